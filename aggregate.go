@@ -0,0 +1,41 @@
+package main
+
+import (
+	"go-backend/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// aggregateItemsHandler godoc
+// @Summary Aggregate items
+// @Description Groups the items matching the filters and computes metrics per group. Filters are the same as for listing, e.g. data.site=North or data.severity[gte]=3.
+// @Description Group fields holding dates (RFC 3339 or YYYY-MM-DD) can be bucketed by appending :hour, :day, :week, :month, :quarter or :year, buckets are named by their start in UTC and weeks start on Monday.
+// @Description Without group_by all matching items form a single group. Metrics with no numeric value in a group are null.
+// @Tags items
+// @Produce json
+// @Param collection query string false "Collection to aggregate, items outside any collection by default"
+// @Param group_by query string false "Comma separated fields" example(data.site,data.reported_at:month)
+// @Param metrics query string false "Comma separated count, sum(field), avg(field), min(field) or max(field)" example(sum(data.cost),avg(data.severity))
+// @Param sort query string false "Comma separated group fields, count or metrics, prefix with - for descending. Groups are ordered by their key otherwise" example(-count)
+// @Param limit query int false "Number of groups, all by default"
+// @Success 200 {object} database.AggregateResult
+// @Failure 400 {object} map[string]string "Invalid query"
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /items/aggregate [get]
+func aggregateItemsHandler(c *gin.Context) {
+	a, err := database.ParseAggregation(c.Request.URL.Query(), "collection")
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	result, err := database.Aggregate(itemCollection(c), a)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
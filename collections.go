@@ -0,0 +1,261 @@
+package main
+
+import (
+	"encoding/json"
+	"go-backend/database"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// listCollectionsHandler godoc
+// @Summary List collections
+// @Description Returns all collections sorted by name
+// @Tags collections
+// @Produce json
+// @Success 200 {array} database.Collection
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /collections [get]
+func listCollectionsHandler(c *gin.Context) {
+	collections, err := database.ListCollections()
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, collections)
+}
+
+// createCollectionHandler godoc
+// @Summary Create a collection
+// @Description Creates an empty collection with an optional JSON Schema (draft 2020-12) for its items
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param collection body database.Collection true "Collection name and schema"
+// @Success 201 {object} database.Collection
+// @Failure 400 {object} map[string]string "Invalid name or schema"
+// @Failure 409 {object} map[string]string "Collection already exists"
+// @Failure 500 {object} map[string]string "Failed to write DB"
+// @Router /collections [post]
+func createCollectionHandler(c *gin.Context) {
+	var collection database.Collection
+
+	if err := c.ShouldBindJSON(&collection); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	if err := database.CreateCollection(collection); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, collection)
+}
+
+// getCollectionHandler godoc
+// @Summary Get a collection
+// @Description Returns a collection with its schema
+// @Tags collections
+// @Produce json
+// @Param name path string true "Collection name"
+// @Success 200 {object} database.Collection
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Router /collections/{name} [get]
+func getCollectionHandler(c *gin.Context) {
+	collection, err := database.GetCollection(c.Param("name"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// dropCollectionHandler godoc
+// @Summary Drop a collection
+// @Description Deletes a collection together with all of its items
+// @Tags collections
+// @Param name path string true "Collection name"
+// @Success 204 "Collection dropped"
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Failure 500 {object} map[string]string "Failed to write DB"
+// @Router /collections/{name} [delete]
+func dropCollectionHandler(c *gin.Context) {
+	if err := database.DropCollection(c.Param("name")); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// getCollectionSchemaHandler godoc
+// @Summary Get a collection schema
+// @Description Returns the JSON Schema items of the collection must match, null if it has none
+// @Tags collections
+// @Produce json
+// @Param name path string true "Collection name"
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Router /collections/{name}/schema [get]
+func getCollectionSchemaHandler(c *gin.Context) {
+	collection, err := database.GetCollection(c.Param("name"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	if len(collection.Schema) == 0 {
+		c.JSON(http.StatusOK, nil)
+		return
+	}
+	c.JSON(http.StatusOK, collection.Schema)
+}
+
+// putCollectionSchemaHandler godoc
+// @Summary Set a collection schema
+// @Description Registers the collection if needed and sets the JSON Schema (draft 2020-12) its items must match
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param name path string true "Collection name"
+// @Param schema body map[string]interface{} true "JSON Schema"
+// @Success 200 {object} database.Collection
+// @Failure 400 {object} map[string]string "Invalid name or schema"
+// @Failure 500 {object} map[string]string "Failed to write DB"
+// @Router /collections/{name}/schema [put]
+func putCollectionSchemaHandler(c *gin.Context) {
+	var schema json.RawMessage
+
+	if err := c.ShouldBindJSON(&schema); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	collection := database.Collection{Name: c.Param("name"), Schema: schema}
+	if err := database.PutCollection(collection); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, collection)
+}
+
+// The item routes below share their handlers with /add and /item/:id,
+// itemCollection picks the collection from the path.
+
+// listCollectionItemsHandler godoc
+// @Summary List items of a collection
+// @Description Returns a page of items of the collection, filtered like GET /items
+// @Tags collections
+// @Produce json
+// @Param name path string true "Collection name"
+// @Param sort query string false "Comma separated fields, prefix with - for descending" example(-data.severity,id)
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} database.Page
+// @Failure 400 {object} map[string]string "Invalid query"
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Router /collections/{name}/items [get]
+func listCollectionItemsHandler(c *gin.Context) {
+	listItemsHandler(c)
+}
+
+// addCollectionItemHandler godoc
+// @Summary Add an item to a collection
+// @Description Add a JSON object to the collection, it must match the collection's JSON Schema
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param name path string true "Collection name"
+// @Param expires_at query string false "RFC 3339 time the item expires at, empty clears it"
+// @Param ttl query string false "Expire the item after this duration, e.g. 24h"
+// @Param data body map[string]interface{} true "Item data"
+// @Success 200 {object} map[string]string "id of the created item"
+// @Failure 400 {object} map[string]string "Invalid JSON or expiry"
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Failure 422 {object} validationErrorResponse "Item does not match the collection schema"
+// @Router /collections/{name}/items [post]
+func addCollectionItemHandler(c *gin.Context) {
+	addItemHandler(c)
+}
+
+// getCollectionItemHandler godoc
+// @Summary Get an item of a collection
+// @Tags collections
+// @Produce json
+// @Param name path string true "Collection name"
+// @Param id path string true "Item ID"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Param expand query string false "Comma separated reference types whose targets are embedded under expanded, * for all"
+// @Success 200 {object} expandedItem
+// @Header 200 {string} ETag "Item version"
+// @Success 304 "Cached copy is current"
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Item or collection not found"
+// @Router /collections/{name}/items/{id} [get]
+func getCollectionItemHandler(c *gin.Context) {
+	getItemHandler(c)
+}
+
+// replaceCollectionItemHandler godoc
+// @Summary Replace an item of a collection
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param name path string true "Collection name"
+// @Param id path string true "Item ID"
+// @Param If-Match header string false "ETag the item must still have"
+// @Param expires_at query string false "RFC 3339 time the item expires at, empty clears it"
+// @Param ttl query string false "Expire the item after this duration, e.g. 24h"
+// @Param data body map[string]interface{} true "Item data"
+// @Success 200 {object} Item
+// @Header 200 {string} ETag "New item version"
+// @Failure 400 {object} map[string]string "Invalid item ID or expiry"
+// @Failure 404 {object} map[string]string "Item or collection not found"
+// @Failure 412 {object} map[string]string "Item version does not match If-Match"
+// @Failure 422 {object} validationErrorResponse "Item does not match the collection schema"
+// @Router /collections/{name}/items/{id} [put]
+func replaceCollectionItemHandler(c *gin.Context) {
+	replaceItemHandler(c)
+}
+
+// patchCollectionItemHandler godoc
+// @Summary Partially update an item of a collection
+// @Tags collections
+// @Accept json
+// @Produce json
+// @Param name path string true "Collection name"
+// @Param id path string true "Item ID"
+// @Param If-Match header string false "ETag the item must still have"
+// @Param expires_at query string false "RFC 3339 time the item expires at, empty clears it"
+// @Param ttl query string false "Expire the item after this duration, e.g. 24h"
+// @Param data body map[string]interface{} true "Partial item data"
+// @Success 200 {object} Item
+// @Header 200 {string} ETag "New item version"
+// @Failure 400 {object} map[string]string "Invalid item ID or expiry"
+// @Failure 404 {object} map[string]string "Item or collection not found"
+// @Failure 412 {object} map[string]string "Item version does not match If-Match"
+// @Failure 422 {object} validationErrorResponse "Item does not match the collection schema"
+// @Router /collections/{name}/items/{id} [patch]
+func patchCollectionItemHandler(c *gin.Context) {
+	patchItemHandler(c)
+}
+
+// deleteCollectionItemHandler godoc
+// @Summary Delete an item of a collection
+// @Tags collections
+// @Param name path string true "Collection name"
+// @Param id path string true "Item ID"
+// @Param If-Match header string false "ETag the item must still have"
+// @Success 204 "Item moved to the trash"
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Item or collection not found"
+// @Failure 409 {object} referencedErrorResponse "Item is referenced with on_delete restrict"
+// @Failure 412 {object} map[string]string "Item version does not match If-Match"
+// @Router /collections/{name}/items/{id} [delete]
+func deleteCollectionItemHandler(c *gin.Context) {
+	deleteItemHandler(c)
+}
@@ -0,0 +1,371 @@
+package database
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Metric functions
+const (
+	MetricCount = "count"
+	MetricSum   = "sum"
+	MetricAvg   = "avg"
+	MetricMin   = "min"
+	MetricMax   = "max"
+)
+
+// Date buckets
+var buckets = map[string]bool{"hour": true, "day": true, "week": true, "month": true, "quarter": true, "year": true}
+
+// Aggregation groups the items matching a query and computes metrics per group
+type Aggregation struct {
+	Query   Query
+	GroupBy []GroupField
+	Metrics []Metric
+	Sort    []SortKey // by group field, "count" or metric name
+	Limit   int       // 0 returns every group
+}
+
+// GroupField is a field to group by, dates can be truncated to a Bucket
+type GroupField struct {
+	Field  string
+	Bucket string
+}
+
+// Metric computes Func over Field in every group, count needs no field
+type Metric struct {
+	Func  string
+	Field string
+}
+
+// AggregateResult holds the groups and the number of items matched
+type AggregateResult struct {
+	Groups  []Group `json:"groups"`
+	Matched int     `json:"matched"`
+}
+
+// Group is the items sharing the values of the group fields. Key maps each
+// group field to its value, Metrics each metric name to its result, null if
+// no item of the group has a usable value.
+type Group struct {
+	Key     map[string]interface{} `json:"key"`
+	Count   int                    `json:"count"`
+	Metrics map[string]interface{} `json:"metrics,omitempty"`
+}
+
+// Name is how the group field appears in group keys, e.g. data.reported_at:month
+func (g GroupField) Name() string {
+	if g.Bucket == "" {
+		return g.Field
+	}
+	return g.Field + ":" + g.Bucket
+}
+
+// Name is how the metric appears in results, e.g. avg(data.severity)
+func (m Metric) Name() string {
+	if m.Func == MetricCount {
+		return MetricCount
+	}
+	return m.Func + "(" + m.Field + ")"
+}
+
+// ParseAggregation builds an Aggregation from URL parameters:
+//
+//	group_by=data.site,data.reported_at:month   fields, dates truncated to hour day week month quarter or year
+//	metrics=sum(data.cost),avg(data.severity)   count sum avg min max, count is always included
+//	sort=-count,data.site                       groups by field, count or metric, "-" for descending
+//	limit=10                                    number of groups, all by default
+//
+// Any other parameter is a filter as in ParseQuery. Keys listed in ignore are skipped.
+func ParseAggregation(values url.Values, ignore ...string) (Aggregation, error) {
+	var a Aggregation
+
+	rest := url.Values{}
+	for k, v := range values {
+		switch k {
+		case "group_by", "metrics", "sort", "limit", "cursor":
+		default:
+			rest[k] = v
+		}
+	}
+	q, err := ParseQuery(rest, ignore...)
+	if err != nil {
+		return a, err
+	}
+	a.Query = q
+
+	if s := values.Get("group_by"); s != "" {
+		for _, f := range strings.Split(s, ",") {
+			g := GroupField{Field: strings.TrimSpace(f)}
+			if i := strings.LastIndex(g.Field, ":"); i >= 0 {
+				g.Field, g.Bucket = g.Field[:i], g.Field[i+1:]
+				if !buckets[g.Bucket] {
+					return a, fmt.Errorf("%w: unknown date bucket %q, use hour, day, week, month, quarter or year", ErrInvalidQuery, g.Bucket)
+				}
+			}
+			if err := validateField(g.Field); err != nil {
+				return a, err
+			}
+			a.GroupBy = append(a.GroupBy, g)
+		}
+	}
+
+	names := map[string]bool{MetricCount: true}
+	for _, g := range a.GroupBy {
+		names[g.Name()] = true
+	}
+	if s := values.Get("metrics"); s != "" {
+		for _, spec := range strings.Split(s, ",") {
+			spec = strings.TrimSpace(spec)
+			if spec == MetricCount {
+				continue
+			}
+			open := strings.Index(spec, "(")
+			if open < 0 || !strings.HasSuffix(spec, ")") {
+				return a, fmt.Errorf("%w: metric %q must be count or fn(field)", ErrInvalidQuery, spec)
+			}
+			m := Metric{Func: spec[:open], Field: spec[open+1 : len(spec)-1]}
+			switch m.Func {
+			case MetricSum, MetricAvg, MetricMin, MetricMax:
+			default:
+				return a, fmt.Errorf("%w: unknown metric %q, use count, sum, avg, min or max", ErrInvalidQuery, m.Func)
+			}
+			if err := validateField(m.Field); err != nil {
+				return a, err
+			}
+			a.Metrics = append(a.Metrics, m)
+			names[m.Name()] = true
+		}
+	}
+
+	if s := values.Get("sort"); s != "" {
+		for _, f := range strings.Split(s, ",") {
+			key := SortKey{Field: strings.TrimSpace(f)}
+			if strings.HasPrefix(key.Field, "-") {
+				key.Field, key.Desc = key.Field[1:], true
+			}
+			if !names[key.Field] {
+				return a, fmt.Errorf("%w: cannot sort groups by %q, use a group field, count or a metric", ErrInvalidQuery, key.Field)
+			}
+			a.Sort = append(a.Sort, key)
+		}
+	}
+
+	if s := values.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return a, fmt.Errorf("%w: limit must be a positive integer", ErrInvalidQuery)
+		}
+		a.Limit = n
+	}
+
+	return a, nil
+}
+
+// Aggregate evaluates the aggregation against the items of a collection,
+// "" for items outside any collection
+func Aggregate(collection string, a Aggregation) (AggregateResult, error) {
+	var items []Item
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		var err error
+		items, err = tx.Query(a.Query.Match)
+		return err
+	})
+	if err != nil {
+		return AggregateResult{}, err
+	}
+
+	return a.Evaluate(items), nil
+}
+
+// accumulator collects the values of one metric in one group
+type accumulator struct {
+	sum   float64
+	n     int
+	min   interface{}
+	max   interface{}
+	found bool
+}
+
+// Evaluate groups the items and computes the metrics
+func (a Aggregation) Evaluate(items []Item) AggregateResult {
+	type group struct {
+		key    []interface{}
+		count  int
+		values []accumulator
+	}
+
+	groups := map[string]*group{}
+	var order []*group
+	for _, item := range items {
+		key := make([]interface{}, len(a.GroupBy))
+		for i, g := range a.GroupBy {
+			key[i] = g.value(item)
+		}
+		raw, _ := json.Marshal(key)
+
+		grp, ok := groups[string(raw)]
+		if !ok {
+			grp = &group{key: key, values: make([]accumulator, len(a.Metrics))}
+			groups[string(raw)] = grp
+			order = append(order, grp)
+		}
+		grp.count++
+
+		for i, m := range a.Metrics {
+			v, ok := FieldValue(item, m.Field)
+			if !ok {
+				continue
+			}
+			acc := &grp.values[i]
+			switch m.Func {
+			case MetricSum, MetricAvg:
+				if n, ok := v.(float64); ok {
+					acc.sum += n
+					acc.n++
+				}
+			case MetricMin, MetricMax:
+				// Numbers and strings (e.g. RFC 3339 dates) are ordered
+				switch v.(type) {
+				case float64, string:
+				default:
+					continue
+				}
+				if !acc.found || compareValues(v, acc.min) < 0 {
+					acc.min = v
+				}
+				if !acc.found || compareValues(v, acc.max) > 0 {
+					acc.max = v
+				}
+				acc.found = true
+			}
+		}
+	}
+
+	result := AggregateResult{Groups: make([]Group, 0, len(order)), Matched: len(items)}
+	for _, grp := range order {
+		g := Group{Key: map[string]interface{}{}, Count: grp.count}
+		for i, f := range a.GroupBy {
+			g.Key[f.Name()] = grp.key[i]
+		}
+		if len(a.Metrics) > 0 {
+			g.Metrics = map[string]interface{}{}
+		}
+		for i, m := range a.Metrics {
+			acc := grp.values[i]
+			var v interface{}
+			switch m.Func {
+			case MetricSum:
+				if acc.n > 0 {
+					v = acc.sum
+				}
+			case MetricAvg:
+				if acc.n > 0 {
+					v = acc.sum / float64(acc.n)
+				}
+			case MetricMin:
+				v = acc.min
+			case MetricMax:
+				v = acc.max
+			}
+			g.Metrics[m.Name()] = v
+		}
+		result.Groups = append(result.Groups, g)
+	}
+
+	a.sortGroups(result.Groups)
+	if a.Limit > 0 && len(result.Groups) > a.Limit {
+		result.Groups = result.Groups[:a.Limit]
+	}
+	return result
+}
+
+// sortGroups orders by the sort keys, then by the group fields
+func (a Aggregation) sortGroups(groups []Group) {
+	keys := append([]SortKey{}, a.Sort...)
+	for _, g := range a.GroupBy {
+		keys = append(keys, SortKey{Field: g.Name()})
+	}
+
+	value := func(g Group, name string) (interface{}, bool) {
+		if name == MetricCount {
+			return float64(g.Count), true
+		}
+		if v, ok := g.Key[name]; ok {
+			return v, v != nil
+		}
+		v := g.Metrics[name]
+		return v, v != nil
+	}
+
+	sort.SliceStable(groups, func(i, j int) bool {
+		for _, k := range keys {
+			a, aok := value(groups[i], k.Field)
+			b, bok := value(groups[j], k.Field)
+			c := compareFields(a, aok, b, bok)
+			if c == 0 {
+				continue
+			}
+			if k.Desc {
+				return c > 0
+			}
+			return c < 0
+		}
+		return false
+	})
+}
+
+// value returns the group value of an item, nil if the field is missing
+// or a bucketed field is not a date
+func (g GroupField) value(item Item) interface{} {
+	v, ok := FieldValue(item, g.Field)
+	if !ok {
+		return nil
+	}
+	if g.Bucket == "" {
+		return v
+	}
+
+	s, ok := v.(string)
+	if !ok {
+		return nil
+	}
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		if t, err = time.Parse("2006-01-02", s); err != nil {
+			return nil
+		}
+	}
+	return truncateDate(t.UTC(), g.Bucket).Format(time.RFC3339)
+}
+
+// truncateDate returns the start of the bucket containing t, weeks start on Monday
+func truncateDate(t time.Time, bucket string) time.Time {
+	y, m, d := t.Date()
+	switch bucket {
+	case "hour":
+		return t.Truncate(time.Hour)
+	case "day":
+		return time.Date(y, m, d, 0, 0, 0, 0, time.UTC)
+	case "week":
+		offset := (int(t.Weekday()) + 6) % 7
+		return time.Date(y, m, d-offset, 0, 0, 0, 0, time.UTC)
+	case "month":
+		return time.Date(y, m, 1, 0, 0, 0, 0, time.UTC)
+	case "quarter":
+		return time.Date(y, m-(m-1)%3, 1, 0, 0, 0, 0, time.UTC)
+	default:
+		return time.Date(y, 1, 1, 0, 0, 0, 0, time.UTC)
+	}
+}
@@ -0,0 +1,147 @@
+package database
+
+import (
+	"errors"
+	"net/url"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func createAggregateItems(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+	createItems(t,
+		Item{ID: "a", Data: map[string]interface{}{"site": "North", "severity": 3.0, "cost": 10.0, "at": "2024-03-10T12:00:00Z"}},
+		Item{ID: "b", Data: map[string]interface{}{"site": "North", "severity": 1.0, "cost": "n/a", "at": "2024-03-11"}},
+		Item{ID: "c", Data: map[string]interface{}{"site": "South", "severity": 5.0, "at": "2024-05-01T00:00:00Z"}},
+		Item{ID: "d", Data: map[string]interface{}{"site": "South", "severity": 4.0, "at": "not a date"}},
+		Item{ID: "e", Data: map[string]interface{}{"severity": 2.0, "cost": 4.0, "at": "2024-12-31T23:00:00Z"}},
+	)
+}
+
+func parseAggregation(t *testing.T, raw string) Aggregation {
+	t.Helper()
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	a, err := ParseAggregation(values)
+	if err != nil {
+		t.Fatalf("ParseAggregation(%q): %v", raw, err)
+	}
+	return a
+}
+
+// group is a group keyed by one field with the given metrics
+func group(field string, key interface{}, count int, metrics map[string]interface{}) Group {
+	return Group{Key: map[string]interface{}{field: key}, Count: count, Metrics: metrics}
+}
+
+func TestAggregate(t *testing.T) {
+	createAggregateItems(t)
+
+	type m = map[string]interface{}
+	tests := []struct {
+		query   string
+		matched int
+		want    []Group
+	}{
+		{"", 5, []Group{{Key: m{}, Count: 5}}},
+		{"group_by=data.site&metrics=sum(data.cost),avg(data.severity)", 5, []Group{
+			group("data.site", nil, 1, m{"sum(data.cost)": 4.0, "avg(data.severity)": 2.0}),
+			group("data.site", "North", 2, m{"sum(data.cost)": 10.0, "avg(data.severity)": 2.0}),
+			group("data.site", "South", 2, m{"sum(data.cost)": nil, "avg(data.severity)": 4.5}),
+		}},
+		{"group_by=data.site&metrics=min(data.severity),max(data.at),min(data.weight)", 5, []Group{
+			group("data.site", nil, 1, m{"min(data.severity)": 2.0, "max(data.at)": "2024-12-31T23:00:00Z", "min(data.weight)": nil}),
+			group("data.site", "North", 2, m{"min(data.severity)": 1.0, "max(data.at)": "2024-03-11", "min(data.weight)": nil}),
+			group("data.site", "South", 2, m{"min(data.severity)": 4.0, "max(data.at)": "not a date", "min(data.weight)": nil}),
+		}},
+		{"group_by=data.at:week", 5, []Group{
+			group("data.at:week", nil, 1, nil),
+			group("data.at:week", "2024-03-04T00:00:00Z", 1, nil), // Sunday
+			group("data.at:week", "2024-03-11T00:00:00Z", 1, nil), // the Monday after
+			group("data.at:week", "2024-04-29T00:00:00Z", 1, nil),
+			group("data.at:week", "2024-12-30T00:00:00Z", 1, nil),
+		}},
+		{"group_by=data.at:quarter", 5, []Group{
+			group("data.at:quarter", nil, 1, nil),
+			group("data.at:quarter", "2024-01-01T00:00:00Z", 2, nil),
+			group("data.at:quarter", "2024-04-01T00:00:00Z", 1, nil),
+			group("data.at:quarter", "2024-10-01T00:00:00Z", 1, nil),
+		}},
+		{"data.site=North&group_by=data.at:day", 2, []Group{
+			group("data.at:day", "2024-03-10T00:00:00Z", 1, nil),
+			group("data.at:day", "2024-03-11T00:00:00Z", 1, nil),
+		}},
+		{"group_by=data.site&sort=-count,data.site&limit=2", 5, []Group{
+			group("data.site", "North", 2, nil),
+			group("data.site", "South", 2, nil),
+		}},
+		{"group_by=data.site&metrics=avg(data.severity)&sort=-avg(data.severity)", 5, []Group{
+			group("data.site", "South", 2, m{"avg(data.severity)": 4.5}),
+			group("data.site", nil, 1, m{"avg(data.severity)": 2.0}),
+			group("data.site", "North", 2, m{"avg(data.severity)": 2.0}),
+		}},
+		{"group_by=data.site&metrics=sum(data.cost)&sort=sum(data.cost)&limit=1", 5, []Group{
+			group("data.site", "South", 2, m{"sum(data.cost)": nil}),
+		}},
+	}
+	for _, tt := range tests {
+		result, err := Aggregate("", parseAggregation(t, tt.query))
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if result.Matched != tt.matched || !reflect.DeepEqual(result.Groups, tt.want) {
+			t.Errorf("%s = %d matched, %+v\nwant %d matched, %+v", tt.query, result.Matched, result.Groups, tt.matched, tt.want)
+		}
+	}
+}
+
+func TestParseAggregationRejects(t *testing.T) {
+	tests := []string{
+		"group_by=data.at:decade",
+		"group_by=name",
+		"metrics=median(data.cost)",
+		"metrics=sum",
+		"metrics=sum(cost)",
+		"group_by=data.site&sort=data.cost",
+		"metrics=sum(data.cost)&sort=avg(data.cost)",
+		"limit=0",
+		"data.site[like]=x",
+	}
+	for _, raw := range tests {
+		values, _ := url.ParseQuery(raw)
+		if _, err := ParseAggregation(values); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ParseAggregation(%q): err = %v, want ErrInvalidQuery", raw, err)
+		}
+	}
+}
+
+func TestTruncateDate(t *testing.T) {
+	tests := []struct {
+		at, bucket, want string
+	}{
+		{"2024-03-10T12:34:56Z", "hour", "2024-03-10T12:00:00Z"},
+		{"2024-03-10T12:34:56Z", "day", "2024-03-10T00:00:00Z"},
+		{"2024-03-10T12:34:56Z", "week", "2024-03-04T00:00:00Z"}, // Sunday
+		{"2024-03-11T00:00:00Z", "week", "2024-03-11T00:00:00Z"}, // Monday
+		{"2025-01-01T08:00:00Z", "week", "2024-12-30T00:00:00Z"},
+		{"2024-02-29T10:00:00Z", "month", "2024-02-01T00:00:00Z"},
+		{"2024-02-29T10:00:00Z", "quarter", "2024-01-01T00:00:00Z"},
+		{"2024-06-30T23:59:59Z", "quarter", "2024-04-01T00:00:00Z"},
+		{"2024-07-01T00:00:00Z", "quarter", "2024-07-01T00:00:00Z"},
+		{"2024-12-31T23:59:59Z", "quarter", "2024-10-01T00:00:00Z"},
+		{"2024-12-31T23:59:59Z", "year", "2024-01-01T00:00:00Z"},
+	}
+	for _, tt := range tests {
+		at, err := time.Parse(time.RFC3339, tt.at)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := truncateDate(at, tt.bucket).Format(time.RFC3339); got != tt.want {
+			t.Errorf("truncateDate(%s, %s) = %s, want %s", tt.at, tt.bucket, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,337 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/santhosh-tekuri/jsonschema/v6"
+)
+
+// collectionsNamespace holds one item per collection, keyed by name
+const collectionsNamespace = "_collections"
+
+var (
+	// ErrCollectionNotFound is returned for collections that were never created.
+	ErrCollectionNotFound = errors.New("collection not found")
+	// ErrCollectionExists is returned when creating a collection twice.
+	ErrCollectionExists = errors.New("collection already exists")
+	// ErrInvalidCollection is returned for bad collection names or schemas.
+	ErrInvalidCollection = errors.New("invalid collection")
+)
+
+var collectionName = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// Collection is a named group of items stored in a namespace of its own,
+// with an optional JSON Schema (draft 2020-12 unless the schema declares
+// another $schema) that the data of every item in it must satisfy.
+type Collection struct {
+	Name   string          `json:"name"`
+	Schema json.RawMessage `json:"schema,omitempty" swaggertype:"object"`
+}
+
+// ValidationIssue is one schema violation, Path is a JSON pointer into the
+// item data and Keyword the schema keyword that failed, e.g. required
+type ValidationIssue struct {
+	Path    string `json:"path"`
+	Keyword string `json:"keyword"`
+	Message string `json:"message"`
+}
+
+// ValidationError lists every way an item violates its collection schema
+type ValidationError struct {
+	Collection string
+	Issues     []ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("item does not match schema of collection %q (%d issues)", e.Collection, len(e.Issues))
+}
+
+// compiled caches schemas by collection name together with the source
+// they were compiled from, so schema updates are picked up
+var (
+	compiledMu sync.Mutex
+	compiled   = map[string]compiledSchema{}
+)
+
+type compiledSchema struct {
+	source string
+	schema *jsonschema.Schema
+}
+
+// CreateCollection creates a new collection, failing with ErrCollectionExists if the name is taken
+func CreateCollection(c Collection) error {
+	return Update(func(tx *Tx) error {
+		return tx.CreateCollection(c)
+	})
+}
+
+// PutCollection creates a collection or replaces its schema.
+// Items already stored are not revalidated.
+func PutCollection(c Collection) error {
+	return Update(func(tx *Tx) error {
+		return tx.PutCollection(c)
+	})
+}
+
+// GetCollection returns a collection by name
+func GetCollection(name string) (Collection, error) {
+	var c Collection
+
+	err := View(func(tx *Tx) error {
+		var err error
+		c, err = tx.Collection(name)
+		return err
+	})
+
+	return c, err
+}
+
+// ListCollections returns all collections sorted by name
+func ListCollections() ([]Collection, error) {
+	var collections []Collection
+
+	err := View(func(tx *Tx) error {
+		var err error
+		collections, err = tx.Collections()
+		return err
+	})
+
+	return collections, err
+}
+
+// DropCollection deletes a collection, every item in it, its trash, their references and history
+func DropCollection(name string) error {
+	return Update(func(tx *Tx) error {
+		return tx.DropCollection(name)
+	})
+}
+
+// Collection returns a collection by name
+func (tx *Tx) Collection(name string) (Collection, error) {
+	item, found, err := tx.In(collectionsNamespace).Get(name)
+	if err != nil {
+		return Collection{}, err
+	}
+	if !found {
+		return Collection{}, ErrCollectionNotFound
+	}
+	return collectionFromItem(item)
+}
+
+// Collections returns all collections sorted by name
+func (tx *Tx) Collections() ([]Collection, error) {
+	items, err := tx.In(collectionsNamespace).List()
+	if err != nil {
+		return nil, err
+	}
+
+	collections := make([]Collection, 0, len(items))
+	for _, item := range items {
+		c, err := collectionFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		collections = append(collections, c)
+	}
+	sort.Slice(collections, func(i, j int) bool {
+		return collections[i].Name < collections[j].Name
+	})
+	return collections, nil
+}
+
+// CreateCollection creates a new collection, failing with ErrCollectionExists if the name is taken
+func (tx *Tx) CreateCollection(c Collection) error {
+	item, err := c.item()
+	if err != nil {
+		return err
+	}
+
+	err = tx.In(collectionsNamespace).Insert(item)
+	if err == ErrConflict {
+		return ErrCollectionExists
+	}
+	return err
+}
+
+// PutCollection creates a collection or replaces its schema
+func (tx *Tx) PutCollection(c Collection) error {
+	item, err := c.item()
+	if err != nil {
+		return err
+	}
+
+	catalog := tx.In(collectionsNamespace)
+	err = catalog.Put(item)
+	if err == ErrNotFound {
+		return catalog.Insert(item)
+	}
+	return err
+}
+
+// DropCollection deletes a collection and, once committed, its namespace
+func (tx *Tx) DropCollection(name string) error {
+	err := tx.In(collectionsNamespace).Delete(name)
+	if err == ErrNotFound {
+		return ErrCollectionNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	delete(tx.changes, name)
+	tx.drops = append(tx.drops, name)
+	if err := tx.dropTrash(name); err != nil {
+		return err
+	}
+	if err := tx.dropReferences(name); err != nil {
+		return err
+	}
+	return tx.dropRevisions(name)
+}
+
+// requireCollection fails with ErrCollectionNotFound unless this view is
+// the default namespace or the namespace of an existing collection
+func (tx *Tx) requireCollection() error {
+	if tx.ns == "" {
+		return nil
+	}
+	_, err := tx.Collection(tx.ns)
+	return err
+}
+
+// validate checks item data against the schema of its collection.
+// Items without a collection are not validated.
+func (tx *Tx) validate(item Item) error {
+	if item.Collection == "" {
+		return nil
+	}
+
+	c, err := tx.Collection(item.Collection)
+	if err != nil || len(c.Schema) == 0 {
+		return err
+	}
+	sch, err := c.compiled()
+	if err != nil {
+		return err
+	}
+
+	var data interface{} = map[string]interface{}{}
+	if item.Data != nil {
+		data = item.Data
+	}
+
+	err = sch.Validate(data)
+	var verr *jsonschema.ValidationError
+	if errors.As(err, &verr) {
+		return &ValidationError{Collection: item.Collection, Issues: validationIssues(verr)}
+	}
+	return err
+}
+
+// item converts the collection to its catalog entry
+func (c Collection) item() (Item, error) {
+	if !collectionName.MatchString(c.Name) {
+		return Item{}, fmt.Errorf("%w: name must match %s", ErrInvalidCollection, collectionName)
+	}
+
+	data := map[string]interface{}{}
+	if len(c.Schema) > 0 && string(c.Schema) != "null" {
+		if _, err := c.compiled(); err != nil {
+			return Item{}, fmt.Errorf("%w: %v", ErrInvalidCollection, err)
+		}
+
+		var schema interface{}
+		if err := json.Unmarshal(c.Schema, &schema); err != nil {
+			return Item{}, fmt.Errorf("%w: %v", ErrInvalidCollection, err)
+		}
+		data["schema"] = schema
+	}
+
+	return Item{ID: c.Name, Data: data}, nil
+}
+
+func collectionFromItem(item Item) (Collection, error) {
+	c := Collection{Name: item.ID}
+
+	if schema, ok := item.Data["schema"]; ok {
+		raw, err := json.Marshal(schema)
+		if err != nil {
+			return Collection{}, err
+		}
+		c.Schema = raw
+	}
+	return c, nil
+}
+
+// compiled returns the compiled schema, reusing the cached one if the source is unchanged
+func (c Collection) compiled() (*jsonschema.Schema, error) {
+	compiledMu.Lock()
+	defer compiledMu.Unlock()
+
+	if cached, ok := compiled[c.Name]; ok && cached.source == string(c.Schema) {
+		return cached.schema, nil
+	}
+
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(c.Schema))
+	if err != nil {
+		return nil, err
+	}
+
+	url := "collection://" + c.Name + "/schema.json"
+	compiler := jsonschema.NewCompiler()
+	compiler.DefaultDraft(jsonschema.Draft2020)
+	compiler.UseLoader(noExternalRefs{})
+	if err := compiler.AddResource(url, doc); err != nil {
+		return nil, err
+	}
+	sch, err := compiler.Compile(url)
+	if err != nil {
+		return nil, err
+	}
+
+	compiled[c.Name] = compiledSchema{source: string(c.Schema), schema: sch}
+	return sch, nil
+}
+
+// validationIssues collects the leaf causes of the error tree. The basic
+// output is not used, it reports a failed $ref instead of its causes.
+func validationIssues(verr *jsonschema.ValidationError) []ValidationIssue {
+	issues := []ValidationIssue{}
+	var collect func(unit jsonschema.OutputUnit)
+	collect = func(unit jsonschema.OutputUnit) {
+		for _, cause := range unit.Errors {
+			collect(cause)
+		}
+		if len(unit.Errors) > 0 || unit.Error == nil {
+			return
+		}
+
+		keyword := unit.KeywordLocation[strings.LastIndex(unit.KeywordLocation, "/")+1:]
+		if path := unit.Error.Kind.KeywordPath(); len(path) > 0 {
+			keyword = path[len(path)-1]
+		}
+		issues = append(issues, ValidationIssue{
+			Path:    unit.InstanceLocation,
+			Keyword: keyword,
+			Message: unit.Error.String(),
+		})
+	}
+	collect(*verr.DetailedOutput())
+	return issues
+}
+
+// noExternalRefs fails every $ref outside the collection schema, so a
+// schema cannot make the server read local files or fetch URLs. The
+// draft meta-schemas are built into the compiler and still resolve.
+type noExternalRefs struct{}
+
+func (noExternalRefs) Load(url string) (interface{}, error) {
+	return nil, fmt.Errorf("external schema %s is not allowed", url)
+}
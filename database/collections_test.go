@@ -0,0 +1,91 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+const assetSchema = `{
+	"$schema": "https://json-schema.org/draft/2020-12/schema",
+	"type": "object",
+	"required": ["name"],
+	"properties": {
+		"name": {"type": "string"},
+		"count": {"$ref": "#/$defs/count"}
+	},
+	"additionalProperties": false,
+	"$defs": {"count": {"type": "integer", "minimum": 0}}
+}`
+
+func TestSchemaValidation(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+	if err := CreateCollection(Collection{Name: "assets", Schema: json.RawMessage(assetSchema)}); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := CreateItem(Item{ID: "a", Collection: "assets", Data: map[string]interface{}{"name": "pump", "count": 2.0}}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	err := CreateItem(Item{ID: "b", Collection: "assets", Data: map[string]interface{}{"count": -1.0, "color": "red"}}, "test")
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("creating an invalid item: err = %v, want a ValidationError", err)
+	}
+	got := map[string]string{}
+	for _, issue := range verr.Issues {
+		got[issue.Keyword] = issue.Path
+		if issue.Message == "validation failed" {
+			t.Errorf("issue %+v does not tell what failed", issue)
+		}
+	}
+	want := map[string]string{"required": "", "minimum": "/count", "additionalProperties": ""}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %+v, want keywords and paths %v", verr.Issues, want)
+	}
+
+	_, err = ReplaceItem("assets", "a", map[string]interface{}{"name": 1.0}, WriteOptions{})
+	if !errors.As(err, &verr) || len(verr.Issues) != 1 || verr.Issues[0].Keyword != "type" || verr.Issues[0].Path != "/name" {
+		t.Errorf("replacing with an invalid name: err = %v, want a type issue at /name", err)
+	}
+	if item, err := GetItem("assets", "a"); err != nil || item.Data["name"] != "pump" {
+		t.Errorf("item after a rejected replace = %+v, %v", item, err)
+	}
+
+	if err := PutCollection(Collection{Name: "assets", Schema: json.RawMessage(`{"type": "object"}`)}); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateItem(Item{ID: "b", Collection: "assets", Data: map[string]interface{}{"color": "red"}}, "test"); err != nil {
+		t.Errorf("creating an item after the schema was relaxed: %v", err)
+	}
+}
+
+func TestSchemaRejectsExternalRefs(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	path := filepath.Join(t.TempDir(), "schema.json")
+	if err := os.WriteFile(path, []byte(`{"type": "string"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	refs := []string{
+		"file://" + filepath.ToSlash(path),
+		"http://127.0.0.1:1/schema.json",
+		"other.json",
+	}
+	for _, ref := range refs {
+		schema := `{"properties": {"name": {"$ref": "` + ref + `"}}}`
+		err := CreateCollection(Collection{Name: "assets", Schema: json.RawMessage(schema)})
+		if !errors.Is(err, ErrInvalidCollection) {
+			t.Errorf("schema with $ref %s: err = %v, want ErrInvalidCollection", ref, err)
+		}
+	}
+
+	if _, err := GetCollection("assets"); err != ErrCollectionNotFound {
+		t.Errorf("a rejected schema created the collection: err = %v", err)
+	}
+}
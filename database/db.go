@@ -1,63 +1,279 @@
 package database
 
 import (
-    "encoding/json"
-    "os"
-    "fmt"
-    "time"
+	"errors"
+	"time"
 )
 
 const dbFile = "./database/data.json"
 
-func NewID() string {
-    return fmt.Sprintf("id-%d", time.Now().UnixNano())
-}
+var (
+	// ErrNotFound is returned when no item exists for the given ID.
+	ErrNotFound = errors.New("item not found")
+	// ErrConflict is returned when an item with the given ID already exists.
+	ErrConflict = errors.New("item already exists")
+	// ErrReadOnly is returned when a View transaction tries to modify data.
+	ErrReadOnly = errors.New("read-only transaction")
+	// ErrVersionMismatch is returned when a write expects another version of the item.
+	ErrVersionMismatch = errors.New("item version does not match")
+)
 
+// Item is a JSON object stored in the database. Version starts at 1 and
+// is incremented by every write, items written before versioning have 0.
+// Items whose ExpiresAt has passed are no longer read and are moved to
+// the trash by the sweeper.
 type Item struct {
-    ID   string                 `json:"id"`
-    Data map[string]interface{} `json:"data"`
+	ID         string                 `json:"id"`
+	Collection string                 `json:"collection,omitempty"`
+	Version    int64                  `json:"version"`
+	ExpiresAt  *time.Time             `json:"expires_at,omitempty"`
+	Data       map[string]interface{} `json:"data"`
 }
 
-// Read existing array from file
-func ReadDB() ([]Item, error) {
-    if _, err := os.Stat(dbFile); os.IsNotExist(err) {
-        return []Item{}, nil
-    }
+// WriteOptions describe a change to an existing item
+type WriteOptions struct {
+	// Author is recorded in the revision written by the change
+	Author string
+	// IfVersion fails the change with ErrVersionMismatch unless the item
+	// has one of these versions, nil skips the check
+	IfVersion []int64
+	// SetExpiry replaces the expiry of the item with ExpiresAt, nil clears it
+	SetExpiry bool
+	ExpiresAt *time.Time
+}
 
-    content, err := os.ReadFile(dbFile)
-    if err != nil {
-        return nil, err
-    }
+// check fails with ErrVersionMismatch if the item has an unexpected version
+func (o WriteOptions) check(item Item) error {
+	if o.IfVersion == nil {
+		return nil
+	}
+	for _, v := range o.IfVersion {
+		if v == item.Version {
+			return nil
+		}
+	}
+	return ErrVersionMismatch
+}
 
-    var items []Item
-    err = json.Unmarshal(content, &items)
-    if err != nil {
-        return nil, err
-    }
+// GetByID returns an item outside any collection
+func GetByID(id string) (*Item, bool, error) {
+	item, err := GetItem("", id)
+	if err == ErrNotFound {
+		return nil, false, nil
+	}
+	if err != nil {
+		return nil, false, err
+	}
 
-    return items, nil
+	return item, true, nil
 }
 
-// Write array back to file
-func WriteDB(items []Item) error {
-    content, err := json.MarshalIndent(items, "", "  ")
-    if err != nil {
-        return err
-    }
-    return os.WriteFile(dbFile, content, 0644)
+// GetItem returns an item of a collection, "" for items outside any collection
+func GetItem(collection, id string) (*Item, error) {
+	var item Item
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		var found bool
+		var err error
+		item, found, err = tx.Get(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
 }
 
-func GetByID(id string) (*Item, bool, error) {
-    items, err := ReadDB()
-    if err != nil {
-        return nil, false, err
-    }
-
-    for _, item := range items {
-        if item.ID == id {
-            return &item, true, nil
-        }
-    }
-
-    return nil, false, nil
-}
\ No newline at end of file
+// CreateItem stores a new item in item.Collection, failing with ErrConflict if the ID is taken
+func CreateItem(item Item, author string) error {
+	return Update(func(tx *Tx) error {
+		tx.SetAuthor(author)
+		tx = tx.In(item.Collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		return tx.Insert(item)
+	})
+}
+
+// ImportItems writes a batch of items to a collection in one transaction.
+// Items whose ID is taken replace the stored item, the others are created.
+// A failing item does not stop the others, its error is returned at its
+// index. The returned error is set if the whole batch failed.
+func ImportItems(collection string, items []Item, author string) ([]error, error) {
+	errs := make([]error, len(items))
+
+	err := Update(func(tx *Tx) error {
+		tx.SetAuthor(author)
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		for i, item := range items {
+			_, found, err := tx.Get(item.ID)
+			if err != nil {
+				return err
+			}
+			if found {
+				errs[i] = tx.Put(item)
+			} else {
+				errs[i] = tx.Insert(item)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return errs, nil
+}
+
+// ReplaceItem overwrites the data of an existing item
+func ReplaceItem(collection, id string, data map[string]interface{}, opts WriteOptions) (*Item, error) {
+	return modifyItem(collection, id, opts, func(item *Item) error {
+		item.Data = data
+		return nil
+	})
+}
+
+// PatchItem merges the given keys into the data of an existing item.
+// A null value removes the key.
+func PatchItem(collection, id string, patch map[string]interface{}, opts WriteOptions) (*Item, error) {
+	return modifyItem(collection, id, opts, func(item *Item) error {
+		if item.Data == nil {
+			item.Data = map[string]interface{}{}
+		}
+		for k, v := range patch {
+			if v == nil {
+				delete(item.Data, k)
+				continue
+			}
+			item.Data[k] = v
+		}
+		return nil
+	})
+}
+
+// DeleteItem moves an item to the trash, see UndeleteItem
+func DeleteItem(collection, id string, opts WriteOptions) error {
+	return Update(func(tx *Tx) error {
+		tx.SetAuthor(opts.Author)
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		item, found, err := tx.Get(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+		if err := opts.check(item); err != nil {
+			return err
+		}
+
+		return tx.Trash(id)
+	})
+}
+
+// modifyItem loads an item, applies fn and stores the result in one transaction
+func modifyItem(collection, id string, opts WriteOptions, fn func(item *Item) error) (*Item, error) {
+	var item Item
+
+	err := Update(func(tx *Tx) error {
+		tx.SetAuthor(opts.Author)
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		var found bool
+		var err error
+		item, found, err = tx.Get(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrNotFound
+		}
+		if err := opts.check(item); err != nil {
+			return err
+		}
+
+		if err := fn(&item); err != nil {
+			return err
+		}
+		if opts.SetExpiry {
+			item.ExpiresAt = opts.ExpiresAt
+		}
+		if err := tx.Put(item); err != nil {
+			return err
+		}
+
+		item, _, err = tx.Get(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+func filterItems(items []Item, match func(Item) bool) []Item {
+	if match == nil {
+		return items
+	}
+
+	filtered := []Item{}
+	for _, item := range items {
+		if match(item) {
+			filtered = append(filtered, item)
+		}
+	}
+	return filtered
+}
+
+// cloneItem deep copies an item so callers cannot mutate stored data
+func cloneItem(item Item) Item {
+	if item.Data != nil {
+		item.Data = cloneValue(item.Data).(map[string]interface{})
+	}
+	return item
+}
+
+func cloneValue(v interface{}) interface{} {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, e := range v {
+			m[k] = cloneValue(e)
+		}
+		return m
+	case []interface{}:
+		s := make([]interface{}, len(v))
+		for i, e := range v {
+			s[i] = cloneValue(e)
+		}
+		return s
+	default:
+		return v
+	}
+}
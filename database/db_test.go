@@ -0,0 +1,104 @@
+package database
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// useBackend makes b the backend of the test and drops it and any
+// write-ahead log opened by the test afterwards
+func useBackend(t *testing.T, b Backend) {
+	t.Helper()
+	SetBackend(b)
+	t.Cleanup(func() {
+		if wal != nil {
+			wal.Close()
+			wal = nil
+		}
+		SetBackend(NewMemoryBackend())
+		b.Close()
+	})
+}
+
+func createItems(t *testing.T, items ...Item) {
+	t.Helper()
+	for _, item := range items {
+		if err := CreateItem(item, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestJSONBackendPersists(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	useBackend(t, NewJSONBackend(path))
+
+	if err := CreateItem(Item{ID: "a", Data: map[string]interface{}{"name": "pump"}}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	useBackend(t, NewJSONBackend(path))
+	item, err := GetItem("", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Data["name"] != "pump" || item.Version != 1 {
+		t.Errorf("reopened item = %+v, want name pump at version 1", item)
+	}
+
+	content := `[{"id": "a", "version": 4, "data": {"name": "valve"}}]`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	item, err = GetItem("", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Data["name"] != "valve" || item.Version != 4 {
+		t.Errorf("item after the file changed = %+v, want name valve at version 4", item)
+	}
+}
+
+func TestIfVersion(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	if err := CreateItem(Item{ID: "a", Data: map[string]interface{}{"n": 1.0}}, "test"); err != nil {
+		t.Fatal(err)
+	}
+	if err := CreateItem(Item{ID: "a"}, "test"); err != ErrConflict {
+		t.Errorf("creating a taken ID: err = %v, want ErrConflict", err)
+	}
+
+	item, err := ReplaceItem("", "a", map[string]interface{}{"n": 2.0}, WriteOptions{IfVersion: []int64{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Version != 2 {
+		t.Errorf("version after replace = %d, want 2", item.Version)
+	}
+
+	_, err = PatchItem("", "a", map[string]interface{}{"n": 3.0}, WriteOptions{IfVersion: []int64{1}})
+	if !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("patching a stale version: err = %v, want ErrVersionMismatch", err)
+	}
+	if err := DeleteItem("", "a", WriteOptions{IfVersion: []int64{1, 3}}); !errors.Is(err, ErrVersionMismatch) {
+		t.Errorf("deleting a stale version: err = %v, want ErrVersionMismatch", err)
+	}
+
+	item, err = GetItem("", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Data["n"] != 2.0 || item.Version != 2 {
+		t.Errorf("item after rejected changes = %+v, want n 2 at version 2", item)
+	}
+
+	if err := DeleteItem("", "a", WriteOptions{IfVersion: []int64{1, 2}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := GetItem("", "a"); err != ErrNotFound {
+		t.Errorf("getting a deleted item: err = %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,149 @@
+package database
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Event types of the change feed
+const (
+	EventCreated = "created"
+	EventUpdated = "updated"
+	EventDeleted = "deleted"
+)
+
+// EventHistory is how many past events are kept for subscribers resuming after a disconnect
+const EventHistory = 1000
+
+// subscriberBuffer is how many events a subscriber may fall behind before it is dropped
+const subscriberBuffer = 256
+
+// ErrEventsLost is returned when resuming after an event that is no longer
+// kept, the subscriber has to reload instead
+var ErrEventsLost = errors.New("events since the last event ID are no longer available")
+
+// Event is a committed change to an item. Item holds the new state and
+// is left out for deletes.
+type Event struct {
+	ID         uint64    `json:"id"`
+	Type       string    `json:"type"`
+	Collection string    `json:"collection,omitempty"`
+	ItemID     string    `json:"item_id"`
+	Version    int64     `json:"version"`
+	Author     string    `json:"author,omitempty"`
+	Time       time.Time `json:"time"`
+	Item       *Item     `json:"item,omitempty"`
+}
+
+// Subscription receives the events published after it was opened. Events
+// is closed when the subscription is closed or the subscriber fell too
+// far behind, it can then resume from the last event it received.
+type Subscription struct {
+	Events <-chan Event
+	ch     chan Event
+}
+
+// broker fans committed events out to the subscribers of this process
+type broker struct {
+	mu      sync.Mutex
+	last    uint64  // ID of the newest event
+	history []Event // newest events, oldest first
+	subs    map[*Subscription]bool
+}
+
+// IDs start at the start time in microseconds, so IDs from before a restart
+// are older than anything kept and resuming from them fails with
+// ErrEventsLost. They stay below 2^53 and survive JSON in JavaScript.
+var events = &broker{last: uint64(time.Now().UnixMicro()), subs: map[*Subscription]bool{}}
+
+// SubscribeEvents opens a subscription. With resume the events after
+// lastID are returned to be delivered first, failing with ErrEventsLost
+// if some of them are no longer kept.
+func SubscribeEvents(lastID uint64, resume bool) (*Subscription, []Event, error) {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	var missed []Event
+	if resume && lastID != events.last {
+		oldest := events.last + 1
+		if len(events.history) > 0 {
+			oldest = events.history[0].ID
+		}
+		if lastID+1 < oldest || lastID > events.last {
+			return nil, nil, ErrEventsLost
+		}
+		missed = append(missed, events.history[len(events.history)-int(events.last-lastID):]...)
+	}
+
+	ch := make(chan Event, subscriberBuffer)
+	sub := &Subscription{Events: ch, ch: ch}
+	events.subs[sub] = true
+	return sub, missed, nil
+}
+
+// Close ends the subscription
+func (s *Subscription) Close() {
+	events.mu.Lock()
+	defer events.mu.Unlock()
+
+	if events.subs[s] {
+		delete(events.subs, s)
+		close(s.ch)
+	}
+}
+
+// publish numbers committed events and hands them to every subscriber
+func (b *broker) publish(committed []Event) {
+	if len(committed) == 0 {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, e := range committed {
+		b.last++
+		e.ID = b.last
+
+		b.history = append(b.history, e)
+		if len(b.history) > EventHistory {
+			b.history = b.history[len(b.history)-EventHistory:]
+		}
+
+		for sub := range b.subs {
+			select {
+			case sub.ch <- e:
+			default:
+				delete(b.subs, sub)
+				close(sub.ch)
+			}
+		}
+	}
+}
+
+// recordEvent queues the event of a revision, published once the transaction commits
+func (tx *Tx) recordEvent(op string, item Item, at time.Time) {
+	e := Event{
+		Collection: tx.collectionName(),
+		ItemID:     item.ID,
+		Version:    item.Version,
+		Author:     tx.author,
+		Time:       at,
+	}
+
+	switch op {
+	case RevDelete:
+		e.Type = EventDeleted
+	case RevCreate, RevUndelete:
+		e.Type = EventCreated
+	default:
+		e.Type = EventUpdated
+	}
+	if e.Type != EventDeleted {
+		clone := cloneItem(item)
+		e.Item = &clone
+	}
+
+	tx.events = append(tx.events, e)
+}
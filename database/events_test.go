@@ -0,0 +1,116 @@
+package database
+
+import (
+	"testing"
+	"time"
+)
+
+func nextEvent(t *testing.T, sub *Subscription) Event {
+	t.Helper()
+	select {
+	case e, ok := <-sub.Events:
+		if !ok {
+			t.Fatal("subscription closed")
+		}
+		return e
+	case <-time.After(5 * time.Second):
+		t.Fatal("no event within 5s")
+	}
+	return Event{}
+}
+
+func TestSubscribeEventsResume(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+	sub, missed, err := SubscribeEvents(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+	if len(missed) != 0 {
+		t.Errorf("a new subscription missed %d events", len(missed))
+	}
+
+	createItems(t, Item{ID: "a", Data: map[string]interface{}{}}, Item{ID: "b", Data: map[string]interface{}{}})
+	if err := DeleteItem("", "a", WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	first, second, third := nextEvent(t, sub), nextEvent(t, sub), nextEvent(t, sub)
+	if first.Type != EventCreated || first.ItemID != "a" || second.ID != first.ID+1 || third.Type != EventDeleted || third.Item != nil {
+		t.Fatalf("events = %+v, %+v, %+v", first, second, third)
+	}
+
+	resumed, missed, err := SubscribeEvents(first.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Close()
+	if len(missed) != 2 || missed[0].ID != second.ID || missed[1].ID != third.ID {
+		t.Errorf("resuming after %d missed %+v, want events %d and %d", first.ID, missed, second.ID, third.ID)
+	}
+
+	resumed, missed, err = SubscribeEvents(third.ID, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Close()
+	if len(missed) != 0 {
+		t.Errorf("resuming after the last event missed %+v", missed)
+	}
+
+	for _, lastID := range []uint64{1, third.ID + 1} {
+		if _, _, err := SubscribeEvents(lastID, true); err != ErrEventsLost {
+			t.Errorf("resuming after %d: err = %v, want ErrEventsLost", lastID, err)
+		}
+	}
+}
+
+func TestSubscribeEventsLost(t *testing.T) {
+	sub, _, err := SubscribeEvents(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	events.publish([]Event{{Type: EventCreated, ItemID: "a"}})
+	first := nextEvent(t, sub)
+	sub.Close()
+
+	// the event after first is pushed out of the history
+	flood := make([]Event, EventHistory+1)
+	for i := range flood {
+		flood[i] = Event{Type: EventUpdated, ItemID: "a"}
+	}
+	events.publish(flood)
+
+	if _, _, err := SubscribeEvents(first.ID, true); err != ErrEventsLost {
+		t.Errorf("resuming after a dropped event: err = %v, want ErrEventsLost", err)
+	}
+	resumed, missed, err := SubscribeEvents(first.ID+1, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resumed.Close()
+	if len(missed) != EventHistory || missed[0].ID != first.ID+2 {
+		t.Errorf("resuming before the oldest kept event: %d missed, want %d", len(missed), EventHistory)
+	}
+}
+
+func TestSlowSubscriberIsDropped(t *testing.T) {
+	sub, _, err := SubscribeEvents(0, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sub.Close()
+
+	flood := make([]Event, subscriberBuffer+1)
+	for i := range flood {
+		flood[i] = Event{Type: EventUpdated, ItemID: "a"}
+	}
+	events.publish(flood)
+
+	n := 0
+	for range sub.Events {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("received %d events before the subscription closed, want %d", n, subscriberBuffer)
+	}
+}
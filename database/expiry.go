@@ -0,0 +1,122 @@
+package database
+
+import "time"
+
+// expiryNamespace indexes when items expire and when trash entries were
+// deleted, keyed "<kind>/<time>/<collection>/<id>" with fixed-width UTC
+// times so the keys sort by time. Sweep reads the due entries from the
+// start instead of every item and every trash entry.
+const expiryNamespace = "_expiry"
+
+// Kinds of expiry index entries
+const (
+	indexExpires = "expires"
+	indexDeleted = "deleted"
+)
+
+// indexBuiltKey marks an expiry index that covers every item, databases
+// from before the index and snapshots without it are indexed once
+const indexBuiltKey = "built"
+
+// indexTime formats index times so that they sort as strings
+const indexTime = "2006-01-02T15:04:05.000000000Z"
+
+func indexKey(kind string, at time.Time, collection, id string) string {
+	return kind + "/" + at.UTC().Format(indexTime) + "/" + itemKey(collection, id)
+}
+
+// index adds an entry for the item id of this namespace
+func (tx *Tx) index(key, id string) {
+	tx.In(expiryNamespace).put(Item{ID: key, Data: map[string]interface{}{"collection": tx.ns, "id": id}})
+}
+
+// unindex removes an entry, it may already be gone
+func (tx *Tx) unindex(key string) error {
+	err := tx.In(expiryNamespace).Delete(key)
+	if err == ErrNotFound {
+		return nil
+	}
+	return err
+}
+
+// indexExpiry moves the expiry entry of an item to at, nil removes it
+func (tx *Tx) indexExpiry(id string, at *time.Time) error {
+	if isReserved(tx.ns) {
+		return nil
+	}
+
+	current, found, err := tx.get(id)
+	if err != nil {
+		return err
+	}
+	if found && current.ExpiresAt != nil {
+		if at != nil && current.ExpiresAt.Equal(*at) {
+			return nil
+		}
+		if err := tx.unindex(indexKey(indexExpires, *current.ExpiresAt, tx.ns, id)); err != nil {
+			return err
+		}
+	}
+	if at != nil {
+		tx.index(indexKey(indexExpires, *at, tx.ns, id), id)
+	}
+	return nil
+}
+
+// buildExpiryIndex indexes the expiring items and the trash unless the
+// index is complete already
+func buildExpiryIndex() error {
+	var built bool
+	err := View(func(tx *Tx) error {
+		var err error
+		_, built, err = tx.In(expiryNamespace).Get(indexBuiltKey)
+		return err
+	})
+	if err != nil || built {
+		return err
+	}
+
+	return Update(func(tx *Tx) error {
+		index := tx.In(expiryNamespace)
+		if _, built, err := index.Get(indexBuiltKey); err != nil || built {
+			return err
+		}
+
+		collections, err := tx.Collections()
+		if err != nil {
+			return err
+		}
+		namespaces := []string{""}
+		for _, c := range collections {
+			namespaces = append(namespaces, c.Name)
+		}
+		for _, ns := range namespaces {
+			view := tx.In(ns)
+			items, err := view.query(func(item Item) bool {
+				return item.ExpiresAt != nil
+			})
+			if err != nil {
+				return err
+			}
+			for _, item := range items {
+				view.index(indexKey(indexExpires, *item.ExpiresAt, ns, item.ID), item.ID)
+			}
+		}
+
+		trash, err := tx.In(trashNamespace).List()
+		if err != nil {
+			return err
+		}
+		for _, item := range trash {
+			var entry TrashEntry
+			if err := fromData(item.Data, &entry); err != nil {
+				return err
+			}
+			ns, id := entry.Item.Collection, entry.Item.ID
+			tx.In(ns).index(indexKey(indexDeleted, entry.DeletedAt, ns, id), id)
+		}
+
+		index.put(Item{ID: indexBuiltKey, Data: map[string]interface{}{}})
+		return nil
+	})
+}
@@ -0,0 +1,412 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+var (
+	// ErrInvalidPatch is returned for malformed patch documents
+	ErrInvalidPatch = errors.New("invalid patch")
+	// ErrPatchFailed is returned when a patch cannot be applied to the item
+	ErrPatchFailed = errors.New("patch cannot be applied")
+	// ErrPatchTestFailed is returned when a JSON Patch test operation does not match
+	ErrPatchTestFailed = errors.New("patch test failed")
+)
+
+// Patch is a change to the data of an item, see MergePatch and JSONPatch
+type Patch interface {
+	Apply(data map[string]interface{}) (map[string]interface{}, error)
+}
+
+// MergePatch is an RFC 7396 JSON Merge Patch: objects are merged
+// recursively, null removes a member and anything else replaces the target
+type MergePatch struct {
+	Value interface{}
+}
+
+// JSONPatch is an RFC 6902 JSON Patch, a list of operations applied in
+// order. Paths are JSON pointers into the item data.
+type JSONPatch []PatchOperation
+
+// PatchOperation is one operation of a JSON Patch. Value is nil when the
+// member is missing, so null values can be told apart.
+type PatchOperation struct {
+	Op    string          `json:"op" example:"replace"`
+	Path  string          `json:"path" example:"/site/name"`
+	From  string          `json:"from,omitempty"`
+	Value json.RawMessage `json:"value,omitempty" swaggertype:"object"`
+}
+
+// PatchError tells which operation of a JSON Patch failed and where.
+// Path is the JSON pointer up to the first member that failed to resolve.
+type PatchError struct {
+	Err       error  `json:"-"`
+	Operation int    `json:"operation"`
+	Op        string `json:"op"`
+	Path      string `json:"path"`
+	Message   string `json:"message"`
+}
+
+func (e *PatchError) Error() string {
+	return fmt.Sprintf("%v: operation %d (%s) at %q: %s", e.Err, e.Operation, e.Op, e.Path, e.Message)
+}
+
+func (e *PatchError) Unwrap() error {
+	return e.Err
+}
+
+// ApplyPatch applies a patch to the data of an existing item. The patch
+// is applied to a copy, so a failing operation leaves the item unchanged.
+func ApplyPatch(collection, id string, p Patch, opts WriteOptions) (*Item, error) {
+	return modifyItem(collection, id, opts, func(item *Item) error {
+		data, err := p.Apply(cloneItem(*item).Data)
+		if err != nil {
+			return err
+		}
+		item.Data = data
+		return nil
+	})
+}
+
+// Apply merges the patch into data
+func (p MergePatch) Apply(data map[string]interface{}) (map[string]interface{}, error) {
+	if data == nil {
+		data = map[string]interface{}{}
+	}
+
+	merged, ok := mergePatch(data, p.Value).(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("%w: a merge patch of item data must be an object", ErrInvalidPatch)
+	}
+	return merged, nil
+}
+
+func mergePatch(target, patch interface{}) interface{} {
+	fields, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	doc, ok := target.(map[string]interface{})
+	if !ok {
+		doc = map[string]interface{}{}
+	}
+	for k, v := range fields {
+		if v == nil {
+			delete(doc, k)
+			continue
+		}
+		doc[k] = mergePatch(doc[k], v)
+	}
+	return doc
+}
+
+// Apply runs the operations in order, stopping at the first that fails
+func (p JSONPatch) Apply(data map[string]interface{}) (map[string]interface{}, error) {
+	var doc interface{} = data
+	if data == nil {
+		doc = map[string]interface{}{}
+	}
+
+	for i, op := range p {
+		var err error
+		if doc, err = op.apply(doc); err != nil {
+			var perr *PatchError
+			if errors.As(err, &perr) {
+				perr.Operation, perr.Op = i, op.Op
+				return nil, perr
+			}
+			return nil, err
+		}
+	}
+
+	result, ok := doc.(map[string]interface{})
+	if !ok {
+		return nil, &PatchError{Err: ErrPatchFailed, Operation: len(p) - 1, Op: p[len(p)-1].Op, Message: "item data must stay an object"}
+	}
+	return result, nil
+}
+
+func (op PatchOperation) apply(doc interface{}) (interface{}, error) {
+	path, err := parsePointer(op.Path)
+	if err != nil {
+		return nil, patchError(ErrInvalidPatch, op.Path, "path: %v", err)
+	}
+
+	switch op.Op {
+	case "add", "replace", "test":
+		if op.Value == nil {
+			return nil, patchError(ErrInvalidPatch, op.Path, "value is required")
+		}
+		var value interface{}
+		if err := json.Unmarshal(op.Value, &value); err != nil {
+			return nil, patchError(ErrInvalidPatch, op.Path, "value: %v", err)
+		}
+
+		switch op.Op {
+		case "add":
+			return addValue(doc, path, value)
+		case "replace":
+			return replaceValue(doc, path, value)
+		}
+
+		current, err := getValue(doc, path)
+		if err != nil {
+			return nil, err
+		}
+		if !jsonEqual(current, value) {
+			return nil, patchError(ErrPatchTestFailed, op.Path, "value does not match")
+		}
+		return doc, nil
+
+	case "remove":
+		doc, _, err := removeValue(doc, path)
+		return doc, err
+
+	case "move", "copy":
+		from, err := parsePointer(op.From)
+		if err != nil {
+			return nil, patchError(ErrInvalidPatch, op.From, "from: %v", err)
+		}
+		if op.Op == "move" && strings.HasPrefix(op.Path, op.From+"/") {
+			return nil, patchError(ErrInvalidPatch, op.Path, "cannot move a value into itself")
+		}
+
+		var value interface{}
+		if op.Op == "move" {
+			doc, value, err = removeValue(doc, from)
+		} else {
+			value, err = getValue(doc, from)
+			value = cloneValue(value)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return addValue(doc, path, value)
+
+	default:
+		return nil, patchError(ErrInvalidPatch, op.Path, "unknown op %q, must be add, remove, replace, move, copy or test", op.Op)
+	}
+}
+
+func patchError(err error, path, format string, args ...interface{}) *PatchError {
+	return &PatchError{Err: err, Path: path, Message: fmt.Sprintf(format, args...)}
+}
+
+// parsePointer splits an RFC 6901 JSON pointer into its unescaped tokens
+func parsePointer(p string) ([]string, error) {
+	if p == "" {
+		return nil, nil
+	}
+	if p[0] != '/' {
+		return nil, errors.New("a JSON pointer must be empty or start with /")
+	}
+
+	tokens := strings.Split(p[1:], "/")
+	for i, t := range tokens {
+		for j := 0; j < len(t); j++ {
+			if t[j] == '~' && (j+1 == len(t) || (t[j+1] != '0' && t[j+1] != '1')) {
+				return nil, errors.New("~ must be followed by 0 or 1")
+			}
+		}
+		tokens[i] = strings.ReplaceAll(strings.ReplaceAll(t, "~1", "/"), "~0", "~")
+	}
+	return tokens, nil
+}
+
+// pointer formats the first n tokens as a JSON pointer
+func pointer(tokens []string, n int) string {
+	var b strings.Builder
+	for _, t := range tokens[:n] {
+		b.WriteString("/")
+		b.WriteString(escapePointer(t))
+	}
+	return b.String()
+}
+
+// arrayIndex resolves a token to an index into an array of length n,
+// "-" is n and only allowed when appending
+func arrayIndex(tokens []string, i, n int, appending bool) (int, error) {
+	t := tokens[i]
+	if t == "-" && appending {
+		return n, nil
+	}
+
+	max := n - 1
+	if appending {
+		max = n
+	}
+	idx, err := strconv.Atoi(t)
+	if err != nil || idx < 0 || (len(t) > 1 && t[0] == '0') || t[0] == '+' {
+		return 0, patchError(ErrPatchFailed, pointer(tokens, i+1), "%q is not an array index", t)
+	}
+	if idx > max {
+		return 0, patchError(ErrPatchFailed, pointer(tokens, i+1), "index %d is out of range, the array has %d elements", idx, n)
+	}
+	return idx, nil
+}
+
+// getValue returns the value the pointer refers to
+func getValue(doc interface{}, tokens []string) (interface{}, error) {
+	for i, t := range tokens {
+		switch v := doc.(type) {
+		case map[string]interface{}:
+			child, ok := v[t]
+			if !ok {
+				return nil, patchError(ErrPatchFailed, pointer(tokens, i+1), "member %q does not exist", t)
+			}
+			doc = child
+		case []interface{}:
+			idx, err := arrayIndex(tokens, i, len(v), false)
+			if err != nil {
+				return nil, err
+			}
+			doc = v[idx]
+		default:
+			return nil, patchError(ErrPatchFailed, pointer(tokens, i), "cannot descend into a %s", jsonType(doc))
+		}
+	}
+	return doc, nil
+}
+
+// change replaces the container holding the last token of the pointer
+// with the result of fn and returns the updated document
+func change(doc interface{}, tokens []string, fn func(container interface{}) (interface{}, error)) (interface{}, error) {
+	parent, err := getValue(doc, tokens[:len(tokens)-1])
+	if err != nil {
+		return nil, err
+	}
+
+	updated, err := fn(parent)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 1 {
+		return updated, nil
+	}
+
+	// Slices may have grown or shrunk, so the new container is stored in its parent
+	return change(doc, tokens[:len(tokens)-1], func(grandparent interface{}) (interface{}, error) {
+		switch g := grandparent.(type) {
+		case map[string]interface{}:
+			g[tokens[len(tokens)-2]] = updated
+		case []interface{}:
+			idx, _ := arrayIndex(tokens, len(tokens)-2, len(g), false)
+			g[idx] = updated
+		}
+		return grandparent, nil
+	})
+}
+
+func addValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	last := tokens[len(tokens)-1]
+	return change(doc, tokens, func(container interface{}) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[last] = value
+			return c, nil
+		case []interface{}:
+			idx, err := arrayIndex(tokens, len(tokens)-1, len(c), true)
+			if err != nil {
+				return nil, err
+			}
+			c = append(c, nil)
+			copy(c[idx+1:], c[idx:])
+			c[idx] = value
+			return c, nil
+		default:
+			return nil, patchError(ErrPatchFailed, pointer(tokens, len(tokens)-1), "cannot add to a %s", jsonType(container))
+		}
+	})
+}
+
+func replaceValue(doc interface{}, tokens []string, value interface{}) (interface{}, error) {
+	if _, err := getValue(doc, tokens); err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return value, nil
+	}
+
+	last := tokens[len(tokens)-1]
+	return change(doc, tokens, func(container interface{}) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			c[last] = value
+		case []interface{}:
+			idx, _ := arrayIndex(tokens, len(tokens)-1, len(c), false)
+			c[idx] = value
+		}
+		return container, nil
+	})
+}
+
+// removeValue returns the document without the value and the removed value
+func removeValue(doc interface{}, tokens []string) (interface{}, interface{}, error) {
+	if len(tokens) == 0 {
+		return nil, nil, patchError(ErrPatchFailed, "", "cannot remove the item data")
+	}
+
+	removed, err := getValue(doc, tokens)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	last := tokens[len(tokens)-1]
+	doc, err = change(doc, tokens, func(container interface{}) (interface{}, error) {
+		switch c := container.(type) {
+		case map[string]interface{}:
+			delete(c, last)
+			return c, nil
+		case []interface{}:
+			idx, _ := arrayIndex(tokens, len(tokens)-1, len(c), false)
+			return append(c[:idx], c[idx+1:]...), nil
+		}
+		return container, nil
+	})
+	return doc, removed, err
+}
+
+// jsonEqual compares two decoded JSON values, numbers by value and objects regardless of member order
+func jsonEqual(a, b interface{}) bool {
+	if reflect.DeepEqual(a, b) {
+		return true
+	}
+
+	ra, errA := json.Marshal(a)
+	rb, errB := json.Marshal(b)
+	if errA != nil || errB != nil {
+		return false
+	}
+	var na, nb interface{}
+	if json.Unmarshal(ra, &na) != nil || json.Unmarshal(rb, &nb) != nil {
+		return false
+	}
+	return reflect.DeepEqual(na, nb)
+}
+
+func jsonType(v interface{}) string {
+	switch v.(type) {
+	case nil:
+		return "null"
+	case bool:
+		return "boolean"
+	case string:
+		return "string"
+	case []interface{}:
+		return "array"
+	case map[string]interface{}:
+		return "object"
+	default:
+		return "number"
+	}
+}
@@ -0,0 +1,108 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestMergePatch(t *testing.T) {
+	data := map[string]interface{}{
+		"name": "pump",
+		"site": map[string]interface{}{"name": "north", "floor": 2.0},
+		"tags": []interface{}{"a", "b"},
+	}
+	patch := MergePatch{Value: map[string]interface{}{
+		"site": map[string]interface{}{"floor": nil, "room": "b12"},
+		"tags": []interface{}{"c"},
+		"name": nil,
+	}}
+
+	got, err := patch.Apply(data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"site": map[string]interface{}{"name": "north", "room": "b12"},
+		"tags": []interface{}{"c"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("merged data = %v, want %v", got, want)
+	}
+
+	if _, err := (MergePatch{Value: "text"}).Apply(data); !errors.Is(err, ErrInvalidPatch) {
+		t.Errorf("merging a string into item data: err = %v, want ErrInvalidPatch", err)
+	}
+}
+
+func TestJSONPatch(t *testing.T) {
+	var patch JSONPatch
+	err := json.Unmarshal([]byte(`[
+		{"op": "test", "path": "/site/name", "value": "north"},
+		{"op": "add", "path": "/tags/-", "value": "c"},
+		{"op": "replace", "path": "/site/name", "value": "south"},
+		{"op": "move", "from": "/name", "path": "/title"},
+		{"op": "copy", "from": "/tags/0", "path": "/first"},
+		{"op": "remove", "path": "/tags/1"},
+		{"op": "add", "path": "/note", "value": null}
+	]`), &patch)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := patch.Apply(map[string]interface{}{
+		"name": "pump",
+		"site": map[string]interface{}{"name": "north"},
+		"tags": []interface{}{"a", "b"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{
+		"title": "pump",
+		"first": "a",
+		"note":  nil,
+		"site":  map[string]interface{}{"name": "south"},
+		"tags":  []interface{}{"a", "c"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("patched data = %v, want %v", got, want)
+	}
+}
+
+func TestFailedPatchLeavesItem(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	data := map[string]interface{}{"name": "pump", "count": 1.0}
+	if err := CreateItem(Item{ID: "a", Data: data}, "test"); err != nil {
+		t.Fatal(err)
+	}
+
+	patch := JSONPatch{
+		{Op: "replace", Path: "/name", Value: json.RawMessage(`"valve"`)},
+		{Op: "test", Path: "/count", Value: json.RawMessage(`2`)},
+	}
+	_, err := ApplyPatch("", "a", patch, WriteOptions{})
+	var perr *PatchError
+	if !errors.Is(err, ErrPatchTestFailed) || !errors.As(err, &perr) || perr.Operation != 1 {
+		t.Fatalf("failing test operation: err = %v, want ErrPatchTestFailed at operation 1", err)
+	}
+
+	item, err := GetItem("", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(item.Data, data) || item.Version != 1 {
+		t.Errorf("item after a failed patch = %+v, want it unchanged", item)
+	}
+
+	patch[1].Value = json.RawMessage(`1`)
+	item, err = ApplyPatch("", "a", patch, WriteOptions{IfVersion: []int64{1}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Data["name"] != "valve" || item.Version != 2 {
+		t.Errorf("patched item = %+v, want name valve at version 2", item)
+	}
+}
@@ -0,0 +1,572 @@
+package database
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrInvalidQuery is returned for malformed filters, sort keys or cursors
+var ErrInvalidQuery = errors.New("invalid query")
+
+const (
+	DefaultLimit = 100
+	MaxLimit     = 1000
+)
+
+// Filter operators
+const (
+	OpEq       = "eq"
+	OpNe       = "ne"
+	OpGt       = "gt"
+	OpGte      = "gte"
+	OpLt       = "lt"
+	OpLte      = "lte"
+	OpContains = "contains"
+	OpExists   = "exists"
+	OpIn       = "in"
+)
+
+var operators = map[string]bool{
+	OpEq: true, OpNe: true, OpGt: true, OpGte: true, OpLt: true, OpLte: true,
+	OpContains: true, OpExists: true, OpIn: true,
+}
+
+// Filter tests one field of an item, e.g. data.severity gte 3.
+// Field is a dotted path, "id", "collection", "version", "expires_at" or "data.<key>[.<key>...]".
+type Filter struct {
+	Field string
+	Op    string
+	Value string
+}
+
+// SortKey orders items by a field, Desc for descending
+type SortKey struct {
+	Field string
+	Desc  bool
+}
+
+// Query selects, orders and pages items
+type Query struct {
+	Filters []Filter
+	Sort    []SortKey
+	Limit   int
+	Cursor  string
+}
+
+// Page is one page of query results. NextCursor is empty on the last page.
+type Page struct {
+	Items      []Item `json:"items"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// reservedParams are query string keys that are not filters
+var reservedParams = map[string]bool{"sort": true, "limit": true, "cursor": true}
+
+// ParseQuery builds a Query from URL parameters:
+//
+//	data.site=North              equality
+//	data.severity[gte]=3         operator, one of eq ne gt gte lt lte contains exists in
+//	data.tags[contains]=crane    substring for strings, element for arrays
+//	data.owner[exists]=true      presence of a field
+//	data.status[in]=open,review  one of a comma separated list
+//	sort=-data.severity,id       comma separated, "-" for descending
+//	limit=50&cursor=...          page size and the next_cursor of the previous page
+//
+// Keys listed in ignore are skipped, so callers can mix in their own parameters.
+func ParseQuery(values url.Values, ignore ...string) (Query, error) {
+	q := Query{Limit: DefaultLimit}
+
+	skip := map[string]bool{}
+	for _, k := range ignore {
+		skip[k] = true
+	}
+
+	if s := values.Get("sort"); s != "" {
+		for _, f := range strings.Split(s, ",") {
+			key := SortKey{Field: strings.TrimSpace(f)}
+			if strings.HasPrefix(key.Field, "-") {
+				key.Field, key.Desc = key.Field[1:], true
+			}
+			if err := validateField(key.Field); err != nil {
+				return q, err
+			}
+			q.Sort = append(q.Sort, key)
+		}
+	}
+
+	if s := values.Get("limit"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("%w: limit must be a positive integer", ErrInvalidQuery)
+		}
+		if n > MaxLimit {
+			n = MaxLimit
+		}
+		q.Limit = n
+	}
+	q.Cursor = values.Get("cursor")
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if reservedParams[key] || skip[key] {
+			continue
+		}
+
+		field, op := key, OpEq
+		if i := strings.Index(key, "["); i >= 0 && strings.HasSuffix(key, "]") {
+			field, op = key[:i], key[i+1:len(key)-1]
+		}
+		if !operators[op] {
+			return q, fmt.Errorf("%w: unknown operator %q", ErrInvalidQuery, op)
+		}
+		if err := validateField(field); err != nil {
+			return q, err
+		}
+
+		for _, v := range values[key] {
+			q.Filters = append(q.Filters, Filter{Field: field, Op: op, Value: v})
+		}
+	}
+
+	return q, nil
+}
+
+func validateField(field string) error {
+	if field == "id" || field == "collection" || field == "version" || field == "expires_at" || field == "data" || (strings.HasPrefix(field, "data.") && len(field) > len("data.")) {
+		return nil
+	}
+	return fmt.Errorf("%w: unknown field %q", ErrInvalidQuery, field)
+}
+
+// Find evaluates the query against the items of a collection, "" for
+// items outside any collection
+func Find(collection string, q Query) (Page, error) {
+	var page Page
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		items, err := tx.Query(q.Match)
+		if err != nil {
+			return err
+		}
+
+		page, err = q.Paginate(items)
+		return err
+	})
+
+	return page, err
+}
+
+// FindAll returns every item of a collection matching the query filters,
+// sorted like Find but without paging
+func FindAll(collection string, q Query) ([]Item, error) {
+	var items []Item
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		var err error
+		items, err = tx.Query(q.Match)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	q.SortItems(items)
+	return items, nil
+}
+
+// eachPageSize is the number of items EachItem reads per transaction
+const eachPageSize = 500
+
+// EachItem calls fn with every item of a collection matching the query
+// filters, stopping at the first error. Without sort keys the items are
+// read in ID order a page at a time, each page in its own transaction, so
+// writes are not held up while fn runs and an item changed meanwhile is
+// seen either before or after the change. Sorted queries read all matches
+// first, like FindAll.
+func EachItem(collection string, q Query, fn func(Item) error) error {
+	if len(q.Sort) > 0 {
+		items, err := FindAll(collection, q)
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	after := ""
+	for {
+		var page []Item
+		err := View(func(tx *Tx) error {
+			tx = tx.In(collection)
+			if err := tx.requireCollection(); err != nil {
+				return err
+			}
+
+			var err error
+			page, err = tx.After(after, eachPageSize)
+			return err
+		})
+		if err != nil {
+			return err
+		}
+		if len(page) == 0 {
+			return nil
+		}
+
+		for _, item := range page {
+			if !q.Match(item) {
+				continue
+			}
+			if err := fn(item); err != nil {
+				return err
+			}
+		}
+		after = page[len(page)-1].ID
+	}
+}
+
+// Match reports whether the item passes every filter
+func (q Query) Match(item Item) bool {
+	for _, f := range q.Filters {
+		if !f.Match(item) {
+			return false
+		}
+	}
+	return true
+}
+
+// Match reports whether the item passes the filter
+func (f Filter) Match(item Item) bool {
+	v, ok := FieldValue(item, f.Field)
+
+	switch f.Op {
+	case OpExists:
+		want, err := strconv.ParseBool(f.Value)
+		if err != nil {
+			want = true
+		}
+		return ok == want
+	case OpNe:
+		return !ok || !valueEquals(v, f.Value)
+	}
+
+	if !ok {
+		return false
+	}
+
+	switch f.Op {
+	case OpEq:
+		return valueEquals(v, f.Value)
+	case OpIn:
+		for _, want := range strings.Split(f.Value, ",") {
+			if valueEquals(v, want) {
+				return true
+			}
+		}
+		return false
+	case OpContains:
+		switch v := v.(type) {
+		case string:
+			return strings.Contains(strings.ToLower(v), strings.ToLower(f.Value))
+		case []interface{}:
+			for _, e := range v {
+				if valueEquals(e, f.Value) {
+					return true
+				}
+			}
+		}
+		return false
+	case OpGt, OpGte, OpLt, OpLte:
+		c, ok := compareToString(v, f.Value)
+		if !ok {
+			return false
+		}
+		switch f.Op {
+		case OpGt:
+			return c > 0
+		case OpGte:
+			return c >= 0
+		case OpLt:
+			return c < 0
+		default:
+			return c <= 0
+		}
+	}
+
+	return false
+}
+
+// FieldValue resolves a dotted field path on an item. Numeric segments
+// index into arrays.
+func FieldValue(item Item, field string) (interface{}, bool) {
+	if field == "id" {
+		return item.ID, true
+	}
+	if field == "collection" {
+		return item.Collection, item.Collection != ""
+	}
+	if field == "version" {
+		return float64(item.Version), true
+	}
+	if field == "expires_at" {
+		if item.ExpiresAt == nil {
+			return nil, false
+		}
+		return item.ExpiresAt.UTC().Format(time.RFC3339Nano), true
+	}
+	if field == "data" {
+		return item.Data, item.Data != nil
+	}
+	if !strings.HasPrefix(field, "data.") {
+		return nil, false
+	}
+
+	var cur interface{} = item.Data
+	for _, seg := range strings.Split(field[len("data."):], ".") {
+		switch c := cur.(type) {
+		case map[string]interface{}:
+			v, ok := c[seg]
+			if !ok {
+				return nil, false
+			}
+			cur = v
+		case []interface{}:
+			i, err := strconv.Atoi(seg)
+			if err != nil || i < 0 || i >= len(c) {
+				return nil, false
+			}
+			cur = c[i]
+		default:
+			return nil, false
+		}
+	}
+	return cur, true
+}
+
+// valueEquals compares a stored JSON value with a query string value
+func valueEquals(v interface{}, s string) bool {
+	switch v := v.(type) {
+	case nil:
+		return s == "null"
+	case bool:
+		b, err := strconv.ParseBool(s)
+		return err == nil && b == v
+	case float64:
+		n, err := strconv.ParseFloat(s, 64)
+		return err == nil && n == v
+	case string:
+		return v == s
+	default:
+		return false
+	}
+}
+
+// compareToString orders a stored value against a query string value.
+// Numbers compare numerically, strings lexically (which also orders
+// RFC 3339 timestamps), other types are not ordered.
+func compareToString(v interface{}, s string) (int, bool) {
+	switch v := v.(type) {
+	case float64:
+		n, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			return 0, false
+		}
+		return compareValues(v, n), true
+	case string:
+		return strings.Compare(v, s), true
+	default:
+		return 0, false
+	}
+}
+
+// typeRank orders values of different JSON types: missing, null, bool, number, string, others
+func typeRank(v interface{}, ok bool) int {
+	if !ok {
+		return 0
+	}
+	switch v.(type) {
+	case nil:
+		return 1
+	case bool:
+		return 2
+	case float64:
+		return 3
+	case string:
+		return 4
+	default:
+		return 5
+	}
+}
+
+// compareValues orders two stored JSON values
+func compareValues(a, b interface{}) int {
+	return compareFields(a, true, b, true)
+}
+
+func compareFields(a interface{}, aok bool, b interface{}, bok bool) int {
+	ra, rb := typeRank(a, aok), typeRank(b, bok)
+	if ra != rb {
+		if ra < rb {
+			return -1
+		}
+		return 1
+	}
+
+	switch a := a.(type) {
+	case bool:
+		b := b.(bool)
+		if a == b {
+			return 0
+		}
+		if !a {
+			return -1
+		}
+		return 1
+	case float64:
+		b := b.(float64)
+		if a < b {
+			return -1
+		}
+		if a > b {
+			return 1
+		}
+		return 0
+	case string:
+		return strings.Compare(a, b.(string))
+	}
+	return 0
+}
+
+// cursor marks the last item of a page by its sort values and ID
+type cursor struct {
+	Sort   string        `json:"s"`
+	Values []interface{} `json:"v"`
+	Has    []bool        `json:"h"`
+	ID     string        `json:"id"`
+}
+
+func (q Query) sortSpec() string {
+	parts := make([]string, len(q.Sort))
+	for i, k := range q.Sort {
+		if k.Desc {
+			parts[i] = "-" + k.Field
+		} else {
+			parts[i] = k.Field
+		}
+	}
+	return strings.Join(parts, ",")
+}
+
+// compareKeys orders an item against a sort position, ties are broken by ID
+func (q Query) compareKeys(item Item, values []interface{}, has []bool, id string) int {
+	for i, k := range q.Sort {
+		v, ok := FieldValue(item, k.Field)
+		c := compareFields(v, ok, values[i], has[i])
+		if k.Desc {
+			c = -c
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return strings.Compare(item.ID, id)
+}
+
+func (q Query) sortValues(item Item) ([]interface{}, []bool) {
+	values := make([]interface{}, len(q.Sort))
+	has := make([]bool, len(q.Sort))
+	for i, k := range q.Sort {
+		values[i], has[i] = FieldValue(item, k.Field)
+	}
+	return values, has
+}
+
+// SortItems orders items by the sort keys and then by ID
+func (q Query) SortItems(items []Item) {
+	sort.SliceStable(items, func(i, j int) bool {
+		values, has := q.sortValues(items[j])
+		return q.compareKeys(items[i], values, has, items[j].ID) < 0
+	})
+}
+
+// Paginate sorts already filtered items and cuts out the page after q.Cursor.
+// Items are ordered by the sort keys and then by ID.
+func (q Query) Paginate(items []Item) (Page, error) {
+	limit := q.Limit
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+
+	q.SortItems(items)
+
+	start, err := q.cursorStart(items)
+	if err != nil {
+		return Page{}, err
+	}
+
+	end := start + limit
+	if end >= len(items) {
+		return Page{Items: items[start:]}, nil
+	}
+
+	page := Page{Items: items[start:end]}
+	last := items[end-1]
+	c := cursor{Sort: q.sortSpec(), ID: last.ID}
+	c.Values, c.Has = q.sortValues(last)
+	raw, err := json.Marshal(c)
+	if err != nil {
+		return Page{}, err
+	}
+	page.NextCursor = base64.RawURLEncoding.EncodeToString(raw)
+
+	return page, nil
+}
+
+// cursorStart returns the index of the first item after the cursor
+func (q Query) cursorStart(items []Item) (int, error) {
+	if q.Cursor == "" {
+		return 0, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(q.Cursor)
+	if err != nil {
+		return 0, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	var c cursor
+	if err := json.Unmarshal(raw, &c); err != nil {
+		return 0, fmt.Errorf("%w: malformed cursor", ErrInvalidQuery)
+	}
+	if c.Sort != q.sortSpec() || len(c.Values) != len(q.Sort) || len(c.Has) != len(q.Sort) {
+		return 0, fmt.Errorf("%w: cursor does not match sort", ErrInvalidQuery)
+	}
+
+	return sort.Search(len(items), func(i int) bool {
+		return q.compareKeys(items[i], c.Values, c.Has, c.ID) > 0
+	}), nil
+}
@@ -0,0 +1,229 @@
+package database
+
+import (
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"testing"
+)
+
+func parseQuery(t *testing.T, raw string) Query {
+	t.Helper()
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	q, err := ParseQuery(values)
+	if err != nil {
+		t.Fatalf("ParseQuery(%q): %v", raw, err)
+	}
+	return q
+}
+
+func itemIDs(items []Item) []string {
+	ids := []string{}
+	for _, item := range items {
+		ids = append(ids, item.ID)
+	}
+	return ids
+}
+
+func createQueryItems(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+	createItems(t,
+		Item{ID: "a", Data: map[string]interface{}{"site": "North", "severity": 3.0, "tags": []interface{}{"crane", "pump"}, "owner": "x", "status": "open", "loc": map[string]interface{}{"floor": 2.0}}},
+		Item{ID: "b", Data: map[string]interface{}{"site": "south", "severity": 1.0, "tags": []interface{}{"pump"}, "owner": nil, "status": "review"}},
+		Item{ID: "c", Data: map[string]interface{}{"site": "North Yard", "severity": 5.0, "status": "closed"}},
+		Item{ID: "d", Data: map[string]interface{}{"name": "tank", "list": []interface{}{map[string]interface{}{"n": 1.0}}}},
+	)
+}
+
+func TestQueryFilters(t *testing.T) {
+	createQueryItems(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"", []string{"a", "b", "c", "d"}},
+		{"data.site=North", []string{"a"}},
+		{"data.site[eq]=north", []string{}},
+		{"data.site[ne]=North", []string{"b", "c", "d"}},
+		{"data.severity=3", []string{"a"}},
+		{"data.severity[gt]=1", []string{"a", "c"}},
+		{"data.severity[gte]=3", []string{"a", "c"}},
+		{"data.severity[lt]=3", []string{"b"}},
+		{"data.severity[lte]=3", []string{"a", "b"}},
+		{"data.severity[gte]=2&data.severity[lte]=4", []string{"a"}},
+		{"data.site[gt]=O", []string{"b"}},
+		{"data.site[contains]=north", []string{"a", "c"}},
+		{"data.tags[contains]=crane", []string{"a"}},
+		{"data.owner[exists]=true", []string{"a", "b"}},
+		{"data.owner[exists]=false", []string{"c", "d"}},
+		{"data.owner=null", []string{"b"}},
+		{"data.status[in]=open,review", []string{"a", "b"}},
+		{"data.loc.floor=2", []string{"a"}},
+		{"data.list.0.n=1", []string{"d"}},
+		{"data.tags.1=pump", []string{"a"}},
+		{"id[in]=a,d", []string{"a", "d"}},
+		{"version=1", []string{"a", "b", "c", "d"}},
+	}
+	for _, tt := range tests {
+		page, err := Find("", parseQuery(t, tt.query))
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := itemIDs(page.Items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestQuerySort(t *testing.T) {
+	createQueryItems(t)
+
+	tests := []struct {
+		query string
+		want  []string
+	}{
+		{"sort=-data.severity", []string{"c", "a", "b", "d"}},
+		{"sort=data.severity", []string{"d", "b", "a", "c"}},
+		{"sort=data.site", []string{"d", "a", "c", "b"}},
+		{"sort=data.owner,-id", []string{"d", "c", "b", "a"}},
+	}
+	for _, tt := range tests {
+		page, err := Find("", parseQuery(t, tt.query))
+		if err != nil {
+			t.Errorf("%s: %v", tt.query, err)
+			continue
+		}
+		if got := itemIDs(page.Items); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s = %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestParseQueryRejects(t *testing.T) {
+	tests := []string{
+		"data.site[like]=x",
+		"data.site[]=x",
+		"name=x",
+		"data.=x",
+		"sort=name",
+		"sort=-data.",
+		"limit=0",
+		"limit=ten",
+	}
+	for _, raw := range tests {
+		values, _ := url.ParseQuery(raw)
+		if _, err := ParseQuery(values); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("ParseQuery(%q): err = %v, want ErrInvalidQuery", raw, err)
+		}
+	}
+
+	values, _ := url.ParseQuery("format=csv&limit=5000")
+	q, err := ParseQuery(values, "format")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(q.Filters) != 0 || q.Limit != MaxLimit {
+		t.Errorf("query = %+v, want no filters and the limit capped at %d", q, MaxLimit)
+	}
+}
+
+func TestQueryCursor(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+	var want []string
+	for i := 0; i < 10; i++ {
+		createItems(t, Item{ID: fmt.Sprintf("i%d", i), Data: map[string]interface{}{"n": float64(i / 3)}})
+	}
+	for _, n := range []int{3, 2, 1, 0} {
+		for i := n * 3; i < n*3+3 && i < 10; i++ {
+			want = append(want, fmt.Sprintf("i%d", i))
+		}
+	}
+
+	q := parseQuery(t, "sort=-data.n&limit=4")
+	var got []string
+	pages := 0
+	for {
+		page, err := Find("", q)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got = append(got, itemIDs(page.Items)...)
+		pages++
+		if page.NextCursor == "" {
+			break
+		}
+		q.Cursor = page.NextCursor
+	}
+	if !reflect.DeepEqual(got, want) || pages != 3 {
+		t.Errorf("%d pages of %v, want 3 pages of %v", pages, got, want)
+	}
+
+	page, err := Find("", parseQuery(t, "sort=-data.n&limit=4"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	bad := []string{
+		"!!!",
+		base64.RawURLEncoding.EncodeToString([]byte("not json")),
+		page.NextCursor, // for another sort
+	}
+	for _, c := range bad {
+		q := parseQuery(t, "sort=data.n&limit=4")
+		q.Cursor = c
+		if _, err := Find("", q); !errors.Is(err, ErrInvalidQuery) {
+			t.Errorf("cursor %q: err = %v, want ErrInvalidQuery", c, err)
+		}
+	}
+}
+
+func TestEachItem(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+	err := Update(func(tx *Tx) error {
+		for i := 0; i < 2*eachPageSize+10; i++ {
+			if err := tx.Insert(Item{ID: fmt.Sprintf("i%04d", i), Data: map[string]interface{}{"even": i%2 == 0}}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var ids []string
+	err = EachItem("", parseQuery(t, "data.even=true"), func(item Item) error {
+		ids = append(ids, item.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(ids) != eachPageSize+5 || ids[0] != "i0000" || ids[len(ids)-1] != fmt.Sprintf("i%04d", 2*eachPageSize+8) {
+		t.Errorf("EachItem returned %d items from %s to %s", len(ids), ids[0], ids[len(ids)-1])
+	}
+	for i := 1; i < len(ids); i++ {
+		if ids[i] <= ids[i-1] {
+			t.Fatalf("EachItem returned %s after %s", ids[i], ids[i-1])
+		}
+	}
+
+	stop := errors.New("stop")
+	n := 0
+	err = EachItem("", Query{}, func(Item) error {
+		n++
+		if n == 3 {
+			return stop
+		}
+		return nil
+	})
+	if err != stop || n != 3 {
+		t.Errorf("EachItem went on to item %d after an error, err = %v", n, err)
+	}
+}
@@ -0,0 +1,469 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// referencesNamespace holds every reference under the key of its source,
+// "out/<collection>/<id>/<reference ID>", and references to items also
+// under the key of their target, "in/<collection>/<id>/<reference ID>",
+// so the references from and to an item are found by key prefix
+const referencesNamespace = "_references"
+
+// What happens to a reference, and the item holding it, when its target item is deleted
+const (
+	OnDeleteRestrict = "restrict" // the target cannot be deleted
+	OnDeleteCascade  = "cascade"  // the referencing item is deleted too
+	OnDeleteNullify  = "nullify"  // the reference is removed
+)
+
+// Directions of references seen from an item
+const (
+	Outgoing = "out"
+	Incoming = "in"
+)
+
+var (
+	// ErrInvalidReference is returned for references with a bad type, target or on_delete
+	ErrInvalidReference = errors.New("invalid reference")
+	// ErrReferenceExists is returned when adding the same reference twice
+	ErrReferenceExists = errors.New("reference already exists")
+	// ErrReferenceNotFound is returned for unknown reference IDs
+	ErrReferenceNotFound = errors.New("reference not found")
+)
+
+var referenceType = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,63}$`)
+
+// Reference is a typed link from an item to another item or to an image
+type Reference struct {
+	ID        string    `json:"id"`
+	Type      string    `json:"type" example:"located_at"`
+	From      Target    `json:"from"`
+	To        Target    `json:"to"`
+	OnDelete  string    `json:"on_delete" example:"restrict"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Target is an item, given by collection and ID, or an image file name
+type Target struct {
+	Collection string `json:"collection,omitempty"`
+	ID         string `json:"id,omitempty"`
+	Image      string `json:"image,omitempty"`
+}
+
+// Related is a reference together with the item at its other end,
+// Item is nil for images
+type Related struct {
+	Reference
+	Item *Item `json:"item,omitempty"`
+}
+
+// ReferencedError is returned when deleting an item that restricting references point to
+type ReferencedError struct {
+	References []Reference
+}
+
+func (e *ReferencedError) Error() string {
+	return fmt.Sprintf("item is referenced by %d restricting references", len(e.References))
+}
+
+// is reports whether the target is the item id of collection
+func (t Target) is(collection, id string) bool {
+	return t.Image == "" && t.Collection == collection && t.ID == id
+}
+
+// AddReference stores a reference from an item of this namespace. Item
+// targets must exist, image targets are checked by the caller.
+func (tx *Tx) AddReference(r Reference) error {
+	if !referenceType.MatchString(r.Type) {
+		return fmt.Errorf("%w: type must match %s", ErrInvalidReference, referenceType)
+	}
+	switch r.OnDelete {
+	case "":
+		r.OnDelete = OnDeleteRestrict
+	case OnDeleteRestrict, OnDeleteCascade, OnDeleteNullify:
+	default:
+		return fmt.Errorf("%w: on_delete must be restrict, cascade or nullify", ErrInvalidReference)
+	}
+	if (r.To.ID == "") == (r.To.Image == "") {
+		return fmt.Errorf("%w: the target must be either an item ID or an image", ErrInvalidReference)
+	}
+	if r.To.Image != "" && (r.To.Collection != "" || r.OnDelete == OnDeleteCascade) {
+		return fmt.Errorf("%w: image targets have no collection and cannot cascade", ErrInvalidReference)
+	}
+
+	if _, found, err := tx.Get(r.From.ID); err != nil || !found {
+		if err == nil {
+			err = ErrNotFound
+		}
+		return err
+	}
+	r.From.Collection = tx.collectionName()
+
+	if r.To.ID != "" {
+		target := tx.In(r.To.Collection)
+		if err := target.requireCollection(); err != nil {
+			return err
+		}
+		_, found, err := target.Get(r.To.ID)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return fmt.Errorf("%w: target item %q does not exist", ErrInvalidReference, r.To.ID)
+		}
+	}
+
+	existing, err := tx.referencesOf(Outgoing, r.From, func(e Reference) bool {
+		return e.Type == r.Type && e.To == r.To
+	})
+	if err != nil {
+		return err
+	}
+	if len(existing) > 0 {
+		return ErrReferenceExists
+	}
+
+	raw, err := toData(r)
+	if err != nil {
+		return err
+	}
+	refs := tx.In(referencesNamespace)
+	if err := refs.Insert(Item{ID: referenceKey(Outgoing, r.From, r.ID), Data: raw}); err != nil {
+		return err
+	}
+	if r.To.Image != "" {
+		return nil
+	}
+	return refs.Insert(Item{ID: referenceKey(Incoming, r.To, r.ID), Data: raw})
+}
+
+// References returns the references from (Outgoing) or to (Incoming) an
+// item of this namespace, optionally of one type, oldest first
+func (tx *Tx) References(id, typ, direction string) ([]Reference, error) {
+	if direction != Incoming {
+		direction = Outgoing
+	}
+	return tx.referencesOf(direction, Target{Collection: tx.collectionName(), ID: id}, func(r Reference) bool {
+		return typ == "" || r.Type == typ
+	})
+}
+
+// RemoveReference deletes a reference from an item of this namespace
+func (tx *Tx) RemoveReference(id, refID string) error {
+	from := Target{Collection: tx.collectionName(), ID: id}
+	item, found, err := tx.In(referencesNamespace).Get(referenceKey(Outgoing, from, refID))
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrReferenceNotFound
+	}
+
+	var r Reference
+	if err := fromData(item.Data, &r); err != nil {
+		return err
+	}
+	return tx.deleteReferences([]Reference{r})
+}
+
+// referencesOf returns the references from (Outgoing) or to (Incoming) an
+// item for which match returns true, oldest first
+func (tx *Tx) referencesOf(direction string, t Target, match func(Reference) bool) ([]Reference, error) {
+	return tx.referencesWithPrefix(referencePrefix(direction, t.Collection, t.ID), func(r Reference) bool {
+		end := r.From
+		if direction == Incoming {
+			end = r.To
+		}
+		return end.is(t.Collection, t.ID) && match(r)
+	})
+}
+
+// referencesWithPrefix decodes the references stored under a key prefix,
+// once each and oldest first
+func (tx *Tx) referencesWithPrefix(prefix string, match func(Reference) bool) ([]Reference, error) {
+	items, err := tx.In(referencesNamespace).Prefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	var refs []Reference
+	seen := map[string]bool{}
+	for _, item := range items {
+		var r Reference
+		if err := fromData(item.Data, &r); err != nil {
+			return nil, err
+		}
+		if !seen[r.ID] && match(r) {
+			seen[r.ID] = true
+			refs = append(refs, r)
+		}
+	}
+	sort.Slice(refs, func(i, j int) bool {
+		return refs[i].CreatedAt.Before(refs[j].CreatedAt)
+	})
+	return refs, nil
+}
+
+// referencePrefix is the start of the keys of the references from
+// (Outgoing) or to (Incoming) an item
+func referencePrefix(direction, collection, id string) string {
+	return direction + "/" + collection + "/" + id + "/"
+}
+
+func referenceKey(direction string, t Target, refID string) string {
+	return referencePrefix(direction, t.Collection, t.ID) + refID
+}
+
+// releaseReferences applies the on_delete rules of the references to an
+// item of this namespace that is about to be deleted. It returns the items
+// to delete along with it, by namespace, or a ReferencedError before
+// changing anything if a restricting reference from a live item remains.
+func (tx *Tx) releaseReferences(id string) (map[string][]string, error) {
+	type node struct{ collection, id string }
+	deleting := map[node]bool{{tx.collectionName(), id}: true}
+	queue := []node{{tx.collectionName(), id}}
+	var restricted, nullified []Reference
+	cascade := map[string][]string{}
+
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+
+		incoming, err := tx.referencesOf(Incoming, Target{Collection: n.collection, ID: n.id}, func(Reference) bool { return true })
+		if err != nil {
+			return nil, err
+		}
+		for _, r := range incoming {
+			if r.OnDelete == OnDeleteNullify {
+				nullified = append(nullified, r)
+				continue
+			}
+
+			src := node{r.From.Collection, r.From.ID}
+			if deleting[src] {
+				continue
+			}
+			// References held by deleted items are kept for an undelete but do not count
+			if _, live, err := tx.In(src.collection).Get(src.id); err != nil {
+				return nil, err
+			} else if !live {
+				continue
+			}
+
+			if r.OnDelete == OnDeleteRestrict {
+				restricted = append(restricted, r)
+				continue
+			}
+			deleting[src] = true
+			queue = append(queue, src)
+			cascade[src.collection] = append(cascade[src.collection], src.id)
+		}
+	}
+
+	if len(restricted) > 0 {
+		return nil, &ReferencedError{References: restricted}
+	}
+
+	if err := tx.deleteReferences(nullified); err != nil {
+		return nil, err
+	}
+	return cascade, nil
+}
+
+// forgetReferences deletes every reference from or to an item of this
+// namespace that is gone for good
+func (tx *Tx) forgetReferences(id string) error {
+	t := Target{Collection: tx.collectionName(), ID: id}
+	all := func(Reference) bool { return true }
+	from, err := tx.referencesOf(Outgoing, t, all)
+	if err != nil {
+		return err
+	}
+	to, err := tx.referencesOf(Incoming, t, all)
+	if err != nil {
+		return err
+	}
+	return tx.deleteReferences(append(from, to...))
+}
+
+// dropReferences deletes every reference from or to an item of a collection
+func (tx *Tx) dropReferences(collection string) error {
+	all := func(Reference) bool { return true }
+	from, err := tx.referencesWithPrefix(Outgoing+"/"+collection+"/", all)
+	if err != nil {
+		return err
+	}
+	to, err := tx.referencesWithPrefix(Incoming+"/"+collection+"/", all)
+	if err != nil {
+		return err
+	}
+	return tx.deleteReferences(append(from, to...))
+}
+
+// deleteReferences removes references under both of their keys, references
+// listed twice or already removed are skipped
+func (tx *Tx) deleteReferences(refs []Reference) error {
+	store := tx.In(referencesNamespace)
+	for _, r := range refs {
+		keys := []string{referenceKey(Outgoing, r.From, r.ID)}
+		if r.To.Image == "" {
+			keys = append(keys, referenceKey(Incoming, r.To, r.ID))
+		}
+		for _, key := range keys {
+			if err := store.Delete(key); err != nil && err != ErrNotFound {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// related resolves the item at the other end of each reference, skipping
+// items that are deleted
+func (tx *Tx) related(refs []Reference, direction string) ([]Related, error) {
+	result := make([]Related, 0, len(refs))
+	for _, r := range refs {
+		end := r.To
+		if direction == Incoming {
+			end = r.From
+		}
+		if end.Image != "" {
+			result = append(result, Related{Reference: r})
+			continue
+		}
+
+		item, found, err := tx.In(end.Collection).Get(end.ID)
+		if err != nil {
+			return nil, err
+		}
+		if found {
+			result = append(result, Related{Reference: r, Item: &item})
+		}
+	}
+	return result, nil
+}
+
+// AddReference stores a reference from an item of a collection
+func AddReference(collection string, r Reference) error {
+	return Update(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+		return tx.AddReference(r)
+	})
+}
+
+// RemoveReference deletes a reference from an item of a collection
+func RemoveReference(collection, id, refID string) error {
+	return Update(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+		return tx.RemoveReference(id, refID)
+	})
+}
+
+// ListReferences returns the references from or to an item, see Tx.References
+func ListReferences(collection, id, typ, direction string) ([]Reference, error) {
+	var refs []Reference
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+		if _, found, err := tx.Get(id); err != nil || !found {
+			if err == nil {
+				err = ErrNotFound
+			}
+			return err
+		}
+
+		var err error
+		refs, err = tx.References(id, typ, direction)
+		return err
+	})
+
+	if refs == nil {
+		refs = []Reference{}
+	}
+	return refs, err
+}
+
+// GetRelated returns the items an item references (Outgoing) or the items
+// referencing it (Incoming), optionally only through references of one type
+func GetRelated(collection, id, typ, direction string) ([]Related, error) {
+	var related []Related
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+		if _, found, err := tx.Get(id); err != nil || !found {
+			if err == nil {
+				err = ErrNotFound
+			}
+			return err
+		}
+
+		refs, err := tx.References(id, typ, direction)
+		if err != nil {
+			return err
+		}
+		related, err = tx.related(refs, direction)
+		return err
+	})
+
+	return related, err
+}
+
+// Expand returns the targets of the outgoing references of an item by
+// type, for the given types or all types if types contains "*"
+func Expand(collection, id string, types []string) (map[string][]Related, error) {
+	expanded := map[string][]Related{}
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		refs, err := tx.References(id, "", Outgoing)
+		if err != nil {
+			return err
+		}
+
+		all := false
+		wanted := map[string]bool{}
+		for _, t := range types {
+			t = strings.TrimSpace(t)
+			all = all || t == "*"
+			wanted[t] = true
+			if t != "*" && t != "" {
+				expanded[t] = []Related{}
+			}
+		}
+
+		var selected []Reference
+		for _, r := range refs {
+			if all || wanted[r.Type] {
+				selected = append(selected, r)
+			}
+		}
+		related, err := tx.related(selected, Outgoing)
+		if err != nil {
+			return err
+		}
+		for _, r := range related {
+			expanded[r.Type] = append(expanded[r.Type], r)
+		}
+		return nil
+	})
+
+	return expanded, err
+}
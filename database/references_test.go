@@ -0,0 +1,201 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+)
+
+// refer adds a reference with on_delete rule from item from to item to,
+// both given as "collection/id"
+func refer(t *testing.T, from, to, onDelete string) Reference {
+	t.Helper()
+	fc, fid := splitKey(from)
+	tc, tid := splitKey(to)
+
+	r := Reference{
+		ID:        fmt.Sprintf("r-%s-%s", fid, tid),
+		Type:      "part_of",
+		From:      Target{ID: fid},
+		To:        Target{Collection: tc, ID: tid},
+		OnDelete:  onDelete,
+		CreatedAt: time.Now(),
+	}
+	if err := AddReference(fc, r); err != nil {
+		t.Fatalf("adding %s -> %s: %v", from, to, err)
+	}
+	return r
+}
+
+// splitKey splits "collection/id" keys, the default collection is empty
+func splitKey(key string) (string, string) {
+	i := strings.LastIndex(key, "/")
+	return key[:i], key[i+1:]
+}
+
+// createGraph creates the items "/a" to "/e" and "parts/p" and "parts/q"
+func createGraph(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+	if err := CreateCollection(Collection{Name: "parts"}); err != nil {
+		t.Fatal(err)
+	}
+	for _, id := range []string{"a", "b", "c", "d", "e"} {
+		createItems(t, Item{ID: id, Data: map[string]interface{}{}})
+	}
+	for _, id := range []string{"p", "q"} {
+		createItems(t, Item{ID: id, Collection: "parts", Data: map[string]interface{}{}})
+	}
+}
+
+// live returns which of the items, given as "collection/id", still exist
+func live(t *testing.T, keys ...string) []string {
+	t.Helper()
+	found := []string{}
+	for _, key := range keys {
+		c, id := splitKey(key)
+		_, err := GetItem(c, id)
+		if err == nil {
+			found = append(found, key)
+		} else if err != ErrNotFound {
+			t.Fatal(err)
+		}
+	}
+	return found
+}
+
+func trashed(t *testing.T, collection string) []string {
+	t.Helper()
+	entries, err := ListTrash(collection)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := []string{}
+	for _, e := range entries {
+		ids = append(ids, e.Item.ID)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func TestOnDeleteRestrict(t *testing.T) {
+	createGraph(t)
+	r := refer(t, "parts/p", "/a", OnDeleteRestrict)
+	refer(t, "/b", "/a", "") // restrict by default
+
+	err := DeleteItem("", "a", WriteOptions{})
+	var rerr *ReferencedError
+	if !errors.As(err, &rerr) || len(rerr.References) != 2 || rerr.References[0].ID != r.ID {
+		t.Fatalf("deleting a restricted item: err = %v, want a ReferencedError naming both references", err)
+	}
+	if got := live(t, "/a"); len(got) != 1 {
+		t.Fatal("the restricted item was deleted")
+	}
+
+	// references held by deleted items do not restrict
+	if err := DeleteItem("parts", "p", WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := RemoveReference("", "b", "r-b-a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := DeleteItem("", "a", WriteOptions{}); err != nil {
+		t.Errorf("deleting an item whose referrers are gone: %v", err)
+	}
+}
+
+func TestOnDeleteCascade(t *testing.T) {
+	createGraph(t)
+	refer(t, "/b", "/a", OnDeleteCascade)
+	refer(t, "parts/p", "/b", OnDeleteCascade)
+	refer(t, "/c", "parts/p", OnDeleteCascade)
+	refer(t, "/a", "/c", OnDeleteCascade) // a cycle back to a
+	refer(t, "/d", "/e", OnDeleteCascade)
+
+	if err := DeleteItem("", "a", WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := live(t, "/a", "/b", "/c", "/d", "/e", "parts/p", "parts/q"), []string{"/d", "/e", "parts/q"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("live items after the cascade = %v, want %v", got, want)
+	}
+	if got, want := trashed(t, ""), []string{"a", "b", "c"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("trash = %v, want %v", got, want)
+	}
+	if got, want := trashed(t, "parts"), []string{"p"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("parts trash = %v, want %v", got, want)
+	}
+
+	// the references stay for an undelete
+	if _, err := UndeleteItem("", "b", "test"); err != nil {
+		t.Fatal(err)
+	}
+	refs, err := ListReferences("", "b", "", Outgoing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].To.ID != "a" {
+		t.Errorf("references of the undeleted item = %+v, want the one to a", refs)
+	}
+}
+
+func TestOnDeleteNullify(t *testing.T) {
+	createGraph(t)
+	refer(t, "/b", "/a", OnDeleteNullify)
+	refer(t, "parts/p", "/a", OnDeleteNullify)
+	kept := refer(t, "/b", "/c", OnDeleteNullify)
+
+	if err := DeleteItem("", "a", WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if got := live(t, "/b", "parts/p"); len(got) != 2 {
+		t.Errorf("live referrers = %v, want both", got)
+	}
+	refs, err := ListReferences("", "b", "", Outgoing)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 1 || refs[0].ID != kept.ID {
+		t.Errorf("references of b = %+v, want only the one to c", refs)
+	}
+	if refs, err := ListReferences("parts", "p", "", Outgoing); err != nil || len(refs) != 0 {
+		t.Errorf("references of p = %+v, %v, want none", refs, err)
+	}
+}
+
+func TestRestrictRollsBackDelete(t *testing.T) {
+	createGraph(t)
+	refer(t, "/b", "/a", OnDeleteCascade)
+	refer(t, "/c", "/b", OnDeleteCascade)
+	refer(t, "/d", "/a", OnDeleteNullify)
+	restrict := refer(t, "parts/p", "/c", OnDeleteRestrict)
+
+	err := DeleteItem("", "a", WriteOptions{})
+	var rerr *ReferencedError
+	if !errors.As(err, &rerr) || len(rerr.References) != 1 || rerr.References[0].ID != restrict.ID {
+		t.Fatalf("deleting into a restricted cascade: err = %v, want a ReferencedError for %s", err, restrict.ID)
+	}
+
+	if got, want := live(t, "/a", "/b", "/c", "/d", "parts/p"), []string{"/a", "/b", "/c", "/d", "parts/p"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("live items = %v, want %v", got, want)
+	}
+	if got := trashed(t, ""); len(got) != 0 {
+		t.Errorf("trash = %v, want it empty", got)
+	}
+	refs, err := ListReferences("", "a", "", Incoming)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(refs) != 2 {
+		t.Errorf("references to a = %+v, want the cascade and the nullify one", refs)
+	}
+	revisions, err := ListRevisions("", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 1 {
+		t.Errorf("%d revisions of a, want only its creation", len(revisions))
+	}
+}
@@ -0,0 +1,405 @@
+package database
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+)
+
+// revisionsNamespace holds the latest revisions of every item, keyed by
+// "<collection>/<id>@<rev>", plus one head entry per item keyed by
+// "<collection>/<id>" that remembers the latest revision number and the
+// latest version of the item.
+const revisionsNamespace = "_revisions"
+
+// DefaultRevisionLimit is how many revisions are kept per item unless configured otherwise
+const DefaultRevisionLimit = 100
+
+// revisionLimit caps the revisions kept per item, writing a revision
+// drops the ones that fall out of it
+var revisionLimit = DefaultRevisionLimit
+
+// ErrRevisionNotFound is returned for revision numbers an item never had
+var ErrRevisionNotFound = errors.New("revision not found")
+
+// Revision operations
+const (
+	RevCreate  = "create"
+	RevUpdate  = "update"
+	RevDelete  = "delete"
+	RevRestore = "restore"
+)
+
+// Revision is the data of an item as written by one change.
+// Delete revisions carry the data the item had when it was deleted.
+type Revision struct {
+	ItemID     string                 `json:"item_id"`
+	Collection string                 `json:"collection,omitempty"`
+	Rev        int                    `json:"rev"`
+	Op         string                 `json:"op"`
+	Data       map[string]interface{} `json:"data"`
+	Author     string                 `json:"author,omitempty"`
+	Timestamp  time.Time              `json:"timestamp"`
+}
+
+// Change is one difference between two revisions. Path is a JSON pointer
+// into the item data, Op is "add", "remove" or "replace".
+type Change struct {
+	Path string      `json:"path"`
+	Op   string      `json:"op"`
+	From interface{} `json:"from,omitempty"`
+	To   interface{} `json:"to,omitempty"`
+}
+
+// SetRevisionLimit sets how many revisions are kept per item, at least one
+func SetRevisionLimit(n int) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	if n < 1 {
+		n = 1
+	}
+	revisionLimit = n
+}
+
+// SetAuthor names who makes the changes of this transaction, it is recorded in revisions
+func (tx *Tx) SetAuthor(author string) {
+	tx.author = author
+}
+
+// Revisions returns the kept revisions of an item, oldest first
+func (tx *Tx) Revisions(id string) ([]Revision, error) {
+	items, err := tx.In(revisionsNamespace).Prefix(itemKey(tx.ns, id) + "@")
+	if err != nil {
+		return nil, err
+	}
+
+	revisions := make([]Revision, 0, len(items))
+	for _, item := range items {
+		rev, err := revisionFromItem(item)
+		if err != nil {
+			return nil, err
+		}
+		revisions = append(revisions, rev)
+	}
+	sort.Slice(revisions, func(i, j int) bool {
+		return revisions[i].Rev < revisions[j].Rev
+	})
+	return revisions, nil
+}
+
+// Revision returns one revision of an item
+func (tx *Tx) Revision(id string, rev int) (Revision, error) {
+	item, found, err := tx.In(revisionsNamespace).Get(revisionKey(itemKey(tx.ns, id), rev))
+	if err != nil {
+		return Revision{}, err
+	}
+	if !found {
+		return Revision{}, ErrRevisionNotFound
+	}
+	return revisionFromItem(item)
+}
+
+// recordRevision appends a revision for a change to an item of this namespace
+func (tx *Tx) recordRevision(op string, item Item) error {
+	if isReserved(tx.ns) {
+		return nil
+	}
+
+	history := tx.In(revisionsNamespace)
+	key := itemKey(tx.ns, item.ID)
+
+	head, found, err := history.Get(key)
+	if err != nil {
+		return err
+	}
+	rev := 1
+	if found {
+		if n, ok := head.Data["rev"].(float64); ok {
+			rev = int(n) + 1
+		}
+	}
+
+	r := Revision{
+		ItemID:     item.ID,
+		Collection: tx.collectionName(),
+		Rev:        rev,
+		Op:         op,
+		Data:       item.Data,
+		Author:     tx.author,
+		Timestamp:  time.Now().UTC(),
+	}
+	entry, err := r.item()
+	if err != nil {
+		return err
+	}
+
+	history.put(entry)
+	history.put(Item{ID: key, Data: map[string]interface{}{"rev": float64(rev), "version": float64(item.Version)}})
+	if err := tx.trimRevisions(key, rev); err != nil {
+		return err
+	}
+	tx.recordEvent(op, item, r.Timestamp)
+	return nil
+}
+
+// trimRevisions drops the revisions of an item before the newest revisionLimit
+func (tx *Tx) trimRevisions(key string, latest int) error {
+	history := tx.In(revisionsNamespace)
+	for rev := latest - revisionLimit; rev > 0; rev-- {
+		id := revisionKey(key, rev)
+		_, found, err := history.Get(id)
+		if err != nil || !found {
+			return err
+		}
+		if err := history.Delete(id); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// lastVersion is the latest version an item of this namespace had, by its
+// revision head or its trash entry, 0 for IDs that were never used
+func (tx *Tx) lastVersion(id string) (int64, error) {
+	if isReserved(tx.ns) {
+		return 0, nil
+	}
+
+	var last int64
+	head, found, err := tx.In(revisionsNamespace).Get(itemKey(tx.ns, id))
+	if err != nil {
+		return 0, err
+	}
+	if found {
+		if v, ok := head.Data["version"].(float64); ok {
+			last = int64(v)
+		}
+	}
+
+	entry, err := tx.trashEntry(id)
+	if err != nil && err != ErrNotFound {
+		return 0, err
+	}
+	if err == nil && entry.Item.Version > last {
+		last = entry.Item.Version
+	}
+	return last, nil
+}
+
+// dropRevisions deletes the history of every item of a collection
+func (tx *Tx) dropRevisions(collection string) error {
+	history := tx.In(revisionsNamespace)
+	items, err := history.Prefix(collection + "/")
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := history.Delete(item.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListRevisions returns the history of an item, oldest first
+func ListRevisions(collection, id string) ([]Revision, error) {
+	var revisions []Revision
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		var err error
+		revisions, err = tx.Revisions(id)
+		if err == nil && len(revisions) == 0 {
+			return ErrNotFound
+		}
+		return err
+	})
+
+	return revisions, err
+}
+
+// GetRevision returns one revision of an item
+func GetRevision(collection, id string, rev int) (*Revision, error) {
+	var r Revision
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		var err error
+		r, err = tx.Revision(id, rev)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &r, nil
+}
+
+// DiffRevisions lists the changes that turn revision from into revision to
+func DiffRevisions(collection, id string, from, to int) ([]Change, error) {
+	var changes []Change
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		a, err := tx.Revision(id, from)
+		if err != nil {
+			return err
+		}
+		b, err := tx.Revision(id, to)
+		if err != nil {
+			return err
+		}
+
+		changes = Diff(a.Data, b.Data)
+		return nil
+	})
+
+	return changes, err
+}
+
+// RestoreRevision writes the data of an old revision back as the current
+// data of the item, recreating it if it was deleted. Either way the item
+// gets a version after every version it had, see Tx.Insert.
+func RestoreRevision(collection, id string, rev int, opts WriteOptions) (*Item, error) {
+	var item Item
+
+	err := Update(func(tx *Tx) error {
+		tx.SetAuthor(opts.Author)
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		r, err := tx.Revision(id, rev)
+		if err != nil {
+			return err
+		}
+
+		current, found, err := tx.Get(id)
+		if err != nil {
+			return err
+		}
+
+		if found {
+			if err := opts.check(current); err != nil {
+				return err
+			}
+		} else if opts.IfVersion != nil {
+			return ErrVersionMismatch
+		}
+
+		// A live item keeps its expiry, only its data goes back
+		item = Item{ID: id}
+		if found {
+			item = current
+		}
+		item.Data = cloneItem(Item{Data: r.Data}).Data
+		if err := tx.write(item, found, RevRestore); err != nil {
+			return err
+		}
+
+		item, _, err = tx.Get(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// Diff compares two item data documents
+func Diff(from, to map[string]interface{}) []Change {
+	changes := []Change{}
+	diffValues("", mapOrNil(from), mapOrNil(to), &changes)
+	return changes
+}
+
+func mapOrNil(m map[string]interface{}) interface{} {
+	if m == nil {
+		return map[string]interface{}{}
+	}
+	return m
+}
+
+func diffValues(path string, a, b interface{}, changes *[]Change) {
+	am, aok := a.(map[string]interface{})
+	bm, bok := b.(map[string]interface{})
+	if !aok || !bok {
+		if !reflect.DeepEqual(a, b) {
+			*changes = append(*changes, Change{Path: path, Op: "replace", From: a, To: b})
+		}
+		return
+	}
+
+	keys := make([]string, 0, len(am)+len(bm))
+	for k := range am {
+		keys = append(keys, k)
+	}
+	for k := range bm {
+		if _, ok := am[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		p := path + "/" + escapePointer(k)
+		av, ina := am[k]
+		bv, inb := bm[k]
+		switch {
+		case !inb:
+			*changes = append(*changes, Change{Path: p, Op: "remove", From: av})
+		case !ina:
+			*changes = append(*changes, Change{Path: p, Op: "add", To: bv})
+		default:
+			diffValues(p, av, bv, changes)
+		}
+	}
+}
+
+// escapePointer escapes a key for use as a JSON pointer segment (RFC 6901)
+func escapePointer(s string) string {
+	return strings.ReplaceAll(strings.ReplaceAll(s, "~", "~0"), "/", "~1")
+}
+
+// itemKey identifies an item across namespaces in the revision history and the trash
+func itemKey(collection, id string) string {
+	return collection + "/" + id
+}
+
+func (r Revision) item() (Item, error) {
+	data, err := toData(r)
+	if err != nil {
+		return Item{}, err
+	}
+	return Item{ID: revisionKey(itemKey(r.Collection, r.ItemID), r.Rev), Data: data}, nil
+}
+
+// revisionKey identifies one revision, zero padded so keys sort by revision
+func revisionKey(key string, rev int) string {
+	return fmt.Sprintf("%s@%08d", key, rev)
+}
+
+func revisionFromItem(item Item) (Revision, error) {
+	var r Revision
+	err := fromData(item.Data, &r)
+	return r, err
+}
@@ -0,0 +1,116 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRevisionHistory(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	createItems(t, Item{ID: "a", Data: map[string]interface{}{"name": "pump", "site": map[string]interface{}{"floor": 1.0}}})
+	if _, err := ReplaceItem("", "a", map[string]interface{}{"name": "valve", "site": map[string]interface{}{"floor": 2.0}, "tag": "x"}, WriteOptions{Author: "ann"}); err != nil {
+		t.Fatal(err)
+	}
+
+	revisions, err := ListRevisions("", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Op != RevCreate || revisions[1].Op != RevUpdate || revisions[1].Author != "ann" {
+		t.Fatalf("revisions = %+v, want a create and an update by ann", revisions)
+	}
+
+	r, err := GetRevision("", "a", 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Data["name"] != "pump" {
+		t.Errorf("revision 1 = %+v, want name pump", r)
+	}
+	if _, err := GetRevision("", "a", 3); err != ErrRevisionNotFound {
+		t.Errorf("getting revision 3: err = %v, want ErrRevisionNotFound", err)
+	}
+	if _, err := ListRevisions("", "b"); err != ErrNotFound {
+		t.Errorf("listing revisions of an unknown item: err = %v, want ErrNotFound", err)
+	}
+
+	changes, err := DiffRevisions("", "a", 1, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []Change{
+		{Path: "/name", Op: "replace", From: "pump", To: "valve"},
+		{Path: "/site/floor", Op: "replace", From: 1.0, To: 2.0},
+		{Path: "/tag", Op: "add", To: "x"},
+	}
+	if !reflect.DeepEqual(changes, want) {
+		t.Errorf("diff 1..2 = %+v, want %+v", changes, want)
+	}
+}
+
+func TestDiff(t *testing.T) {
+	tests := []struct {
+		from, to map[string]interface{}
+		want     []Change
+	}{
+		{nil, nil, []Change{}},
+		{map[string]interface{}{"a": 1.0}, nil, []Change{{Path: "/a", Op: "remove", From: 1.0}}},
+		{map[string]interface{}{"a/b": "x"}, map[string]interface{}{"a/b": "y"}, []Change{{Path: "/a~1b", Op: "replace", From: "x", To: "y"}}},
+		{map[string]interface{}{"l": []interface{}{1.0}}, map[string]interface{}{"l": []interface{}{1.0}}, []Change{}},
+		{map[string]interface{}{"o": map[string]interface{}{"k": 1.0}}, map[string]interface{}{"o": "text"}, []Change{{Path: "/o", Op: "replace", From: map[string]interface{}{"k": 1.0}, To: "text"}}},
+	}
+	for _, tt := range tests {
+		if got := Diff(tt.from, tt.to); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Diff(%v, %v) = %+v, want %+v", tt.from, tt.to, got, tt.want)
+		}
+	}
+}
+
+func TestRestoreRevision(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	expires := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+	createItems(t, Item{ID: "a", ExpiresAt: &expires, Data: map[string]interface{}{"name": "pump"}})
+	if _, err := ReplaceItem("", "a", map[string]interface{}{"name": "valve"}, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+
+	item, err := RestoreRevision("", "a", 1, WriteOptions{IfVersion: []int64{2}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Data["name"] != "pump" || item.Version != 3 {
+		t.Errorf("restored item = %+v, want name pump at version 3", item)
+	}
+	if item.ExpiresAt == nil || !item.ExpiresAt.Equal(expires) {
+		t.Errorf("restored item expires at %v, want %v", item.ExpiresAt, expires)
+	}
+	if _, err := RestoreRevision("", "a", 2, WriteOptions{IfVersion: []int64{2}}); err != ErrVersionMismatch {
+		t.Errorf("restoring onto a stale version: err = %v, want ErrVersionMismatch", err)
+	}
+
+	if err := DeleteItem("", "a", WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	item, err = RestoreRevision("", "a", 2, WriteOptions{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Data["name"] != "valve" || item.Version != 4 || item.ExpiresAt != nil {
+		t.Errorf("item restored after delete = %+v, want name valve at version 4 without expiry", item)
+	}
+
+	revisions, err := ListRevisions("", "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ops []string
+	for _, r := range revisions {
+		ops = append(ops, r.Op)
+	}
+	if want := []string{RevCreate, RevUpdate, RevRestore, RevDelete, RevRestore}; !reflect.DeepEqual(ops, want) {
+		t.Errorf("revision ops = %v, want %v", ops, want)
+	}
+}
@@ -0,0 +1,594 @@
+package database
+
+import (
+	"fmt"
+	"html"
+	"math"
+	"sort"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// BM25 parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// snippetContext is the number of tokens shown on each side of a match
+const snippetContext = 6
+
+// maxHighlights caps the highlighted fields per result
+const maxHighlights = 3
+
+// SearchResult is an item matching a search with its relevance and the
+// matching parts of its text, matches are wrapped in <mark> and the rest
+// of the snippet is HTML escaped
+type SearchResult struct {
+	Item       Item        `json:"item"`
+	Score      float64     `json:"score"`
+	Highlights []Highlight `json:"highlights"`
+}
+
+// Highlight is a snippet of one string field of the item data
+type Highlight struct {
+	Field   string `json:"field"`
+	Snippet string `json:"snippet"`
+}
+
+// SearchResults is one page of results, Total counts all matches
+type SearchResults struct {
+	Results []SearchResult `json:"results"`
+	Total   int            `json:"total"`
+}
+
+// indexes holds the full-text index of each namespace, built on the first
+// search and kept up to date by commits of this process. An index is built
+// again when its store was reread from disk, and all are dropped when the
+// backend is replaced, recovered or restored from a snapshot.
+var (
+	indexMu sync.Mutex
+	indexes = map[string]*searchIndex{}
+)
+
+// searchIndex is an inverted index of the string values in item data
+type searchIndex struct {
+	docs     map[string]*searchDoc
+	postings map[string]map[string]bool // term to IDs of the items containing it
+	terms    []string                   // sorted terms for prefix lookups, nil when stale
+	length   int                        // total tokens of all docs
+	loads    int                        // loads of the store the index was built from
+}
+
+type searchDoc struct {
+	fields []searchField
+	length int
+}
+
+// searchField is one string value, fields of array elements share the path of the array
+type searchField struct {
+	path   string
+	text   string
+	tokens []token
+}
+
+type token struct {
+	term       string
+	start, end int // byte offsets in the text
+}
+
+// searchClause is one part of a query that every result must match:
+// a term, a "quoted phrase" or a prefix*, optionally scoped as field:term
+type searchClause struct {
+	field  string
+	terms  []string
+	prefix bool // the last term matches as a prefix
+}
+
+// clauseMatch is where a clause matched in one document
+type clauseMatch struct {
+	count int
+	spans map[int][][2]int // field index to token ranges
+}
+
+// Search finds the items of a collection whose string values match q.
+// Clauses are separated by spaces and must all match:
+//
+//	crane              term
+//	"tower crane"      phrase
+//	cran*              prefix
+//	title:crane        term in data.title or below, also for phrases and prefixes
+//
+// Results are ordered by BM25 relevance.
+func Search(collection, q string, offset, limit int) (SearchResults, error) {
+	results := SearchResults{Results: []SearchResult{}}
+
+	clauses := parseSearch(q)
+	if len(clauses) == 0 {
+		return results, fmt.Errorf("%w: q must contain a search term", ErrInvalidQuery)
+	}
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		indexMu.Lock()
+		defer indexMu.Unlock()
+
+		idx, err := tx.searchIndex()
+		if err != nil {
+			return err
+		}
+
+		ranked := idx.search(clauses)
+		results.Total = len(ranked)
+		if offset >= len(ranked) {
+			return nil
+		}
+		ranked = ranked[offset:]
+		if len(ranked) > limit {
+			ranked = ranked[:limit]
+		}
+
+		for _, r := range ranked {
+			item, found, err := tx.Get(r.Item.ID)
+			if err != nil {
+				return err
+			}
+			if found {
+				r.Item = item
+				results.Results = append(results.Results, r)
+			}
+		}
+		return nil
+	})
+
+	return results, err
+}
+
+// searchIndex returns the index of this namespace, building it if needed.
+// indexMu must be held.
+func (tx *Tx) searchIndex() (*searchIndex, error) {
+	s, err := tx.store()
+	if err != nil {
+		return nil, err
+	}
+	loads := 0
+	if r, ok := s.(reloader); ok {
+		if loads, err = r.loads(); err != nil {
+			return nil, err
+		}
+	}
+
+	if idx, ok := indexes[tx.ns]; ok && idx.loads == loads {
+		return idx, nil
+	}
+
+	items, err := tx.List()
+	if err != nil {
+		return nil, err
+	}
+
+	idx := &searchIndex{docs: map[string]*searchDoc{}, postings: map[string]map[string]bool{}, loads: loads}
+	for _, item := range items {
+		idx.add(item)
+	}
+	indexes[tx.ns] = idx
+	return idx, nil
+}
+
+// updateSearchIndexes applies committed changes to the indexes built so far
+func updateSearchIndexes(batches map[string]Batch, drops []string) {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	for ns, b := range batches {
+		idx, ok := indexes[ns]
+		if !ok {
+			continue
+		}
+		for _, id := range b.Deletes {
+			idx.remove(id)
+		}
+		for _, item := range b.Puts {
+			idx.remove(item.ID)
+			idx.add(item)
+		}
+	}
+
+	for _, ns := range drops {
+		delete(indexes, ns)
+	}
+}
+
+// resetSearchIndexes forgets every index, e.g. when the backend changes
+func resetSearchIndexes() {
+	indexMu.Lock()
+	defer indexMu.Unlock()
+
+	indexes = map[string]*searchIndex{}
+}
+
+func (idx *searchIndex) add(item Item) {
+	doc := &searchDoc{}
+	collectFields("", item.Data, doc)
+	if doc.length == 0 {
+		return
+	}
+
+	idx.docs[item.ID] = doc
+	idx.length += doc.length
+	for _, f := range doc.fields {
+		for _, t := range f.tokens {
+			ids, ok := idx.postings[t.term]
+			if !ok {
+				ids = map[string]bool{}
+				idx.postings[t.term] = ids
+				idx.terms = nil
+			}
+			ids[item.ID] = true
+		}
+	}
+}
+
+func (idx *searchIndex) remove(id string) {
+	doc, ok := idx.docs[id]
+	if !ok {
+		return
+	}
+
+	delete(idx.docs, id)
+	idx.length -= doc.length
+	for _, f := range doc.fields {
+		for _, t := range f.tokens {
+			ids := idx.postings[t.term]
+			delete(ids, id)
+			if len(ids) == 0 {
+				delete(idx.postings, t.term)
+				idx.terms = nil
+			}
+		}
+	}
+}
+
+// collectFields gathers the string values below v with their dotted paths
+func collectFields(path string, v interface{}, doc *searchDoc) {
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			p := k
+			if path != "" {
+				p = path + "." + k
+			}
+			collectFields(p, v[k], doc)
+		}
+	case []interface{}:
+		for _, child := range v {
+			collectFields(path, child, doc)
+		}
+	case string:
+		tokens := tokenize(v)
+		if len(tokens) > 0 {
+			doc.fields = append(doc.fields, searchField{path: path, text: v, tokens: tokens})
+			doc.length += len(tokens)
+		}
+	}
+}
+
+// tokenize splits text into lower case runs of letters and digits
+func tokenize(text string) []token {
+	var tokens []token
+	start := -1
+	for i, r := range text {
+		word := unicode.IsLetter(r) || unicode.IsDigit(r)
+		if word && start < 0 {
+			start = i
+		}
+		if !word && start >= 0 {
+			tokens = append(tokens, token{term: strings.ToLower(text[start:i]), start: start, end: i})
+			start = -1
+		}
+	}
+	if start >= 0 {
+		tokens = append(tokens, token{term: strings.ToLower(text[start:]), start: start, end: len(text)})
+	}
+	return tokens
+}
+
+// parseSearch splits a query into clauses
+func parseSearch(q string) []searchClause {
+	var clauses []searchClause
+
+	for len(q) > 0 {
+		q = strings.TrimLeftFunc(q, unicode.IsSpace)
+		if q == "" {
+			break
+		}
+
+		var c searchClause
+		if i := strings.IndexByte(q, ':'); i > 0 && isFieldPath(q[:i]) {
+			c.field, q = strings.TrimPrefix(q[:i], "data."), q[i+1:]
+		}
+
+		var text string
+		if strings.HasPrefix(q, `"`) {
+			end := strings.IndexByte(q[1:], '"')
+			if end < 0 {
+				text, q = q[1:], ""
+			} else {
+				text, q = q[1:end+1], q[end+2:]
+			}
+		} else {
+			end := strings.IndexFunc(q, unicode.IsSpace)
+			if end < 0 {
+				end = len(q)
+			}
+			text, q = q[:end], q[end:]
+			if strings.HasSuffix(text, "*") {
+				c.prefix = true
+			}
+		}
+
+		for _, t := range tokenize(text) {
+			c.terms = append(c.terms, t.term)
+		}
+		if len(c.terms) > 0 {
+			clauses = append(clauses, c)
+		}
+	}
+	return clauses
+}
+
+func isFieldPath(s string) bool {
+	for _, r := range s {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' && r != '-' && r != '.' {
+			return false
+		}
+	}
+	return true
+}
+
+// search returns every document matching all clauses, best first
+func (idx *searchIndex) search(clauses []searchClause) []SearchResult {
+	avg := 1.0
+	if len(idx.docs) > 0 {
+		avg = float64(idx.length) / float64(len(idx.docs))
+	}
+
+	scores := map[string]float64{}
+	matches := map[string][]clauseMatch{}
+	for i, c := range clauses {
+		found := map[string]clauseMatch{}
+		for _, id := range idx.candidates(c) {
+			if i > 0 {
+				if _, ok := scores[id]; !ok {
+					continue
+				}
+			}
+			if m := idx.docs[id].match(c); m.count > 0 {
+				found[id] = m
+			}
+		}
+
+		idf := idx.idf(c)
+		next := map[string]float64{}
+		for id, m := range found {
+			tf := float64(m.count)
+			norm := 1 - bm25B + bm25B*float64(idx.docs[id].length)/avg
+			next[id] = scores[id] + idf*tf*(bm25K1+1)/(tf+bm25K1*norm)
+			matches[id] = append(matches[id], m)
+		}
+		scores = next
+	}
+
+	results := make([]SearchResult, 0, len(scores))
+	for id, score := range scores {
+		results = append(results, SearchResult{
+			Item:       Item{ID: id},
+			Score:      math.Round(score*1000) / 1000,
+			Highlights: idx.docs[id].highlights(matches[id]),
+		})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].Score != results[j].Score {
+			return results[i].Score > results[j].Score
+		}
+		return results[i].Item.ID < results[j].Item.ID
+	})
+	return results
+}
+
+// idf sums the inverse document frequency of the terms of a clause over
+// the whole index, a prefix counting every document with a matching term
+func (idx *searchIndex) idf(c searchClause) float64 {
+	n := float64(len(idx.docs))
+	sum := 0.0
+	for k, term := range c.terms {
+		df := len(idx.postings[term])
+		if k == len(c.terms)-1 && c.prefix {
+			docs := map[string]bool{}
+			for _, t := range idx.termsWithPrefix(term) {
+				for id := range idx.postings[t] {
+					docs[id] = true
+				}
+			}
+			df = len(docs)
+		}
+		sum += math.Log(1 + (n-float64(df)+0.5)/(float64(df)+0.5))
+	}
+	return sum
+}
+
+// candidates returns the IDs of the documents containing the first term of a clause
+func (idx *searchIndex) candidates(c searchClause) []string {
+	var ids []string
+	if len(c.terms) == 1 && c.prefix {
+		for _, term := range idx.termsWithPrefix(c.terms[0]) {
+			for id := range idx.postings[term] {
+				ids = append(ids, id)
+			}
+		}
+		sort.Strings(ids)
+		return compactStrings(ids)
+	}
+
+	for id := range idx.postings[c.terms[0]] {
+		ids = append(ids, id)
+	}
+	return ids
+}
+
+func (idx *searchIndex) termsWithPrefix(prefix string) []string {
+	if idx.terms == nil {
+		idx.terms = make([]string, 0, len(idx.postings))
+		for term := range idx.postings {
+			idx.terms = append(idx.terms, term)
+		}
+		sort.Strings(idx.terms)
+	}
+
+	i := sort.SearchStrings(idx.terms, prefix)
+	j := i
+	for j < len(idx.terms) && strings.HasPrefix(idx.terms[j], prefix) {
+		j++
+	}
+	return idx.terms[i:j]
+}
+
+func compactStrings(s []string) []string {
+	out := s[:0]
+	for i, v := range s {
+		if i == 0 || v != s[i-1] {
+			out = append(out, v)
+		}
+	}
+	return out
+}
+
+// match finds the occurrences of a clause in the fields it is scoped to
+func (doc *searchDoc) match(c searchClause) clauseMatch {
+	m := clauseMatch{spans: map[int][][2]int{}}
+
+	for fi, f := range doc.fields {
+		if c.field != "" && f.path != c.field && !strings.HasPrefix(f.path, c.field+".") {
+			continue
+		}
+
+		n := len(c.terms)
+		for start := 0; start+n <= len(f.tokens); start++ {
+			ok := true
+			for k, term := range c.terms {
+				t := f.tokens[start+k].term
+				if k == n-1 && c.prefix {
+					ok = strings.HasPrefix(t, term)
+				} else {
+					ok = t == term
+				}
+				if !ok {
+					break
+				}
+			}
+			if ok {
+				m.count++
+				m.spans[fi] = append(m.spans[fi], [2]int{start, start + n})
+			}
+		}
+	}
+	return m
+}
+
+// highlights builds snippets of the fields with the most matches
+func (doc *searchDoc) highlights(matches []clauseMatch) []Highlight {
+	marked := map[int]map[int]bool{} // field index to matched token positions
+	for _, m := range matches {
+		for fi, spans := range m.spans {
+			if marked[fi] == nil {
+				marked[fi] = map[int]bool{}
+			}
+			for _, s := range spans {
+				for p := s[0]; p < s[1]; p++ {
+					marked[fi][p] = true
+				}
+			}
+		}
+	}
+
+	fields := make([]int, 0, len(marked))
+	for fi := range marked {
+		fields = append(fields, fi)
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		a, b := fields[i], fields[j]
+		if len(marked[a]) != len(marked[b]) {
+			return len(marked[a]) > len(marked[b])
+		}
+		return a < b
+	})
+	if len(fields) > maxHighlights {
+		fields = fields[:maxHighlights]
+	}
+
+	highlights := make([]Highlight, 0, len(fields))
+	for _, fi := range fields {
+		f := doc.fields[fi]
+		highlights = append(highlights, Highlight{Field: "data." + f.path, Snippet: f.snippet(marked[fi])})
+	}
+	return highlights
+}
+
+// snippet cuts the text around the first marked token and wraps marked tokens in <mark>
+func (f searchField) snippet(marked map[int]bool) string {
+	first := len(f.tokens)
+	for p := range marked {
+		if p < first {
+			first = p
+		}
+	}
+
+	from := first - snippetContext
+	if from < 0 {
+		from = 0
+	}
+	to := first + 2*snippetContext
+	if to > len(f.tokens) {
+		to = len(f.tokens)
+	}
+
+	start, end := 0, len(f.text)
+	if from > 0 {
+		start = f.tokens[from].start
+	}
+	if to < len(f.tokens) {
+		end = f.tokens[to-1].end
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	pos := start
+	for p := from; p < to; p++ {
+		t := f.tokens[p]
+		if !marked[p] {
+			continue
+		}
+		b.WriteString(html.EscapeString(f.text[pos:t.start]))
+		b.WriteString("<mark>")
+		b.WriteString(html.EscapeString(f.text[t.start:t.end]))
+		b.WriteString("</mark>")
+		pos = t.end
+	}
+	b.WriteString(html.EscapeString(f.text[pos:end]))
+	if end < len(f.text) {
+		b.WriteString("…")
+	}
+	return b.String()
+}
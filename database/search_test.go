@@ -0,0 +1,77 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func searchIDs(t *testing.T, q string) ([]string, map[string]float64) {
+	t.Helper()
+	res, err := Search("", q, 0, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]string, len(res.Results))
+	scores := map[string]float64{}
+	for i, r := range res.Results {
+		ids[i] = r.Item.ID
+		scores[r.Item.ID] = r.Score
+	}
+	return ids, scores
+}
+
+func TestSearchRanking(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+	createItems(t,
+		Item{ID: "a", Data: map[string]interface{}{"title": "pump station", "text": "pump pump"}},
+		Item{ID: "b", Data: map[string]interface{}{"title": "pump room", "text": "valve"}},
+		Item{ID: "c", Data: map[string]interface{}{"title": "pump house", "text": "station"}},
+		Item{ID: "d", Data: map[string]interface{}{"title": "tank"}},
+	)
+
+	ids, _ := searchIDs(t, "pump")
+	if len(ids) != 3 || ids[0] != "a" {
+		t.Errorf("search pump = %v, want a first of 3", ids)
+	}
+
+	ids, scores := searchIDs(t, "pump station")
+	if len(ids) != 2 || ids[0] != "a" {
+		t.Errorf("search pump station = %v, want a then c", ids)
+	}
+	_, reversed := searchIDs(t, "station pump")
+	for id, score := range scores {
+		if reversed[id] != score {
+			t.Errorf("score of %s = %v, reversed clauses score %v", id, score, reversed[id])
+		}
+	}
+
+	ids, _ = searchIDs(t, "title:sta*")
+	if len(ids) != 1 || ids[0] != "a" {
+		t.Errorf("search title:sta* = %v, want only a", ids)
+	}
+}
+
+func TestSearchFollowsWrites(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.json")
+	useBackend(t, NewJSONBackend(path))
+	createItems(t, Item{ID: "a", Data: map[string]interface{}{"title": "pump"}})
+
+	if ids, _ := searchIDs(t, "pump"); len(ids) != 1 {
+		t.Fatalf("search pump = %v, want a", ids)
+	}
+	if _, err := ReplaceItem("", "a", map[string]interface{}{"title": "valve"}, WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := searchIDs(t, "pump"); len(ids) != 0 {
+		t.Errorf("search pump after replace = %v, want nothing", ids)
+	}
+
+	content := `[{"id": "a", "version": 3, "data": {"title": "tank"}}]`
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if ids, _ := searchIDs(t, "tank"); len(ids) != 1 {
+		t.Errorf("search tank after the file changed = %v, want a", ids)
+	}
+}
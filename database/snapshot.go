@@ -0,0 +1,427 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/atomicfile"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SnapshotDir is where snapshots are kept unless configured otherwise
+const SnapshotDir = "./database/snapshots"
+
+// snapshotFormat is the version of the snapshot document layout
+const snapshotFormat = 1
+
+const (
+	snapshotPrefix = "snapshot-"
+	snapshotExt    = ".json.gz"
+	checksumExt    = ".sha256"
+	snapshotTime   = "20060102T150405.000Z"
+)
+
+var (
+	// ErrSnapshotNotFound is returned for snapshot names that do not exist.
+	ErrSnapshotNotFound = errors.New("snapshot not found")
+	// ErrInvalidSnapshot is returned when a snapshot fails its checksum or cannot be read.
+	ErrInvalidSnapshot = errors.New("invalid snapshot")
+)
+
+// SnapshotInfo describes a snapshot file. A snapshot is a gzipped JSON
+// document of every namespace, next to a sha256sum style checksum file.
+type SnapshotInfo struct {
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	Size      int64     `json:"size"`
+	SHA256    string    `json:"sha256"`
+	Items     int       `json:"items,omitempty"`
+}
+
+// Retention decides which snapshots pruning keeps. The newest Last
+// snapshots are kept, plus the newest snapshot of each of the last Daily
+// days. With both zero every snapshot is kept.
+type Retention struct {
+	Last  int
+	Daily int
+}
+
+// snapshotDoc is the decompressed content of a snapshot
+type snapshotDoc struct {
+	Format     int               `json:"format"`
+	CreatedAt  time.Time         `json:"created_at"`
+	Namespaces map[string][]Item `json:"namespaces"`
+}
+
+// TakeSnapshot writes a snapshot of the whole database to dir. All
+// namespaces are read in one transaction, so the snapshot is consistent.
+func TakeSnapshot(dir string) (SnapshotInfo, error) {
+	var info SnapshotInfo
+
+	err := View(func(tx *Tx) error {
+		var err error
+		info, err = tx.snapshot(dir)
+		return err
+	})
+
+	return info, err
+}
+
+// snapshot writes every namespace as seen by the transaction to dir
+func (tx *Tx) snapshot(dir string) (SnapshotInfo, error) {
+	doc := snapshotDoc{Format: snapshotFormat, CreatedAt: time.Now().UTC(), Namespaces: map[string][]Item{}}
+
+	namespaces, err := tx.backend.Namespaces()
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	for _, ns := range namespaces {
+		items, err := tx.In(ns).query(nil)
+		if err != nil {
+			return SnapshotInfo{}, err
+		}
+		doc.Namespaces[ns] = items
+	}
+
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if err := json.NewEncoder(zw).Encode(doc); err != nil {
+		return SnapshotInfo{}, err
+	}
+	if err := zw.Close(); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	info := SnapshotInfo{
+		Name:      snapshotPrefix + doc.CreatedAt.Format(snapshotTime) + snapshotExt,
+		CreatedAt: doc.CreatedAt,
+		Size:      int64(buf.Len()),
+		SHA256:    hex.EncodeToString(sum[:]),
+		Items:     doc.items(),
+	}
+
+	// The checksum is written last, a snapshot without one is incomplete
+	path := filepath.Join(dir, info.Name)
+	if err := atomicfile.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return SnapshotInfo{}, err
+	}
+	checksum := fmt.Sprintf("%s  %s\n", info.SHA256, info.Name)
+	if err := atomicfile.WriteFile(path+checksumExt, []byte(checksum), 0644); err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	return info, nil
+}
+
+// ListSnapshots returns the complete snapshots in dir, newest first
+func ListSnapshots(dir string) ([]SnapshotInfo, error) {
+	matches, err := filepath.Glob(filepath.Join(dir, snapshotPrefix+"*"+snapshotExt))
+	if err != nil {
+		return nil, err
+	}
+
+	snapshots := []SnapshotInfo{}
+	for _, m := range matches {
+		info, err := snapshotInfo(dir, filepath.Base(m))
+		if err != nil {
+			continue
+		}
+		snapshots = append(snapshots, info)
+	}
+	sort.Slice(snapshots, func(i, j int) bool {
+		return snapshots[i].Name > snapshots[j].Name
+	})
+	return snapshots, nil
+}
+
+// VerifySnapshot checks the checksum and content of a snapshot without restoring it
+func VerifySnapshot(dir, name string) (SnapshotInfo, error) {
+	info, doc, err := readSnapshot(dir, name)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	info.Items = doc.items()
+	return info, nil
+}
+
+// RestoreSnapshot replaces the whole database with a snapshot. The
+// snapshot is read and validated completely before anything is changed,
+// namespaces that are not in the snapshot are dropped.
+func RestoreSnapshot(dir, name string) (SnapshotInfo, error) {
+	info, doc, err := readSnapshot(dir, name)
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	info.Items = doc.items()
+
+	err = Update(func(tx *Tx) error {
+		namespaces, err := tx.backend.Namespaces()
+		if err != nil {
+			return err
+		}
+		for _, ns := range namespaces {
+			if _, ok := doc.Namespaces[ns]; !ok {
+				tx.drops = append(tx.drops, ns)
+			}
+		}
+
+		for ns, items := range doc.Namespaces {
+			view := tx.In(ns)
+			current, err := view.query(nil)
+			if err != nil {
+				return err
+			}
+
+			keep := make(map[string]bool, len(items))
+			for _, item := range items {
+				keep[item.ID] = true
+				view.put(item)
+			}
+			cs := view.changeSet()
+			for _, item := range current {
+				if !keep[item.ID] {
+					cs.deletes[item.ID] = true
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	resetSearchIndexes()
+	return info, nil
+}
+
+// DeleteSnapshot removes a snapshot and its checksum
+func DeleteSnapshot(dir, name string) error {
+	if !validSnapshotName(name) {
+		return ErrSnapshotNotFound
+	}
+
+	path := filepath.Join(dir, name)
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return ErrSnapshotNotFound
+		}
+		return err
+	}
+	if err := os.Remove(path + checksumExt); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+// PruneSnapshots deletes the snapshots the retention rules do not keep
+// and returns their names
+func PruneSnapshots(dir string, r Retention) ([]string, error) {
+	if r.Last <= 0 && r.Daily <= 0 {
+		return []string{}, nil
+	}
+
+	snapshots, err := ListSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	keep := map[string]bool{}
+	for i := 0; i < r.Last && i < len(snapshots); i++ {
+		keep[snapshots[i].Name] = true
+	}
+
+	if r.Daily > 0 {
+		cutoff := time.Now().UTC().Truncate(24*time.Hour).AddDate(0, 0, -(r.Daily - 1))
+		days := map[string]bool{}
+		for _, s := range snapshots {
+			day := s.CreatedAt.Format("2006-01-02")
+			if s.CreatedAt.Before(cutoff) || days[day] {
+				continue
+			}
+			days[day] = true
+			keep[s.Name] = true
+		}
+	}
+
+	pruned := []string{}
+	for _, s := range snapshots {
+		if keep[s.Name] {
+			continue
+		}
+		if err := DeleteSnapshot(dir, s.Name); err != nil {
+			return pruned, err
+		}
+		pruned = append(pruned, s.Name)
+	}
+	return pruned, nil
+}
+
+// ScheduleSnapshots takes a snapshot and prunes old ones every interval
+// until stop is closed. Failures are logged and retried at the next tick.
+func ScheduleSnapshots(dir string, interval time.Duration, r Retention, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		info, err := TakeSnapshot(dir)
+		if err != nil {
+			log.Printf("snapshot failed: %v", err)
+			continue
+		}
+		log.Printf("snapshot %s taken (%d items)", info.Name, info.Items)
+
+		if _, err := PruneSnapshots(dir, r); err != nil {
+			log.Printf("snapshot pruning failed: %v", err)
+		}
+	}
+}
+
+// snapshotInfo reads the checksum file of a snapshot without verifying it
+func snapshotInfo(dir, name string) (SnapshotInfo, error) {
+	if !validSnapshotName(name) {
+		return SnapshotInfo{}, ErrSnapshotNotFound
+	}
+
+	created, err := time.Parse(snapshotTime, strings.TrimSuffix(strings.TrimPrefix(name, snapshotPrefix), snapshotExt))
+	if err != nil {
+		return SnapshotInfo{}, ErrSnapshotNotFound
+	}
+
+	path := filepath.Join(dir, name)
+	stat, err := os.Stat(path)
+	if os.IsNotExist(err) {
+		return SnapshotInfo{}, ErrSnapshotNotFound
+	}
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+
+	checksum, err := os.ReadFile(path + checksumExt)
+	if os.IsNotExist(err) {
+		return SnapshotInfo{}, fmt.Errorf("%w: %s has no checksum", ErrInvalidSnapshot, name)
+	}
+	if err != nil {
+		return SnapshotInfo{}, err
+	}
+	fields := strings.Fields(string(checksum))
+	if len(fields) == 0 {
+		return SnapshotInfo{}, fmt.Errorf("%w: %s has an empty checksum", ErrInvalidSnapshot, name)
+	}
+
+	return SnapshotInfo{Name: name, CreatedAt: created, Size: stat.Size(), SHA256: fields[0]}, nil
+}
+
+// readSnapshot reads, verifies and decodes a snapshot
+func readSnapshot(dir, name string) (SnapshotInfo, snapshotDoc, error) {
+	var doc snapshotDoc
+
+	info, err := snapshotInfo(dir, name)
+	if err != nil {
+		return info, doc, err
+	}
+
+	f, err := os.Open(filepath.Join(dir, name))
+	if err != nil {
+		return info, doc, err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	zr, err := gzip.NewReader(io.TeeReader(f, h))
+	if err != nil {
+		return info, doc, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if err := json.NewDecoder(zr).Decode(&doc); err != nil {
+		return info, doc, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	// Drain the rest so the gzip trailer is checked and fully hashed
+	if _, err := io.Copy(io.Discard, zr); err != nil {
+		return info, doc, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	if _, err := io.Copy(h, f); err != nil {
+		return info, doc, err
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != info.SHA256 {
+		return info, doc, fmt.Errorf("%w: checksum mismatch", ErrInvalidSnapshot)
+	}
+	if err := doc.validate(); err != nil {
+		return info, doc, fmt.Errorf("%w: %v", ErrInvalidSnapshot, err)
+	}
+	return info, doc, nil
+}
+
+// validate checks that the snapshot can be restored as a whole
+func (doc snapshotDoc) validate() error {
+	if doc.Format != snapshotFormat {
+		return fmt.Errorf("unsupported format %d", doc.Format)
+	}
+	if _, ok := doc.Namespaces[""]; !ok {
+		return errors.New("default namespace missing")
+	}
+
+	catalog := map[string]bool{}
+	for _, item := range doc.Namespaces[collectionsNamespace] {
+		if _, err := collectionFromItem(item); err != nil {
+			return fmt.Errorf("collection %q: %v", item.ID, err)
+		}
+		catalog[item.ID] = true
+	}
+
+	for ns, items := range doc.Namespaces {
+		if err := validNamespace(ns); err != nil {
+			return err
+		}
+		if ns != "" && !isReserved(ns) && !catalog[ns] {
+			return fmt.Errorf("namespace %q has no collection", ns)
+		}
+
+		seen := make(map[string]bool, len(items))
+		for _, item := range items {
+			if item.ID == "" {
+				return fmt.Errorf("namespace %q has an item without ID", ns)
+			}
+			if seen[item.ID] {
+				return fmt.Errorf("namespace %q has duplicate ID %q", ns, item.ID)
+			}
+			seen[item.ID] = true
+		}
+	}
+	return nil
+}
+
+func (doc snapshotDoc) items() int {
+	n := 0
+	for ns, items := range doc.Namespaces {
+		if !isReserved(ns) {
+			n += len(items)
+		}
+	}
+	return n
+}
+
+func validSnapshotName(name string) bool {
+	return strings.HasPrefix(name, snapshotPrefix) && strings.HasSuffix(name, snapshotExt) &&
+		!strings.ContainsAny(name, `/\`)
+}
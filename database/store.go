@@ -0,0 +1,193 @@
+package database
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Store holds the items of one namespace.
+// Get and Delete return ErrNotFound when the ID is unknown, Put inserts or replaces.
+type Store interface {
+	Get(id string) (Item, error)
+	Put(item Item) error
+	Delete(id string) error
+	List() ([]Item, error)
+	Query(match func(Item) bool) ([]Item, error)
+}
+
+// Backend is a storage engine holding one Store per namespace.
+// The default namespace "" holds items outside any collection, each
+// collection has a namespace of its own, and names starting with "_"
+// are reserved for the database package's bookkeeping.
+type Backend interface {
+	Store(namespace string) (Store, error)
+	Namespaces() ([]string, error)
+	Drop(namespace string) error
+	Close() error
+}
+
+// Batch is a set of changes committed together by a transaction
+type Batch struct {
+	Puts    []Item
+	Deletes []string
+}
+
+// batcher is implemented by stores that can apply a Batch atomically.
+// Other stores get the changes one by one.
+type batcher interface {
+	Apply(b Batch) error
+}
+
+// prefixer is implemented by stores that find the items whose ID starts
+// with a prefix without reading the others, ordered by ID. Other stores
+// are queried in full.
+type prefixer interface {
+	Prefix(prefix string) ([]Item, error)
+}
+
+// ranger is implemented by stores that read their items in ID order a
+// page at a time. Other stores are listed in full for every page.
+type ranger interface {
+	After(id string, limit int) ([]Item, error)
+}
+
+// reloader is implemented by stores that reread their data when another
+// process changed it. Loads counts the reads, so caches built from the
+// store, like the search index, know when to rebuild.
+type reloader interface {
+	loads() (int, error)
+}
+
+// checkpointer is implemented by backends that can keep committed changes
+// in memory while the write-ahead log holds them. Checkpoint writes them
+// out so the log can be emptied, reload drops them once another process
+// sharing the files has done so.
+type checkpointer interface {
+	deferWrites()
+	checkpoint() error
+	reload()
+}
+
+// locker is implemented by backends shared with other processes, they
+// hand out a lock that is held for the whole transaction.
+type locker interface {
+	lock(exclusive bool) (unlock func(), err error)
+}
+
+// Backend names accepted by OpenBackend
+const (
+	BackendJSON   = "json"
+	BackendMemory = "memory"
+	BackendBolt   = "bolt"
+)
+
+var namespaceName = regexp.MustCompile(`^_?[a-z0-9][a-z0-9_-]{0,63}$`)
+
+var (
+	backendMu sync.RWMutex
+	backend   Backend = NewJSONBackend(dbFile)
+)
+
+// OpenBackend creates the named backend.
+// An empty name selects JSON files, an empty path the backend's default file.
+func OpenBackend(name, path string) (Backend, error) {
+	switch name {
+	case "", BackendJSON:
+		if path == "" {
+			path = dbFile
+		}
+		return NewJSONBackend(path), nil
+	case BackendMemory:
+		return NewMemoryBackend(), nil
+	case BackendBolt:
+		if path == "" {
+			path = boltFile
+		}
+		return NewBoltBackend(path)
+	default:
+		return nil, fmt.Errorf("unknown database backend %q", name)
+	}
+}
+
+// SetBackend replaces the backend used by the package level functions
+func SetBackend(b Backend) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	backendMu.Lock()
+	backend = b
+	backendMu.Unlock()
+
+	resetSearchIndexes()
+}
+
+func currentBackend() Backend {
+	backendMu.RLock()
+	defer backendMu.RUnlock()
+	return backend
+}
+
+func validNamespace(ns string) error {
+	if ns == "" || namespaceName.MatchString(ns) {
+		return nil
+	}
+	return fmt.Errorf("invalid namespace %q", ns)
+}
+
+// sortedIDs keeps the IDs of a store in order for prefix lookups
+type sortedIDs []string
+
+func newSortedIDs(items []Item) sortedIDs {
+	ids := make(sortedIDs, len(items))
+	for i, item := range items {
+		ids[i] = item.ID
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+// insert adds id unless it is there already
+func (s sortedIDs) insert(id string) sortedIDs {
+	i := sort.SearchStrings(s, id)
+	if i < len(s) && s[i] == id {
+		return s
+	}
+	s = append(s, "")
+	copy(s[i+1:], s[i:])
+	s[i] = id
+	return s
+}
+
+func (s sortedIDs) remove(id string) sortedIDs {
+	i := sort.SearchStrings(s, id)
+	if i == len(s) || s[i] != id {
+		return s
+	}
+	return append(s[:i], s[i+1:]...)
+}
+
+// withPrefix returns the IDs starting with prefix
+func (s sortedIDs) withPrefix(prefix string) []string {
+	i := sort.SearchStrings(s, prefix)
+	j := i
+	for j < len(s) && strings.HasPrefix(s[j], prefix) {
+		j++
+	}
+	return s[i:j]
+}
+
+// after returns up to limit IDs following id
+func (s sortedIDs) after(id string, limit int) []string {
+	i := sort.SearchStrings(s, id)
+	if i < len(s) && s[i] == id {
+		i++
+	}
+	j := i + limit
+	if j > len(s) {
+		j = len(s)
+	}
+	return s[i:j]
+}
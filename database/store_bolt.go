@@ -0,0 +1,235 @@
+package database
+
+import (
+	"bytes"
+	"encoding/json"
+	"sort"
+	"strings"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+const boltFile = "./database/data.db"
+
+// Bucket of the default namespace, other namespaces use "items.<namespace>"
+const itemsBucket = "items"
+
+// BoltBackend keeps every namespace in its own bucket of one embedded bbolt file
+type BoltBackend struct {
+	db *bolt.DB
+}
+
+func NewBoltBackend(path string) (*BoltBackend, error) {
+	db, err := bolt.Open(path, 0644, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	return &BoltBackend{db: db}, nil
+}
+
+func (b *BoltBackend) Store(ns string) (Store, error) {
+	if err := validNamespace(ns); err != nil {
+		return nil, err
+	}
+	return &BoltStore{db: b.db, bucket: []byte(bucketName(ns))}, nil
+}
+
+func (b *BoltBackend) Namespaces() ([]string, error) {
+	namespaces := []string{""}
+
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.ForEach(func(name []byte, _ *bolt.Bucket) error {
+			if ns, ok := strings.CutPrefix(string(name), itemsBucket+"."); ok {
+				namespaces = append(namespaces, ns)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+func (b *BoltBackend) Drop(ns string) error {
+	if ns == "" {
+		return nil
+	}
+	if err := validNamespace(ns); err != nil {
+		return err
+	}
+
+	return b.db.Update(func(tx *bolt.Tx) error {
+		err := tx.DeleteBucket([]byte(bucketName(ns)))
+		if err == bolt.ErrBucketNotFound {
+			return nil
+		}
+		return err
+	})
+}
+
+func (b *BoltBackend) Close() error {
+	return b.db.Close()
+}
+
+func bucketName(ns string) string {
+	if ns == "" {
+		return itemsBucket
+	}
+	return itemsBucket + "." + ns
+}
+
+// BoltStore keeps the items of one namespace in a bucket, keyed by ID.
+// The bucket is created on the first write.
+type BoltStore struct {
+	db     *bolt.DB
+	bucket []byte
+}
+
+func (s *BoltStore) Get(id string) (Item, error) {
+	var item Item
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return ErrNotFound
+		}
+		v := b.Get([]byte(id))
+		if v == nil {
+			return ErrNotFound
+		}
+		return json.Unmarshal(v, &item)
+	})
+
+	return item, err
+}
+
+func (s *BoltStore) Put(item Item) error {
+	return s.Apply(Batch{Puts: []Item{item}})
+}
+
+func (s *BoltStore) Delete(id string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil || b.Get([]byte(id)) == nil {
+			return ErrNotFound
+		}
+		return b.Delete([]byte(id))
+	})
+}
+
+func (s *BoltStore) List() ([]Item, error) {
+	return s.Query(nil)
+}
+
+func (s *BoltStore) Query(match func(Item) bool) ([]Item, error) {
+	items := []Item{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(k, v []byte) error {
+			var item Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			if match == nil || match(item) {
+				items = append(items, item)
+			}
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Prefix returns the items whose key starts with prefix, bolt keeps keys in order
+func (s *BoltStore) Prefix(prefix string) ([]Item, error) {
+	items := []Item{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		for k, v := c.Seek([]byte(prefix)); k != nil && bytes.HasPrefix(k, []byte(prefix)); k, v = c.Next() {
+			var item Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// After returns up to limit items following the key id, in key order
+func (s *BoltStore) After(id string, limit int) ([]Item, error) {
+	items := []Item{}
+
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(s.bucket)
+		if b == nil {
+			return nil
+		}
+		c := b.Cursor()
+		k, v := c.Seek([]byte(id))
+		if k != nil && string(k) == id {
+			k, v = c.Next()
+		}
+		for ; k != nil && len(items) < limit; k, v = c.Next() {
+			var item Item
+			if err := json.Unmarshal(v, &item); err != nil {
+				return err
+			}
+			items = append(items, item)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// Apply writes the whole batch in a single bolt transaction
+func (s *BoltStore) Apply(batch Batch) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(s.bucket)
+		if err != nil {
+			return err
+		}
+
+		for _, id := range batch.Deletes {
+			if err := b.Delete([]byte(id)); err != nil {
+				return err
+			}
+		}
+		for _, item := range batch.Puts {
+			v, err := json.Marshal(item)
+			if err != nil {
+				return err
+			}
+			if err := b.Put([]byte(item.ID), v); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,444 @@
+package database
+
+import (
+	"encoding/json"
+	"go-backend/atomicfile"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// JSONBackend stores the default namespace in the file at path and every
+// other namespace next to it, e.g. data.json and data.hazards.json.
+type JSONBackend struct {
+	path string
+
+	mu       sync.Mutex
+	stores   map[string]*JSONStore
+	deferred bool
+}
+
+func NewJSONBackend(path string) *JSONBackend {
+	return &JSONBackend{path: path, stores: map[string]*JSONStore{}}
+}
+
+func (b *JSONBackend) Store(ns string) (Store, error) {
+	if err := validNamespace(ns); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.stores[ns]
+	if !ok {
+		s = NewJSONStore(b.namespacePath(ns))
+		s.deferred = b.deferred
+		b.stores[ns] = s
+	}
+	return s, nil
+}
+
+func (b *JSONBackend) Namespaces() ([]string, error) {
+	prefix, ext := b.split()
+	matches, err := filepath.Glob(prefix + ".*" + ext)
+	if err != nil {
+		return nil, err
+	}
+
+	// Glob returns cleaned paths, so compare file names only
+	base := filepath.Base(prefix) + "."
+	namespaces := []string{""}
+	found := map[string]bool{"": true}
+	for _, m := range matches {
+		ns := strings.TrimSuffix(strings.TrimPrefix(filepath.Base(m), base), ext)
+		if namespaceName.MatchString(ns) {
+			namespaces = append(namespaces, ns)
+			found[ns] = true
+		}
+	}
+
+	// Stores with deferred changes may not have a file yet
+	b.mu.Lock()
+	for ns, s := range b.stores {
+		s.mu.Lock()
+		if s.dirty && !found[ns] {
+			namespaces = append(namespaces, ns)
+		}
+		s.mu.Unlock()
+	}
+	b.mu.Unlock()
+
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+func (b *JSONBackend) Drop(ns string) error {
+	if ns == "" {
+		return nil
+	}
+	if err := validNamespace(ns); err != nil {
+		return err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	delete(b.stores, ns)
+	err := os.Remove(b.namespacePath(ns))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	return err
+}
+
+func (b *JSONBackend) Close() error {
+	return nil
+}
+
+// lock takes one advisory lock for all namespace files
+func (b *JSONBackend) lock(exclusive bool) (func(), error) {
+	f, err := os.OpenFile(b.path+".lock", os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	if err := lockFile(f, exclusive); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	return func() {
+		unlockFile(f)
+		f.Close()
+	}, nil
+}
+
+// deferWrites keeps the changes applied to the stores in memory until
+// checkpoint writes them, while the write-ahead log holds them
+func (b *JSONBackend) deferWrites() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.deferred = true
+	for _, s := range b.stores {
+		s.mu.Lock()
+		s.deferred = true
+		s.mu.Unlock()
+	}
+}
+
+// checkpoint writes every store with deferred changes to its file
+func (b *JSONBackend) checkpoint() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range b.stores {
+		if err := s.flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// reload drops the cached items and deferred changes of every store, so
+// they are read from their files again
+func (b *JSONBackend) reload() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	for _, s := range b.stores {
+		s.mu.Lock()
+		s.dirty, s.info = false, nil
+		s.mu.Unlock()
+	}
+}
+
+func (b *JSONBackend) namespacePath(ns string) string {
+	if ns == "" {
+		return b.path
+	}
+	prefix, ext := b.split()
+	return prefix + "." + ns + ext
+}
+
+func (b *JSONBackend) split() (prefix, ext string) {
+	ext = filepath.Ext(b.path)
+	return strings.TrimSuffix(b.path, ext), ext
+}
+
+// JSONStore keeps all items as an indented JSON array in a single file.
+// The file is parsed once into an in-memory index keyed by ID, which is
+// kept in sync on writes and reloaded when the file is replaced or
+// modified on disk by someone else. Deferred stores only change the
+// index on writes and rewrite the file at checkpoints.
+type JSONStore struct {
+	path string
+
+	mu       sync.Mutex
+	items    []Item
+	index    map[string]int
+	sorted   sortedIDs
+	info     os.FileInfo // stat of the file the cache was loaded from, nil if none
+	deferred bool
+	dirty    bool // the cache holds changes the file does not
+	reads    int  // times the cache was replaced by the content on disk
+}
+
+func NewJSONStore(path string) *JSONStore {
+	return &JSONStore{path: path}
+}
+
+func (s *JSONStore) Get(id string) (Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return Item{}, err
+	}
+
+	i, ok := s.index[id]
+	if !ok {
+		return Item{}, ErrNotFound
+	}
+	return cloneItem(s.items[i]), nil
+}
+
+func (s *JSONStore) Put(item Item) error {
+	return s.Apply(Batch{Puts: []Item{item}})
+}
+
+func (s *JSONStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	if _, ok := s.index[id]; !ok {
+		return ErrNotFound
+	}
+
+	return s.apply(Batch{Deletes: []string{id}})
+}
+
+func (s *JSONStore) List() ([]Item, error) {
+	return s.Query(nil)
+}
+
+func (s *JSONStore) Query(match func(Item) bool) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	items := []Item{}
+	for _, item := range s.items {
+		if match == nil || match(item) {
+			items = append(items, cloneItem(item))
+		}
+	}
+	return items, nil
+}
+
+// Prefix returns the items whose ID starts with prefix, ordered by ID
+func (s *JSONStore) Prefix(prefix string) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	ids := s.sorted.withPrefix(prefix)
+	items := make([]Item, len(ids))
+	for i, id := range ids {
+		items[i] = cloneItem(s.items[s.index[id]])
+	}
+	return items, nil
+}
+
+// After returns up to limit items following the ID id, ordered by ID
+func (s *JSONStore) After(id string, limit int) ([]Item, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+
+	ids := s.sorted.after(id, limit)
+	items := make([]Item, len(ids))
+	for i, id := range ids {
+		items[i] = cloneItem(s.items[s.index[id]])
+	}
+	return items, nil
+}
+
+// Apply rewrites the file once with all changes of the batch
+func (s *JSONStore) Apply(b Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return err
+	}
+	return s.apply(b)
+}
+
+// apply writes the batch to disk, unless deferred, and then to the cache.
+// Callers hold s.mu and have called load.
+func (s *JSONStore) apply(b Batch) error {
+	items := make([]Item, 0, len(s.items)+len(b.Puts))
+	deleted := map[string]bool{}
+	sorted := append(sortedIDs(nil), s.sorted...)
+	for _, id := range b.Deletes {
+		deleted[id] = true
+		sorted = sorted.remove(id)
+	}
+	for _, item := range s.items {
+		if !deleted[item.ID] {
+			items = append(items, item)
+		}
+	}
+
+	index := indexItems(items)
+	for _, item := range b.Puts {
+		item = cloneItem(item)
+		if i, ok := index[item.ID]; ok {
+			items[i] = item
+			continue
+		}
+		index[item.ID] = len(items)
+		items = append(items, item)
+		sorted = sorted.insert(item.ID)
+	}
+
+	if s.deferred {
+		s.dirty = true
+	} else if err := s.write(items); err != nil {
+		// The file may or may not have been replaced, reload on next access
+		s.info = nil
+		return err
+	}
+
+	s.items = items
+	s.index = index
+	s.sorted = sorted
+	return nil
+}
+
+// load refreshes the cache if the file changed since it was last read,
+// callers hold s.mu. Writes always replace the file, so a new inode, size
+// or mtime means another process has written it. Deferred changes are
+// newer than the file and kept.
+func (s *JSONStore) load() error {
+	if s.dirty {
+		return nil
+	}
+
+	info, err := os.Stat(s.path)
+	if os.IsNotExist(err) {
+		if s.info != nil {
+			s.reads++
+		}
+		s.items, s.index, s.sorted, s.info = []Item{}, map[string]int{}, nil, nil
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if s.info != nil && os.SameFile(s.info, info) &&
+		s.info.ModTime().Equal(info.ModTime()) && s.info.Size() == info.Size() {
+		return nil
+	}
+
+	items, err := s.read()
+	if err != nil {
+		return err
+	}
+
+	s.items = items
+	s.index = indexItems(items)
+	s.sorted = newSortedIDs(items)
+	s.info = info
+	s.reads++
+	return nil
+}
+
+// loads returns how often the cache was read from disk, after reading it
+// again if the file changed
+func (s *JSONStore) loads() (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.load(); err != nil {
+		return 0, err
+	}
+	return s.reads, nil
+}
+
+// read loads the item array from file
+func (s *JSONStore) read() ([]Item, error) {
+	content, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return []Item{}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	err = json.Unmarshal(content, &items)
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+// write atomically replaces the file with the given items and remembers
+// the stat of the new file so our own writes do not trigger a reload
+func (s *JSONStore) write(items []Item) error {
+	content, err := json.MarshalIndent(items, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := atomicfile.WriteFile(s.path, content, 0644); err != nil {
+		return err
+	}
+
+	info, err := os.Stat(s.path)
+	if err != nil {
+		return err
+	}
+	s.info = info
+	return nil
+}
+
+// flush writes deferred changes to the file
+func (s *JSONStore) flush() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if !s.dirty {
+		return nil
+	}
+	if err := s.write(s.items); err != nil {
+		return err
+	}
+	s.dirty = false
+	return nil
+}
+
+func indexItems(items []Item) map[string]int {
+	index := make(map[string]int, len(items))
+	for i, item := range items {
+		index[item.ID] = i
+	}
+	return index
+}
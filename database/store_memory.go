@@ -0,0 +1,176 @@
+package database
+
+import (
+	"sort"
+	"sync"
+)
+
+// MemoryBackend keeps every namespace in process memory, mainly for tests
+type MemoryBackend struct {
+	mu     sync.Mutex
+	stores map[string]*MemoryStore
+}
+
+func NewMemoryBackend() *MemoryBackend {
+	return &MemoryBackend{stores: map[string]*MemoryStore{}}
+}
+
+func (b *MemoryBackend) Store(ns string) (Store, error) {
+	if err := validNamespace(ns); err != nil {
+		return nil, err
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	s, ok := b.stores[ns]
+	if !ok {
+		s = NewMemoryStore()
+		b.stores[ns] = s
+	}
+	return s, nil
+}
+
+func (b *MemoryBackend) Namespaces() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	namespaces := []string{""}
+	for ns := range b.stores {
+		if ns != "" {
+			namespaces = append(namespaces, ns)
+		}
+	}
+	sort.Strings(namespaces)
+	return namespaces, nil
+}
+
+func (b *MemoryBackend) Drop(ns string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if ns != "" {
+		delete(b.stores, ns)
+	}
+	return nil
+}
+
+func (b *MemoryBackend) Close() error {
+	return nil
+}
+
+// MemoryStore keeps the items of one namespace in process memory
+type MemoryStore struct {
+	mu     sync.RWMutex
+	items  []Item
+	index  map[string]int
+	sorted sortedIDs
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{index: map[string]int{}}
+}
+
+func (s *MemoryStore) Get(id string) (Item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	i, ok := s.index[id]
+	if !ok {
+		return Item{}, ErrNotFound
+	}
+	return cloneItem(s.items[i]), nil
+}
+
+func (s *MemoryStore) Put(item Item) error {
+	return s.Apply(Batch{Puts: []Item{item}})
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.index[id]; !ok {
+		return ErrNotFound
+	}
+	s.remove(id)
+	return nil
+}
+
+func (s *MemoryStore) List() ([]Item, error) {
+	return s.Query(nil)
+}
+
+func (s *MemoryStore) Query(match func(Item) bool) ([]Item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	items := []Item{}
+	for _, item := range s.items {
+		if match == nil || match(item) {
+			items = append(items, cloneItem(item))
+		}
+	}
+	return items, nil
+}
+
+// Prefix returns the items whose ID starts with prefix, ordered by ID
+func (s *MemoryStore) Prefix(prefix string) ([]Item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.sorted.withPrefix(prefix)
+	items := make([]Item, len(ids))
+	for i, id := range ids {
+		items[i] = cloneItem(s.items[s.index[id]])
+	}
+	return items, nil
+}
+
+// After returns up to limit items following the ID id, ordered by ID
+func (s *MemoryStore) After(id string, limit int) ([]Item, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	ids := s.sorted.after(id, limit)
+	items := make([]Item, len(ids))
+	for i, id := range ids {
+		items[i] = cloneItem(s.items[s.index[id]])
+	}
+	return items, nil
+}
+
+func (s *MemoryStore) Apply(b Batch) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, id := range b.Deletes {
+		s.remove(id)
+	}
+	for _, item := range b.Puts {
+		item = cloneItem(item)
+		if i, ok := s.index[item.ID]; ok {
+			s.items[i] = item
+			continue
+		}
+		s.index[item.ID] = len(s.items)
+		s.items = append(s.items, item)
+		s.sorted = s.sorted.insert(item.ID)
+	}
+	return nil
+}
+
+// remove deletes id keeping insertion order, callers hold s.mu
+func (s *MemoryStore) remove(id string) {
+	i, ok := s.index[id]
+	if !ok {
+		return
+	}
+
+	s.items = append(s.items[:i], s.items[i+1:]...)
+	s.sorted = s.sorted.remove(id)
+	delete(s.index, id)
+	for j := i; j < len(s.items); j++ {
+		s.index[s.items[j].ID] = j
+	}
+}
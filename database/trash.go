@@ -0,0 +1,383 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"log"
+	"sort"
+	"strings"
+	"time"
+)
+
+// trashNamespace holds deleted items until they are undeleted or purged,
+// keyed by "<collection>/<id>" like the revision history
+const trashNamespace = "_trash"
+
+// DefaultTrashRetention is how long deleted items stay recoverable unless configured otherwise
+const DefaultTrashRetention = 30 * 24 * time.Hour
+
+// sweeperAuthor is recorded in the revisions of items removed on expiry
+const sweeperAuthor = "system:expiry"
+
+// RevUndelete is the revision operation of an item taken back out of the trash
+const RevUndelete = "undelete"
+
+// TrashEntry is a deleted item waiting in the trash
+type TrashEntry struct {
+	Item      Item      `json:"item"`
+	DeletedAt time.Time `json:"deleted_at"`
+	DeletedBy string    `json:"deleted_by,omitempty"`
+}
+
+// Trash moves an item of this namespace to the trash, applying the
+// on_delete rules of the references to it
+func (tx *Tx) Trash(id string) error {
+	cascade, err := tx.releaseReferences(id)
+	if err != nil {
+		return err
+	}
+	for ns, ids := range cascade {
+		for _, cid := range ids {
+			if err := tx.In(ns).trash(cid); err != nil {
+				return err
+			}
+		}
+	}
+	return tx.trash(id)
+}
+
+func (tx *Tx) trash(id string) error {
+	item, found, err := tx.get(id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	if err := tx.Delete(id); err != nil {
+		return err
+	}
+
+	entry := TrashEntry{Item: item, DeletedAt: time.Now().UTC(), DeletedBy: tx.author}
+	raw, err := toData(entry)
+	if err != nil {
+		return err
+	}
+	tx.In(trashNamespace).put(Item{ID: itemKey(tx.ns, id), Data: raw})
+	tx.index(indexKey(indexDeleted, entry.DeletedAt, tx.ns, id), id)
+	return nil
+}
+
+// Undelete takes an item of this namespace back out of the trash, failing
+// with ErrConflict if its ID was reused in the meantime
+func (tx *Tx) Undelete(id string) (Item, error) {
+	entry, err := tx.trashEntry(id)
+	if err != nil {
+		return Item{}, err
+	}
+
+	// insert gives it a new version, so ETags from before the delete do
+	// not match. An expiry that has passed is cleared, or the item would
+	// expire again.
+	item := entry.Item
+	if item.ExpiresAt != nil && !item.ExpiresAt.After(time.Now()) {
+		item.ExpiresAt = nil
+	}
+	if err := tx.insert(item, RevUndelete); err != nil {
+		return Item{}, err
+	}
+	item, _, err = tx.Get(id)
+	return item, err
+}
+
+// TrashEntries returns the deleted items of this namespace, most recently deleted first
+func (tx *Tx) TrashEntries() ([]TrashEntry, error) {
+	items, err := tx.In(trashNamespace).Prefix(itemKey(tx.ns, ""))
+	if err != nil {
+		return nil, err
+	}
+
+	entries := make([]TrashEntry, 0, len(items))
+	for _, item := range items {
+		var entry TrashEntry
+		if err := fromData(item.Data, &entry); err != nil {
+			return nil, err
+		}
+		entries = append(entries, entry)
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].DeletedAt.After(entries[j].DeletedAt)
+	})
+	return entries, nil
+}
+
+// Purge removes an item of this namespace from the trash for good
+func (tx *Tx) Purge(id string) error {
+	entry, err := tx.trashEntry(id)
+	if err != nil {
+		return err
+	}
+	if err := tx.unindex(indexKey(indexDeleted, entry.DeletedAt, tx.ns, id)); err != nil {
+		return err
+	}
+	if err := tx.In(trashNamespace).Delete(itemKey(tx.ns, id)); err != nil {
+		return err
+	}
+	return tx.forgetReferences(id)
+}
+
+func (tx *Tx) trashEntry(id string) (TrashEntry, error) {
+	var entry TrashEntry
+
+	item, found, err := tx.In(trashNamespace).Get(itemKey(tx.ns, id))
+	if err != nil {
+		return entry, err
+	}
+	if !found {
+		return entry, ErrNotFound
+	}
+
+	err = fromData(item.Data, &entry)
+	return entry, err
+}
+
+// forgetTrash drops the trash entry of an ID that is live again
+func (tx *Tx) forgetTrash(id string) error {
+	if isReserved(tx.ns) {
+		return nil
+	}
+
+	entry, err := tx.trashEntry(id)
+	if err == ErrNotFound {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err := tx.unindex(indexKey(indexDeleted, entry.DeletedAt, tx.ns, id)); err != nil {
+		return err
+	}
+	return tx.In(trashNamespace).Delete(itemKey(tx.ns, id))
+}
+
+// dropTrash deletes the trash entries of a collection
+func (tx *Tx) dropTrash(collection string) error {
+	trash := tx.In(trashNamespace)
+	items, err := trash.Prefix(collection + "/")
+	if err != nil {
+		return err
+	}
+
+	for _, item := range items {
+		if err := trash.Delete(item.ID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ListTrash returns the deleted items of a collection, most recently deleted first
+func ListTrash(collection string) ([]TrashEntry, error) {
+	var entries []TrashEntry
+
+	err := View(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		var err error
+		entries, err = tx.TrashEntries()
+		return err
+	})
+
+	return entries, err
+}
+
+// UndeleteItem takes a deleted item back out of the trash
+func UndeleteItem(collection, id, author string) (*Item, error) {
+	var item Item
+
+	err := Update(func(tx *Tx) error {
+		tx.SetAuthor(author)
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		var err error
+		item, err = tx.Undelete(id)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &item, nil
+}
+
+// PurgeItem removes a deleted item from the trash for good
+func PurgeItem(collection, id string) error {
+	return Update(func(tx *Tx) error {
+		tx = tx.In(collection)
+		if err := tx.requireCollection(); err != nil {
+			return err
+		}
+
+		return tx.Purge(id)
+	})
+}
+
+// sweepBatch is how many due index entries Sweep handles per transaction
+const sweepBatch = 100
+
+// Sweep moves items whose expires_at has passed to the trash and purges
+// trash entries deleted longer than retention ago. It reads the due
+// entries of the expiry index in short transactions, so writers wait for
+// one batch at most and items that are not due are never visited.
+func Sweep(now time.Time, retention time.Duration) (expired, purged int, err error) {
+	if err := buildExpiryIndex(); err != nil {
+		return 0, 0, err
+	}
+
+	expired, err = sweepDue(indexExpires, now, func(tx *Tx, key, id string) (bool, error) {
+		item, found, err := tx.get(id)
+		if err != nil {
+			return false, err
+		}
+		if !found || item.ExpiresAt == nil || indexKey(indexExpires, *item.ExpiresAt, tx.ns, id) != key {
+			return false, tx.unindex(key)
+		}
+
+		// Restricted items stay until their referrers are gone, cascades
+		// may have taken others already
+		err = tx.Trash(id)
+		var rerr *ReferencedError
+		if errors.As(err, &rerr) || err == ErrNotFound {
+			return false, nil
+		}
+		return err == nil, err
+	})
+	if err != nil {
+		return expired, 0, err
+	}
+
+	purged, err = sweepDue(indexDeleted, now.Add(-retention), func(tx *Tx, key, id string) (bool, error) {
+		entry, err := tx.trashEntry(id)
+		if err == ErrNotFound {
+			return false, tx.unindex(key)
+		}
+		if err != nil {
+			return false, err
+		}
+		if indexKey(indexDeleted, entry.DeletedAt, tx.ns, id) != key {
+			return false, tx.unindex(key)
+		}
+		return true, tx.Purge(id)
+	})
+	return expired, purged, err
+}
+
+// sweepDue calls fn for the index entries of kind up to until, oldest
+// first, in transactions of sweepBatch entries. fn reports whether it
+// removed the item, entries of dropped collections are discarded.
+func sweepDue(kind string, until time.Time, fn func(tx *Tx, key, id string) (bool, error)) (int, error) {
+	prefix := kind + "/"
+	bound := prefix + until.UTC().Format(indexTime)
+	after := prefix
+	total := 0
+
+	for {
+		done := false
+		n := 0
+		err := Update(func(tx *Tx) error {
+			tx.SetAuthor(sweeperAuthor)
+			index := tx.In(expiryNamespace)
+			entries, err := index.After(after, sweepBatch)
+			if err != nil {
+				return err
+			}
+			done = len(entries) < sweepBatch
+
+			for _, e := range entries {
+				if !strings.HasPrefix(e.ID, prefix) || e.ID[:len(bound)] > bound {
+					done = true
+					return nil
+				}
+				after = e.ID
+
+				ns, _ := e.Data["collection"].(string)
+				id, _ := e.Data["id"].(string)
+				view := tx.In(ns)
+				if err := view.requireCollection(); err == ErrCollectionNotFound {
+					if err := index.Delete(e.ID); err != nil {
+						return err
+					}
+					continue
+				} else if err != nil {
+					return err
+				}
+
+				removed, err := fn(view, e.ID, id)
+				if err != nil {
+					return err
+				}
+				if removed {
+					n++
+				}
+			}
+			return nil
+		})
+		if err != nil {
+			return total, err
+		}
+		total += n
+		if done {
+			return total, nil
+		}
+	}
+}
+
+// ScheduleSweeper runs Sweep every interval until stop is closed
+func ScheduleSweeper(interval, retention time.Duration, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		expired, purged, err := Sweep(time.Now().UTC(), retention)
+		if err != nil {
+			log.Printf("sweep failed: %v", err)
+			continue
+		}
+		if expired > 0 || purged > 0 {
+			log.Printf("sweep: %d items expired, %d purged from trash", expired, purged)
+		}
+	}
+}
+
+// toData converts a value to the data of an internal item
+func toData(v interface{}) (map[string]interface{}, error) {
+	raw, err := json.Marshal(v)
+	if err != nil {
+		return nil, err
+	}
+	var data map[string]interface{}
+	err = json.Unmarshal(raw, &data)
+	return data, err
+}
+
+// fromData converts the data of an internal item back to a value
+func fromData(data map[string]interface{}, v interface{}) error {
+	raw, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(raw, v)
+}
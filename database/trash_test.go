@@ -0,0 +1,238 @@
+package database
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+// indexEntries returns the keys of the expiry index entries of kind
+func indexEntries(t *testing.T, kind string) []string {
+	t.Helper()
+	keys := []string{}
+	err := View(func(tx *Tx) error {
+		items, err := tx.In(expiryNamespace).Prefix(kind + "/")
+		for _, item := range items {
+			keys = append(keys, item.ID)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return keys
+}
+
+func TestExpiredItemsAreHidden(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	past := time.Now().Add(-time.Minute)
+	future := time.Now().Add(time.Hour)
+	createItems(t,
+		Item{ID: "a", ExpiresAt: &past, Data: map[string]interface{}{"n": 1.0}},
+		Item{ID: "b", ExpiresAt: &future, Data: map[string]interface{}{"n": 1.0}},
+		Item{ID: "c", Data: map[string]interface{}{"n": 1.0}},
+	)
+
+	if _, err := GetItem("", "a"); err != ErrNotFound {
+		t.Errorf("getting an expired item: err = %v, want ErrNotFound", err)
+	}
+	if _, err := ReplaceItem("", "a", map[string]interface{}{"n": 2.0}, WriteOptions{}); err != ErrNotFound {
+		t.Errorf("replacing an expired item: err = %v, want ErrNotFound", err)
+	}
+
+	page, err := Find("", parseQuery(t, "data.n=1"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := itemIDs(page.Items); !reflect.DeepEqual(got, []string{"b", "c"}) {
+		t.Errorf("Find = %v, want [b c]", got)
+	}
+
+	var each []string
+	err = EachItem("", Query{}, func(item Item) error {
+		each = append(each, item.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(each, []string{"b", "c"}) {
+		t.Errorf("EachItem = %v, want [b c]", each)
+	}
+}
+
+func TestSweepExpiresDueItems(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	now := time.Now().UTC()
+	past, soon, later := now.Add(-time.Minute), now.Add(time.Hour), now.Add(2*time.Hour)
+	createItems(t,
+		Item{ID: "a", ExpiresAt: &past, Data: map[string]interface{}{}},
+		Item{ID: "b", ExpiresAt: &soon, Data: map[string]interface{}{}},
+		Item{ID: "c", ExpiresAt: &later, Data: map[string]interface{}{}},
+		Item{ID: "d", Data: map[string]interface{}{}},
+	)
+
+	// moving the expiry of c moves its index entry
+	if _, err := ReplaceItem("", "c", map[string]interface{}{}, WriteOptions{SetExpiry: true}); err != nil {
+		t.Fatal(err)
+	}
+	if got := len(indexEntries(t, indexExpires)); got != 2 {
+		t.Errorf("%d expiry entries, want 2", got)
+	}
+
+	expired, purged, err := Sweep(now, DefaultTrashRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 || purged != 0 {
+		t.Errorf("sweep expired %d and purged %d, want 1 and 0", expired, purged)
+	}
+	want := []string{indexKey(indexExpires, soon, "", "b")}
+	if got := indexEntries(t, indexExpires); !reflect.DeepEqual(got, want) {
+		t.Errorf("expiry entries after the sweep = %v, want %v", got, want)
+	}
+
+	trash, err := ListTrash("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 1 || trash[0].Item.ID != "a" || trash[0].DeletedBy != sweeperAuthor {
+		t.Errorf("trash = %+v, want a deleted by the sweeper", trash)
+	}
+
+	expired, _, err = Sweep(now.Add(3*time.Hour), DefaultTrashRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 {
+		t.Errorf("second sweep expired %d items, want 1", expired)
+	}
+	for _, id := range []string{"c", "d"} {
+		if _, err := GetItem("", id); err != nil {
+			t.Errorf("getting %s after the sweeps: %v", id, err)
+		}
+	}
+}
+
+func TestSweepBuildsMissingIndex(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	past := time.Now().Add(-time.Minute)
+	createItems(t, Item{ID: "a", ExpiresAt: &past, Data: map[string]interface{}{}})
+	if err := DeleteItem("", "a", WriteOptions{}); err == nil {
+		t.Fatal("deleted an expired item")
+	}
+
+	// a database from before the index
+	err := Update(func(tx *Tx) error {
+		index := tx.In(expiryNamespace)
+		items, err := index.List()
+		if err != nil {
+			return err
+		}
+		for _, item := range items {
+			if err := index.Delete(item.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expired, _, err := Sweep(time.Now(), DefaultTrashRetention)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if expired != 1 {
+		t.Errorf("sweep expired %d items, want 1", expired)
+	}
+	if got := indexEntries(t, indexDeleted); len(got) != 1 {
+		t.Errorf("trash entries in the index = %v, want 1", got)
+	}
+}
+
+func TestTrashRestoreAndPurge(t *testing.T) {
+	useBackend(t, NewMemoryBackend())
+
+	past := time.Now().Add(-time.Minute)
+	createItems(t,
+		Item{ID: "a", Data: map[string]interface{}{"name": "pump"}},
+		Item{ID: "b", ExpiresAt: &past, Data: map[string]interface{}{}},
+	)
+	if err := DeleteItem("", "a", WriteOptions{Author: "ann"}); err != nil {
+		t.Fatal(err)
+	}
+	if _, _, err := Sweep(time.Now(), DefaultTrashRetention); err != nil {
+		t.Fatal(err)
+	}
+
+	trash, err := ListTrash("")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(trash) != 2 || trash[0].Item.ID != "b" || trash[1].Item.ID != "a" || trash[1].DeletedBy != "ann" {
+		t.Fatalf("trash = %+v, want b and a deleted by ann", trash)
+	}
+	if _, err := GetItem("", "a"); err != ErrNotFound {
+		t.Errorf("getting a deleted item: err = %v, want ErrNotFound", err)
+	}
+
+	item, err := UndeleteItem("", "a", "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.Data["name"] != "pump" || item.Version != 2 {
+		t.Errorf("undeleted item = %+v, want name pump at version 2", item)
+	}
+	item, err = UndeleteItem("", "b", "ann")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if item.ExpiresAt != nil {
+		t.Errorf("undeleted item still expires at %v", item.ExpiresAt)
+	}
+	if _, err := UndeleteItem("", "a", "ann"); err != ErrNotFound {
+		t.Errorf("undeleting a live item: err = %v, want ErrNotFound", err)
+	}
+	if got := indexEntries(t, indexDeleted); len(got) != 0 {
+		t.Errorf("index entries of undeleted items: %v", got)
+	}
+
+	if err := DeleteItem("", "a", WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	if err := PurgeItem("", "a"); err != nil {
+		t.Fatal(err)
+	}
+	if err := PurgeItem("", "a"); err != ErrNotFound {
+		t.Errorf("purging twice: err = %v, want ErrNotFound", err)
+	}
+	if _, err := UndeleteItem("", "a", "ann"); err != ErrNotFound {
+		t.Errorf("undeleting a purged item: err = %v, want ErrNotFound", err)
+	}
+
+	if err := DeleteItem("", "b", WriteOptions{}); err != nil {
+		t.Fatal(err)
+	}
+	_, purged, err := Sweep(time.Now().Add(time.Hour), 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if purged != 0 {
+		t.Errorf("purged %d entries within the retention", purged)
+	}
+	_, purged, err = Sweep(time.Now().Add(3*time.Hour), 2*time.Hour)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if trash, _ := ListTrash(""); purged != 1 || len(trash) != 0 {
+		t.Errorf("purged %d entries, %d left, want all purged after the retention", purged, len(trash))
+	}
+	if got := indexEntries(t, indexDeleted); len(got) != 0 {
+		t.Errorf("index entries of purged items: %v", got)
+	}
+}
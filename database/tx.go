@@ -0,0 +1,563 @@
+package database
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// mu serializes transactions within the process, backends shared with
+// other processes additionally hold their own lock for the whole transaction.
+var mu sync.RWMutex
+
+// Tx is a view of one namespace of the database inside Update or View,
+// In switches to another namespace of the same transaction.
+// Changes made through a Tx are committed once fn returns without error.
+type Tx struct {
+	*txState
+	ns string
+}
+
+type txState struct {
+	backend  Backend
+	readOnly bool
+	changes  map[string]*changeSet // by namespace
+	drops    []string              // namespaces to drop after commit
+	author   string                // recorded in the revisions written by this transaction
+	events   []Event               // published after commit
+}
+
+// changeSet holds the uncommitted changes of one namespace
+type changeSet struct {
+	puts    map[string]Item
+	order   []string // IDs in puts, in the order they were first written
+	deletes map[string]bool
+}
+
+// Update runs fn with exclusive access to the database and commits any
+// changes when fn returns nil. Returning an error discards them. Changes
+// to one namespace are applied atomically.
+func Update(fn func(tx *Tx) error) error {
+	mu.Lock()
+	defer mu.Unlock()
+
+	b := currentBackend()
+	unlock, err := lockBackend(b, true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := followWAL(b); err != nil {
+		return err
+	}
+
+	tx := &Tx{txState: &txState{backend: b, changes: map[string]*changeSet{}}}
+	if err := fn(tx); err != nil {
+		return err
+	}
+
+	return tx.commit()
+}
+
+// View runs fn with a consistent read-only view of the database
+func View(fn func(tx *Tx) error) error {
+	mu.RLock()
+	defer mu.RUnlock()
+
+	b := currentBackend()
+	unlock, err := lockBackend(b, false)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+	if err := followWAL(b); err != nil {
+		return err
+	}
+
+	return fn(&Tx{txState: &txState{backend: b, readOnly: true, changes: map[string]*changeSet{}}})
+}
+
+func lockBackend(b Backend, exclusive bool) (func(), error) {
+	if l, ok := b.(locker); ok {
+		return l.lock(exclusive)
+	}
+	return func() {}, nil
+}
+
+// followWAL applies the commits of other processes sharing the log
+func followWAL(b Backend) error {
+	if wal == nil {
+		return nil
+	}
+	return wal.follow(b)
+}
+
+// In returns a view of the namespace ns within the same transaction
+func (tx *Tx) In(ns string) *Tx {
+	return &Tx{txState: tx.txState, ns: ns}
+}
+
+// Namespace returns the namespace this view reads and writes
+func (tx *Tx) Namespace() string {
+	return tx.ns
+}
+
+func (tx *Tx) store() (Store, error) {
+	return tx.backend.Store(tx.ns)
+}
+
+func (tx *Tx) changeSet() *changeSet {
+	cs, ok := tx.changes[tx.ns]
+	if !ok {
+		cs = &changeSet{puts: map[string]Item{}, deletes: map[string]bool{}}
+		tx.changes[tx.ns] = cs
+	}
+	return cs
+}
+
+func (tx *Tx) commit() error {
+	batches := map[string]Batch{}
+	for ns, cs := range tx.changes {
+		if len(cs.puts) > 0 || len(cs.deletes) > 0 {
+			batches[ns] = cs.batch()
+		}
+	}
+	if len(batches) == 0 && len(tx.drops) == 0 {
+		return nil
+	}
+
+	if wal != nil {
+		r := walRecord{Time: time.Now().UTC(), Changes: map[string]walBatch{}, Drops: tx.drops}
+		for ns, b := range batches {
+			r.Changes[ns] = walBatch{Puts: b.Puts, Deletes: b.Deletes}
+		}
+		if err := wal.append(r); err != nil {
+			return err
+		}
+	}
+
+	for ns, b := range batches {
+		s, err := tx.backend.Store(ns)
+		if err != nil {
+			return err
+		}
+		if err := applyBatch(s, b); err != nil {
+			return err
+		}
+	}
+
+	for _, ns := range tx.drops {
+		if err := tx.backend.Drop(ns); err != nil {
+			return err
+		}
+	}
+
+	updateSearchIndexes(batches, tx.drops)
+	events.publish(tx.events)
+	return nil
+}
+
+// batch lists the puts in write order and the deletes
+func (cs *changeSet) batch() Batch {
+	var b Batch
+	for _, id := range cs.order {
+		if item, ok := cs.puts[id]; ok {
+			b.Puts = append(b.Puts, item)
+		}
+	}
+	for id := range cs.deletes {
+		b.Deletes = append(b.Deletes, id)
+	}
+	return b
+}
+
+// applyBatch writes a batch atomically if the store supports it
+func applyBatch(s Store, b Batch) error {
+	if s, ok := s.(batcher); ok {
+		return s.Apply(b)
+	}
+
+	for _, id := range b.Deletes {
+		if err := s.Delete(id); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+	for _, item := range b.Puts {
+		if err := s.Put(item); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Get returns a copy of the item with the given ID. Items whose expiry has
+// passed are not found, even before the sweeper moves them to the trash.
+func (tx *Tx) Get(id string) (Item, bool, error) {
+	item, found, err := tx.get(id)
+	if err != nil || !found || !tx.expired(item, time.Now()) {
+		return item, found, err
+	}
+	return Item{}, false, nil
+}
+
+// get returns a copy of the stored item, whether it expired or not
+func (tx *Tx) get(id string) (Item, bool, error) {
+	if cs, ok := tx.changes[tx.ns]; ok {
+		if cs.deletes[id] {
+			return Item{}, false, nil
+		}
+		if item, ok := cs.puts[id]; ok {
+			return cloneItem(item), true, nil
+		}
+	}
+
+	s, err := tx.store()
+	if err != nil {
+		return Item{}, false, err
+	}
+
+	item, err := s.Get(id)
+	if err == ErrNotFound {
+		return Item{}, false, nil
+	}
+	if err != nil {
+		return Item{}, false, err
+	}
+	return item, true, nil
+}
+
+// List returns all items including changes made in this transaction
+func (tx *Tx) List() ([]Item, error) {
+	return tx.Query(nil)
+}
+
+// Query returns the items for which match returns true, nil matches all.
+// Expired items are left out.
+func (tx *Tx) Query(match func(Item) bool) ([]Item, error) {
+	return tx.query(tx.live(match))
+}
+
+// query is Query including the expired items
+func (tx *Tx) query(match func(Item) bool) ([]Item, error) {
+	s, err := tx.store()
+	if err != nil {
+		return nil, err
+	}
+
+	cs, ok := tx.changes[tx.ns]
+	if !ok || (len(cs.puts) == 0 && len(cs.deletes) == 0) {
+		return s.Query(match)
+	}
+
+	items, err := s.List()
+	if err != nil {
+		return nil, err
+	}
+
+	merged := make([]Item, 0, len(items)+len(cs.puts))
+	seen := map[string]bool{}
+	for _, item := range items {
+		if cs.deletes[item.ID] {
+			continue
+		}
+		if put, ok := cs.puts[item.ID]; ok {
+			item = cloneItem(put)
+		}
+		seen[item.ID] = true
+		merged = append(merged, item)
+	}
+	for _, id := range cs.order {
+		if put, ok := cs.puts[id]; ok && !seen[id] {
+			merged = append(merged, cloneItem(put))
+		}
+	}
+
+	return filterItems(merged, match), nil
+}
+
+// Prefix returns the items whose ID starts with prefix, ordered by ID and
+// including changes made in this transaction. Stores that keep their IDs
+// in order answer without reading the other items.
+func (tx *Tx) Prefix(prefix string) ([]Item, error) {
+	s, err := tx.store()
+	if err != nil {
+		return nil, err
+	}
+
+	var items []Item
+	if p, ok := s.(prefixer); ok {
+		items, err = p.Prefix(prefix)
+	} else {
+		items, err = s.Query(func(item Item) bool {
+			return strings.HasPrefix(item.ID, prefix)
+		})
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	cs, ok := tx.changes[tx.ns]
+	if !ok || (len(cs.puts) == 0 && len(cs.deletes) == 0) {
+		if _, sorted := s.(prefixer); !sorted {
+			sortByID(items)
+		}
+		return tx.dropExpired(items), nil
+	}
+
+	merged := make([]Item, 0, len(items))
+	for _, item := range items {
+		if !cs.deletes[item.ID] {
+			if _, ok := cs.puts[item.ID]; !ok {
+				merged = append(merged, item)
+			}
+		}
+	}
+	for id, put := range cs.puts {
+		if strings.HasPrefix(id, prefix) {
+			merged = append(merged, cloneItem(put))
+		}
+	}
+	sortByID(merged)
+	return tx.dropExpired(merged), nil
+}
+
+// After returns up to limit items whose ID follows id, ordered by ID and
+// including changes made in this transaction. Reading from the empty ID
+// onward, page after page, visits every item once.
+func (tx *Tx) After(id string, limit int) ([]Item, error) {
+	if isReserved(tx.ns) {
+		return tx.after(id, limit)
+	}
+
+	// Expired items are left out, so a short page is read further
+	now := time.Now()
+	items := []Item{}
+	for {
+		want := limit - len(items)
+		page, err := tx.after(id, want)
+		if err != nil {
+			return nil, err
+		}
+		for _, item := range page {
+			if !tx.expired(item, now) {
+				items = append(items, item)
+			}
+		}
+		if len(page) < want || len(items) >= limit {
+			return items, nil
+		}
+		id = page[len(page)-1].ID
+	}
+}
+
+func (tx *Tx) after(id string, limit int) ([]Item, error) {
+	s, err := tx.store()
+	if err != nil {
+		return nil, err
+	}
+
+	// Deletes made in this transaction may drop items from the page
+	cs, changed := tx.changes[tx.ns]
+	want := limit
+	if changed {
+		want += len(cs.deletes)
+	}
+
+	var items []Item
+	if r, ok := s.(ranger); ok {
+		items, err = r.After(id, want)
+	} else {
+		items, err = s.Query(func(item Item) bool {
+			return item.ID > id
+		})
+		sortByID(items)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if changed && (len(cs.puts) > 0 || len(cs.deletes) > 0) {
+		merged := make([]Item, 0, len(items))
+		for _, item := range items {
+			if !cs.deletes[item.ID] {
+				if _, ok := cs.puts[item.ID]; !ok {
+					merged = append(merged, item)
+				}
+			}
+		}
+		for putID, put := range cs.puts {
+			if putID > id {
+				merged = append(merged, cloneItem(put))
+			}
+		}
+		sortByID(merged)
+		items = merged
+	}
+
+	if len(items) > limit {
+		items = items[:limit]
+	}
+	return items, nil
+}
+
+// expired reports whether an item of this namespace has passed its expiry
+// at now. Items of the reserved namespaces never expire.
+func (tx *Tx) expired(item Item, now time.Time) bool {
+	return !isReserved(tx.ns) && item.ExpiresAt != nil && !item.ExpiresAt.After(now)
+}
+
+// live narrows match to the items that have not expired
+func (tx *Tx) live(match func(Item) bool) func(Item) bool {
+	if isReserved(tx.ns) {
+		return match
+	}
+	now := time.Now()
+	return func(item Item) bool {
+		return !tx.expired(item, now) && (match == nil || match(item))
+	}
+}
+
+// dropExpired filters the expired items out of items
+func (tx *Tx) dropExpired(items []Item) []Item {
+	if isReserved(tx.ns) {
+		return items
+	}
+	return filterItems(items, tx.live(nil))
+}
+
+func sortByID(items []Item) {
+	sort.Slice(items, func(i, j int) bool {
+		return items[i].ID < items[j].ID
+	})
+}
+
+// Insert adds a new item, failing with ErrConflict if the ID is taken.
+// The item is placed in the collection of this namespace and must match its schema.
+// It keeps a version it already has if that is after every version the ID
+// had before it was deleted, and otherwise continues after the last one,
+// starting at 1 for new IDs. ETags of the deleted item never match again.
+func (tx *Tx) Insert(item Item) error {
+	return tx.insert(item, RevCreate)
+}
+
+// Put replaces an existing item, failing with ErrNotFound if there is none.
+// The item must match the schema of its collection and gets the next version.
+func (tx *Tx) Put(item Item) error {
+	return tx.replace(item, RevUpdate)
+}
+
+// write inserts or replaces an item, recording op as the revision operation
+func (tx *Tx) write(item Item, exists bool, op string) error {
+	if exists {
+		return tx.replace(item, op)
+	}
+	return tx.insert(item, op)
+}
+
+func (tx *Tx) insert(item Item, op string) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
+
+	item.Collection = tx.collectionName()
+	if err := tx.validate(item); err != nil {
+		return err
+	}
+
+	_, found, err := tx.Get(item.ID)
+	if err != nil {
+		return err
+	}
+	if found {
+		return ErrConflict
+	}
+
+	last, err := tx.lastVersion(item.ID)
+	if err != nil {
+		return err
+	}
+	if item.Version <= last {
+		item.Version = last + 1
+	}
+	if err := tx.indexExpiry(item.ID, item.ExpiresAt); err != nil {
+		return err
+	}
+	tx.put(item)
+	if err := tx.forgetTrash(item.ID); err != nil {
+		return err
+	}
+	return tx.recordRevision(op, item)
+}
+
+func (tx *Tx) replace(item Item, op string) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
+
+	current, found, err := tx.Get(item.ID)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	item.Collection = tx.collectionName()
+	item.Version = current.Version + 1
+	if err := tx.validate(item); err != nil {
+		return err
+	}
+
+	if err := tx.indexExpiry(item.ID, item.ExpiresAt); err != nil {
+		return err
+	}
+	tx.put(item)
+	return tx.recordRevision(op, item)
+}
+
+// Delete removes an item, failing with ErrNotFound if there is none.
+// Expired items the sweeper has not trashed yet can still be deleted.
+func (tx *Tx) Delete(id string) error {
+	if tx.readOnly {
+		return ErrReadOnly
+	}
+
+	item, found, err := tx.get(id)
+	if err != nil {
+		return err
+	}
+	if !found {
+		return ErrNotFound
+	}
+	if err := tx.indexExpiry(id, nil); err != nil {
+		return err
+	}
+
+	cs := tx.changeSet()
+	delete(cs.puts, id)
+	cs.deletes[id] = true
+	return tx.recordRevision(RevDelete, item)
+}
+
+func (tx *Tx) put(item Item) {
+	cs := tx.changeSet()
+	if _, ok := cs.puts[item.ID]; !ok {
+		cs.order = append(cs.order, item.ID)
+	}
+	delete(cs.deletes, item.ID)
+	cs.puts[item.ID] = cloneItem(item)
+}
+
+// collectionName is the collection items of this namespace belong to,
+// empty for the default and the reserved namespaces
+func (tx *Tx) collectionName() string {
+	if isReserved(tx.ns) {
+		return ""
+	}
+	return tx.ns
+}
+
+func isReserved(ns string) bool {
+	return len(ns) > 0 && ns[0] == '_'
+}
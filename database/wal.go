@@ -0,0 +1,481 @@
+package database
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// WALFile is where the write-ahead log is kept unless configured otherwise
+const WALFile = "./database/wal.log"
+
+// ErrCorruptWAL is returned when a record in the middle of the log is damaged
+var ErrCorruptWAL = errors.New("corrupt write-ahead log")
+
+// walHeader is the size of the length and checksum in front of each record
+const walHeader = 8
+
+var crcTable = crc32.MakeTable(crc32.Castagnoli)
+
+// wal receives every commit before it is applied, nil disables logging
+var wal *WAL
+
+// WAL is an append-only log of committed transactions. Every record is
+// fsynced before the transaction is applied to the backend, so a crash in
+// between is repaired by replaying the log. Records are framed as a 4 byte
+// big-endian length, a 4 byte CRC-32C of the payload and the JSON payload.
+//
+// The log is the commit path of JSON backends: commits only change their
+// in-memory stores and the files are rewritten at checkpoints, see
+// Compact. The first record names the generation of the log, which starts
+// at every checkpoint. Processes sharing the database apply the records
+// appended by the others before each transaction, and reread the files
+// when the generation changed.
+type WAL struct {
+	f *os.File
+
+	mu     sync.Mutex // guards the fields below, views follow the log concurrently
+	gen    string     // generation this process has read
+	start  int64      // end of the generation record
+	offset int64      // end of the records applied to the backend
+}
+
+// walRecord holds the changes of one transaction by namespace, or names
+// the generation of the log
+type walRecord struct {
+	Time       time.Time           `json:"time"`
+	Generation string              `json:"generation,omitempty"`
+	Changes    map[string]walBatch `json:"changes,omitempty"`
+	Drops      []string            `json:"drops,omitempty"`
+}
+
+type walBatch struct {
+	Puts    []Item   `json:"puts,omitempty"`
+	Deletes []string `json:"deletes,omitempty"`
+}
+
+// OpenWAL opens or creates the log at path. Records are always appended
+// at the end, so other processes sharing the database can share the log.
+// A torn final record, left by a crash during an append, is truncated
+// while holding the backend lock. Damage before the last record fails
+// with ErrCorruptWAL.
+func OpenWAL(path string) (*WAL, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	unlock, err := lockBackend(currentBackend(), true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	f, err := os.OpenFile(path, os.O_RDWR|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+	w := &WAL{f: f}
+
+	end, err := readWAL(f, 0, nil)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	stat, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	if end < stat.Size() {
+		log.Printf("write-ahead log: truncating torn record, %d bytes at offset %d", stat.Size()-end, end)
+		if err := f.Truncate(end); err != nil {
+			f.Close()
+			return nil, err
+		}
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+
+	if end == 0 {
+		if err := w.truncate(); err != nil {
+			f.Close()
+			return nil, err
+		}
+	}
+	return w, nil
+}
+
+// Close closes the log file
+func (w *WAL) Close() error {
+	return w.f.Close()
+}
+
+// Size returns the length of the log in bytes
+func (w *WAL) Size() (int64, error) {
+	stat, err := w.f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return stat.Size(), nil
+}
+
+// empty reports whether the log holds no transactions
+func (w *WAL) empty() (bool, error) {
+	size, err := w.Size()
+	if err != nil {
+		return false, err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return size <= w.start, nil
+}
+
+// Recover brings the current backend up to date after a start and then
+// logs every commit to w. A memory backend is first loaded from the newest
+// valid snapshot in dir, the log then replays every transaction since the
+// last compaction. Replaying is idempotent, records hold whole items.
+// JSON backends keep the replayed and later changes in memory from now on.
+func Recover(w *WAL, dir string) (int, error) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	b := currentBackend()
+	unlock, err := lockBackend(b, true)
+	if err != nil {
+		return 0, err
+	}
+	defer unlock()
+
+	if _, ok := b.(*MemoryBackend); ok {
+		if err := loadNewestSnapshot(b, dir); err != nil {
+			return 0, err
+		}
+	}
+	if cp, ok := b.(checkpointer); ok {
+		cp.deferWrites()
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	replayed := 0
+	w.offset, err = readWAL(w.f, 0, func(r walRecord, end int64) error {
+		if r.Generation != "" {
+			w.gen, w.start = r.Generation, end
+			return nil
+		}
+		replayed++
+		return r.apply(b)
+	})
+	if err != nil {
+		return replayed, err
+	}
+
+	resetSearchIndexes()
+	wal = w
+	return replayed, nil
+}
+
+// Compact checkpoints the backend and empties the log. A memory backend is
+// written to a snapshot in dir, JSON backends rewrite the files of their
+// changed stores. No transaction runs in between, so the backend holds
+// everything the log held. The snapshot is zero unless one was taken.
+func Compact(dir string) (SnapshotInfo, error) {
+	var info SnapshotInfo
+
+	err := Update(func(tx *Tx) error {
+		var err error
+		if _, ok := tx.backend.(*MemoryBackend); ok {
+			info, err = tx.snapshot(dir)
+		} else if cp, ok := tx.backend.(checkpointer); ok {
+			err = cp.checkpoint()
+		}
+		if err != nil || wal == nil {
+			return err
+		}
+		return wal.truncate()
+	})
+
+	return info, err
+}
+
+// ScheduleCompaction compacts the log every interval until stop is closed,
+// skipping logs without transactions. Snapshots taken for a memory backend
+// are pruned by r. Failures are logged and retried at the next tick.
+func ScheduleCompaction(dir string, interval time.Duration, r Retention, stop <-chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+		}
+
+		if w := wal; w != nil {
+			if empty, err := w.empty(); err != nil || empty {
+				continue
+			}
+		}
+
+		info, err := Compact(dir)
+		if err != nil {
+			log.Printf("write-ahead log compaction failed: %v", err)
+			continue
+		}
+		if info.Name == "" {
+			log.Printf("write-ahead log checkpointed")
+			continue
+		}
+		log.Printf("write-ahead log compacted into %s", info.Name)
+
+		if _, err := PruneSnapshots(dir, r); err != nil {
+			log.Printf("snapshot pruning failed: %v", err)
+		}
+	}
+}
+
+// follow applies the records other processes appended since this one last
+// read the log, callers hold the backend lock. A new generation means
+// another process checkpointed, the backend then rereads its files, which
+// hold everything before it.
+func (w *WAL) follow(b Backend) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	gen, start, err := readGeneration(w.f)
+	if err != nil {
+		return err
+	}
+	if gen != w.gen {
+		if cp, ok := b.(checkpointer); ok {
+			cp.reload()
+		}
+		resetSearchIndexes()
+		w.gen, w.start, w.offset = gen, start, start
+	}
+
+	size, err := w.Size()
+	if err != nil || size <= w.offset {
+		return err
+	}
+
+	end, err := readWAL(w.f, w.offset, func(r walRecord, _ int64) error {
+		if err := r.apply(b); err != nil {
+			return err
+		}
+		updateSearchIndexes(r.batches(), r.Drops)
+		return nil
+	})
+	w.offset = end
+	return err
+}
+
+// append writes and fsyncs one record, callers hold the backend lock and
+// have followed the log. A torn record left by a crashed process is cut
+// off first, so the new record does not land behind it.
+func (w *WAL) append(r walRecord) error {
+	payload, err := json.Marshal(r)
+	if err != nil {
+		return err
+	}
+
+	buf := make([]byte, walHeader+len(payload))
+	binary.BigEndian.PutUint32(buf[0:4], uint32(len(payload)))
+	binary.BigEndian.PutUint32(buf[4:8], crc32.Checksum(payload, crcTable))
+	copy(buf[walHeader:], payload)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	size, err := w.Size()
+	if err != nil {
+		return err
+	}
+	if size > w.offset {
+		log.Printf("write-ahead log: truncating torn record, %d bytes at offset %d", size-w.offset, w.offset)
+		if err := w.f.Truncate(w.offset); err != nil {
+			return err
+		}
+	}
+
+	if _, err := w.f.Write(buf); err != nil {
+		return err
+	}
+	if err := w.f.Sync(); err != nil {
+		return err
+	}
+	w.offset += int64(len(buf))
+	return nil
+}
+
+// truncate empties the log and starts a new generation, callers hold the
+// backend lock
+func (w *WAL) truncate() error {
+	var id [8]byte
+	if _, err := rand.Read(id[:]); err != nil {
+		return err
+	}
+
+	if err := w.f.Truncate(0); err != nil {
+		return err
+	}
+	w.mu.Lock()
+	w.offset = 0
+	w.mu.Unlock()
+
+	gen := hex.EncodeToString(id[:])
+	if err := w.append(walRecord{Time: time.Now().UTC(), Generation: gen}); err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.gen, w.start = gen, w.offset
+	return nil
+}
+
+// readGeneration returns the generation named by the first record of f
+// and the offset after it, empty for logs written before generations
+func readGeneration(f *os.File) (string, int64, error) {
+	var gen string
+	var start int64
+	_, err := readWAL(f, 0, func(r walRecord, end int64) error {
+		gen, start = r.Generation, end
+		if gen == "" {
+			start = 0
+		}
+		return errStopWAL
+	})
+	if err != nil && err != errStopWAL {
+		return "", 0, err
+	}
+	return gen, start, nil
+}
+
+// errStopWAL ends readWAL early without failing
+var errStopWAL = errors.New("stop reading")
+
+// readWAL calls fn for each record from offset from of f and returns the
+// offset after the last complete record. A damaged record is tolerated
+// only at the end of the file.
+func readWAL(f *os.File, from int64, fn func(r walRecord, end int64) error) (int64, error) {
+	stat, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	size := stat.Size()
+
+	if _, err := f.Seek(from, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	offset := from
+	header := make([]byte, walHeader)
+	for offset < size {
+		if size-offset < walHeader {
+			return offset, nil
+		}
+		if _, err := io.ReadFull(f, header); err != nil {
+			return offset, err
+		}
+
+		length := int64(binary.BigEndian.Uint32(header[0:4]))
+		end := offset + walHeader + length
+		if end > size {
+			return offset, nil
+		}
+
+		payload := make([]byte, length)
+		if _, err := io.ReadFull(f, payload); err != nil {
+			return offset, err
+		}
+
+		var r walRecord
+		if crc32.Checksum(payload, crcTable) != binary.BigEndian.Uint32(header[4:8]) || json.Unmarshal(payload, &r) != nil {
+			if end == size {
+				return offset, nil
+			}
+			return offset, fmt.Errorf("%w: bad record at offset %d", ErrCorruptWAL, offset)
+		}
+
+		if fn != nil {
+			if err := fn(r, end); err != nil {
+				return offset, err
+			}
+		}
+		offset = end
+	}
+	return offset, nil
+}
+
+// batches returns the changes of the record as the batches of a commit
+func (r walRecord) batches() map[string]Batch {
+	batches := make(map[string]Batch, len(r.Changes))
+	for ns, b := range r.Changes {
+		batches[ns] = Batch{Puts: b.Puts, Deletes: b.Deletes}
+	}
+	return batches
+}
+
+// apply writes the record to a backend
+func (r walRecord) apply(b Backend) error {
+	for ns, batch := range r.Changes {
+		s, err := b.Store(ns)
+		if err != nil {
+			return err
+		}
+		if err := applyBatch(s, Batch{Puts: batch.Puts, Deletes: batch.Deletes}); err != nil {
+			return err
+		}
+	}
+
+	for _, ns := range r.Drops {
+		if err := b.Drop(ns); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// loadNewestSnapshot fills an empty backend from the newest snapshot in dir that verifies
+func loadNewestSnapshot(b Backend, dir string) error {
+	snapshots, err := ListSnapshots(dir)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range snapshots {
+		_, doc, err := readSnapshot(dir, s.Name)
+		if err != nil {
+			log.Printf("skipping snapshot %s: %v", s.Name, err)
+			continue
+		}
+
+		for ns, items := range doc.Namespaces {
+			st, err := b.Store(ns)
+			if err != nil {
+				return err
+			}
+			if err := applyBatch(st, Batch{Puts: items}); err != nil {
+				return err
+			}
+		}
+		log.Printf("loaded snapshot %s", s.Name)
+		return nil
+	}
+	return nil
+}
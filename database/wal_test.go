@@ -0,0 +1,151 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// openWAL opens the log in dir and recovers the current backend from it
+func openWAL(t *testing.T, dir string) (*WAL, int) {
+	t.Helper()
+	w, err := OpenWAL(filepath.Join(dir, "wal.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	replayed, err := Recover(w, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return w, replayed
+}
+
+// crash drops the log without a checkpoint, as a killed process would
+func crash(w *WAL) {
+	w.Close()
+	wal = nil
+}
+
+func TestWALReplaysAfterCrash(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	useBackend(t, NewJSONBackend(path))
+	w, _ := openWAL(t, dir)
+
+	createItems(t,
+		Item{ID: "a", Data: map[string]interface{}{"name": "pump"}},
+		Item{ID: "b", Data: map[string]interface{}{"name": "valve"}},
+	)
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Errorf("data file before a checkpoint: err = %v, want it missing", err)
+	}
+	crash(w)
+
+	useBackend(t, NewJSONBackend(path))
+	w, replayed := openWAL(t, dir)
+	if replayed != 2 {
+		t.Errorf("replayed %d transactions, want 2", replayed)
+	}
+	if _, err := GetItem("", "b"); err != nil {
+		t.Fatalf("item b after replay: %v", err)
+	}
+
+	if _, err := Compact(dir); err != nil {
+		t.Fatal(err)
+	}
+	if empty, err := w.empty(); err != nil || !empty {
+		t.Errorf("log after a checkpoint: empty = %v, err = %v", empty, err)
+	}
+	crash(w)
+
+	useBackend(t, NewJSONBackend(path))
+	item, err := GetItem("", "a")
+	if err != nil {
+		t.Fatalf("item a from the checkpointed file: %v", err)
+	}
+	if item.Data["name"] != "pump" {
+		t.Errorf("item a = %+v, want name pump", item)
+	}
+}
+
+func TestWALTruncatesTornRecord(t *testing.T) {
+	dir := t.TempDir()
+	useBackend(t, NewMemoryBackend())
+	w, _ := openWAL(t, dir)
+	createItems(t, Item{ID: "a"})
+
+	size, err := w.Size()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crash(w)
+
+	f, err := os.OpenFile(filepath.Join(dir, "wal.log"), os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write([]byte{0, 0, 0, 64, 1, 2, 3, 4, '{', '"'})
+	f.Close()
+
+	useBackend(t, NewMemoryBackend())
+	w, replayed := openWAL(t, dir)
+	if got, _ := w.Size(); got != size {
+		t.Errorf("log size after opening = %d, want the torn record cut to %d", got, size)
+	}
+	if replayed != 1 {
+		t.Errorf("replayed %d transactions, want 1", replayed)
+	}
+
+	createItems(t, Item{ID: "b"})
+	crash(w)
+	useBackend(t, NewMemoryBackend())
+	if _, replayed := openWAL(t, dir); replayed != 2 {
+		t.Errorf("replayed %d transactions after appending, want 2", replayed)
+	}
+}
+
+func TestWALFollowsOtherProcess(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "data.json")
+	useBackend(t, NewJSONBackend(path))
+	openWAL(t, dir)
+
+	// the other process shares the files and the log
+	other := NewJSONBackend(path)
+	other.deferWrites()
+	f, err := os.OpenFile(filepath.Join(dir, "wal.log"), os.O_RDWR|os.O_APPEND, 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	follower := &WAL{f: f}
+	defer follower.Close()
+
+	get := func(id string) bool {
+		t.Helper()
+		if err := follower.follow(other); err != nil {
+			t.Fatal(err)
+		}
+		s, err := other.Store("")
+		if err != nil {
+			t.Fatal(err)
+		}
+		_, err = s.Get(id)
+		return err == nil
+	}
+
+	createItems(t, Item{ID: "a"})
+	if !get("a") {
+		t.Error("the follower does not see a commit in the log")
+	}
+
+	createItems(t, Item{ID: "b"})
+	if _, err := Compact(dir); err != nil {
+		t.Fatal(err)
+	}
+	createItems(t, Item{ID: "c"})
+	for _, id := range []string{"a", "b", "c"} {
+		if !get(id) {
+			t.Errorf("the follower misses %s after a checkpoint", id)
+		}
+	}
+}
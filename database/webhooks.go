@@ -0,0 +1,326 @@
+package database
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// webhooksNamespace holds the webhook subscriptions keyed by ID and
+// deliveriesNamespace the log of their deliveries, keyed "delivery/<ID>",
+// with empty index entries "webhook/<webhook ID>/<ID>" and
+// "status/<status>/<ID>" to find the deliveries of a webhook or in a state
+const (
+	webhooksNamespace   = "_webhooks"
+	deliveriesNamespace = "_deliveries"
+)
+
+// Webhook event types
+const (
+	WebhookItemCreated = "item.created"
+	WebhookItemUpdated = "item.updated"
+	WebhookItemDeleted = "item.deleted"
+)
+
+// WebhookEventTypes lists the event types a webhook can subscribe to
+var WebhookEventTypes = []string{WebhookItemCreated, WebhookItemUpdated, WebhookItemDeleted}
+
+// Delivery states
+const (
+	DeliveryPending   = "pending"   // waiting for its next attempt
+	DeliverySucceeded = "succeeded" // the receiver answered 2xx
+	DeliveryDead      = "dead"      // every attempt failed, kept in the dead-letter list
+)
+
+var (
+	// ErrInvalidWebhook is returned for webhooks without a valid URL or event types
+	ErrInvalidWebhook = errors.New("invalid webhook")
+	// ErrWebhookNotFound is returned for unknown webhook IDs
+	ErrWebhookNotFound = errors.New("webhook not found")
+	// ErrDeliveryNotFound is returned for unknown delivery IDs
+	ErrDeliveryNotFound = errors.New("delivery not found")
+)
+
+// Webhook subscribes a URL to event types. Deliveries are signed with Secret.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	Secret    string    `json:"secret,omitempty"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Delivery is one event sent to one webhook together with the outcome of its attempts
+type Delivery struct {
+	ID            string          `json:"id"`
+	WebhookID     string          `json:"webhook_id"`
+	Event         string          `json:"event"`
+	Payload       json.RawMessage `json:"payload" swaggertype:"object"`
+	Status        string          `json:"status"`
+	Attempts      int             `json:"attempts"`
+	LastStatus    int             `json:"last_status,omitempty"` // HTTP status of the last attempt
+	LastError     string          `json:"last_error,omitempty"`
+	CreatedAt     time.Time       `json:"created_at"`
+	NextAttemptAt *time.Time      `json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time      `json:"delivered_at,omitempty"`
+}
+
+// Subscribes reports whether the webhook receives events of the given type
+func (w Webhook) Subscribes(event string) bool {
+	for _, e := range w.Events {
+		if e == event {
+			return true
+		}
+	}
+	return false
+}
+
+func (w Webhook) validate() error {
+	u, err := url.Parse(w.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidWebhook)
+	}
+	if len(w.Events) == 0 {
+		return fmt.Errorf("%w: events must list at least one of %v", ErrInvalidWebhook, WebhookEventTypes)
+	}
+	for _, e := range w.Events {
+		known := false
+		for _, t := range WebhookEventTypes {
+			known = known || e == t
+		}
+		if !known {
+			return fmt.Errorf("%w: unknown event %q, must be one of %v", ErrInvalidWebhook, e, WebhookEventTypes)
+		}
+	}
+	return nil
+}
+
+// CreateWebhook stores a new webhook subscription
+func CreateWebhook(w Webhook) error {
+	if err := w.validate(); err != nil {
+		return err
+	}
+
+	return Update(func(tx *Tx) error {
+		raw, err := toData(w)
+		if err != nil {
+			return err
+		}
+		return tx.In(webhooksNamespace).Insert(Item{ID: w.ID, Data: raw})
+	})
+}
+
+// GetWebhook returns a webhook by ID
+func GetWebhook(id string) (Webhook, error) {
+	var w Webhook
+
+	err := View(func(tx *Tx) error {
+		item, found, err := tx.In(webhooksNamespace).Get(id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrWebhookNotFound
+		}
+		return fromData(item.Data, &w)
+	})
+
+	return w, err
+}
+
+// ListWebhooks returns all webhooks, oldest first
+func ListWebhooks() ([]Webhook, error) {
+	var webhooks []Webhook
+
+	err := View(func(tx *Tx) error {
+		items, err := tx.In(webhooksNamespace).List()
+		if err != nil {
+			return err
+		}
+
+		webhooks = make([]Webhook, 0, len(items))
+		for _, item := range items {
+			var w Webhook
+			if err := fromData(item.Data, &w); err != nil {
+				return err
+			}
+			webhooks = append(webhooks, w)
+		}
+		return nil
+	})
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+	return webhooks, err
+}
+
+// DeleteWebhook deletes a webhook and its delivery log
+func DeleteWebhook(id string) error {
+	return Update(func(tx *Tx) error {
+		err := tx.In(webhooksNamespace).Delete(id)
+		if err == ErrNotFound {
+			return ErrWebhookNotFound
+		}
+		if err != nil {
+			return err
+		}
+
+		deliveries, err := tx.deliveries("webhook/"+id+"/", nil)
+		if err != nil {
+			return err
+		}
+		for _, d := range deliveries {
+			if err := tx.deleteDelivery(d); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// SaveDelivery creates or updates an entry of the delivery log
+func SaveDelivery(d Delivery) error {
+	return Update(func(tx *Tx) error {
+		raw, err := toData(d)
+		if err != nil {
+			return err
+		}
+
+		log := tx.In(deliveriesNamespace)
+		key := "delivery/" + d.ID
+		old, found, err := log.Get(key)
+		if err != nil {
+			return err
+		}
+		if status, _ := old.Data["status"].(string); found && status != d.Status {
+			if err := log.Delete("status/" + status + "/" + d.ID); err != nil && err != ErrNotFound {
+				return err
+			}
+		}
+		if err := log.write(Item{ID: key, Data: raw}, found, RevUpdate); err != nil {
+			return err
+		}
+
+		log.put(Item{ID: "webhook/" + d.WebhookID + "/" + d.ID, Data: map[string]interface{}{}})
+		log.put(Item{ID: "status/" + d.Status + "/" + d.ID, Data: map[string]interface{}{}})
+		return nil
+	})
+}
+
+// deliveries returns the deliveries whose keys or index entries start
+// with prefix and for which match returns true, nil matches all
+func (tx *Tx) deliveries(prefix string, match func(Delivery) bool) ([]Delivery, error) {
+	log := tx.In(deliveriesNamespace)
+	items, err := log.Prefix(prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]Delivery, 0, len(items))
+	for _, item := range items {
+		if !strings.HasPrefix(item.ID, "delivery/") {
+			id := item.ID[strings.LastIndexByte(item.ID, '/')+1:]
+			var found bool
+			if item, found, err = log.Get("delivery/" + id); err != nil {
+				return nil, err
+			} else if !found {
+				continue
+			}
+		}
+
+		var d Delivery
+		if err := fromData(item.Data, &d); err != nil {
+			return nil, err
+		}
+		if match == nil || match(d) {
+			deliveries = append(deliveries, d)
+		}
+	}
+	return deliveries, nil
+}
+
+// deleteDelivery removes a delivery and its index entries
+func (tx *Tx) deleteDelivery(d Delivery) error {
+	log := tx.In(deliveriesNamespace)
+	for _, key := range []string{"delivery/" + d.ID, "webhook/" + d.WebhookID + "/" + d.ID, "status/" + d.Status + "/" + d.ID} {
+		if err := log.Delete(key); err != nil && err != ErrNotFound {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetDelivery returns an entry of the delivery log by ID
+func GetDelivery(id string) (Delivery, error) {
+	var d Delivery
+
+	err := View(func(tx *Tx) error {
+		item, found, err := tx.In(deliveriesNamespace).Get("delivery/" + id)
+		if err != nil {
+			return err
+		}
+		if !found {
+			return ErrDeliveryNotFound
+		}
+		return fromData(item.Data, &d)
+	})
+
+	return d, err
+}
+
+// ListDeliveries returns the delivery log newest first, optionally only
+// of one webhook and one status
+func ListDeliveries(webhookID, status string) ([]Delivery, error) {
+	var deliveries []Delivery
+
+	err := View(func(tx *Tx) error {
+		prefix := "delivery/"
+		switch {
+		case webhookID != "":
+			prefix = "webhook/" + webhookID + "/"
+		case status != "":
+			prefix = "status/" + status + "/"
+		}
+
+		var err error
+		deliveries, err = tx.deliveries(prefix, func(d Delivery) bool {
+			return status == "" || d.Status == status
+		})
+		return err
+	})
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+	return deliveries, err
+}
+
+// PruneDeliveries removes succeeded deliveries created before cutoff,
+// pending and dead ones are kept
+func PruneDeliveries(cutoff time.Time) (int, error) {
+	pruned := 0
+
+	err := Update(func(tx *Tx) error {
+		deliveries, err := tx.deliveries("status/"+DeliverySucceeded+"/", func(d Delivery) bool {
+			return d.CreatedAt.Before(cutoff)
+		})
+		if err != nil {
+			return err
+		}
+
+		for _, d := range deliveries {
+			if err := tx.deleteDelivery(d); err != nil {
+				return err
+			}
+			pruned++
+		}
+		return nil
+	})
+
+	return pruned, err
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"errors"
+	"go-backend/database"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-contrib/sse"
+	"github.com/gin-gonic/gin"
+)
+
+// eventKeepAlive is how often an idle stream gets a comment so proxies keep it open
+const eventKeepAlive = 15 * time.Second
+
+// itemEventsHandler godoc
+// @Summary Stream item changes
+// @Description Server-Sent Events stream of item changes committed from now on. It starts with an "open" event, then the event name is the type (created, updated, deleted) and the data is a database.Event.
+// @Description Reconnecting with the Last-Event-ID header (or last_event_id) first replays the events missed in between. If they are no longer available a "reset" event is sent and the client should reload its data.
+// @Tags items
+// @Produce text/event-stream
+// @Param collection query string false "Only changes of this collection, all changes by default"
+// @Param Last-Event-ID header string false "ID of the last event received"
+// @Param last_event_id query string false "Same as Last-Event-ID, for clients that cannot set headers"
+// @Success 200 {object} database.Event
+// @Failure 400 {object} map[string]string "Invalid Last-Event-ID"
+// @Router /items/events [get]
+func itemEventsHandler(c *gin.Context) {
+	lastEventID := c.GetHeader("Last-Event-ID")
+	if lastEventID == "" {
+		lastEventID = c.Query("last_event_id")
+	}
+
+	var lastID uint64
+	if lastEventID != "" {
+		var err error
+		if lastID, err = strconv.ParseUint(lastEventID, 10, 64); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid Last-Event-ID"})
+			return
+		}
+	}
+
+	sub, missed, err := database.SubscribeEvents(lastID, lastEventID != "")
+	reset := errors.Is(err, database.ErrEventsLost)
+	if reset {
+		sub, missed, err = database.SubscribeEvents(0, false)
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to subscribe"})
+		return
+	}
+	defer sub.Close()
+
+	collection, filtered := c.GetQuery("collection")
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+
+	c.Header("Cache-Control", "no-cache")
+	c.Header("X-Accel-Buffering", "no")
+	opened := sse.Event{Event: "open", Retry: 3000, Data: gin.H{}}
+	if reset {
+		opened.Event, opened.Data = "reset", gin.H{"error": database.ErrEventsLost.Error()}
+	}
+	c.Render(http.StatusOK, opened)
+	c.Writer.Flush()
+
+	send := func(e database.Event) {
+		if filtered && e.Collection != collection {
+			return
+		}
+		c.Render(-1, sse.Event{Id: strconv.FormatUint(e.ID, 10), Event: e.Type, Data: e})
+	}
+	for _, e := range missed {
+		send(e)
+	}
+	c.Writer.Flush()
+
+	c.Stream(func(w io.Writer) bool {
+		select {
+		case <-c.Request.Context().Done():
+			return false
+		case <-keepAlive.C:
+			_, err := io.WriteString(w, ": keep-alive\n\n")
+			return err == nil
+		case e, ok := <-sub.Events:
+			if !ok {
+				// Fell too far behind, the client reconnects and resumes
+				return false
+			}
+			send(e)
+			return true
+		}
+	})
+}
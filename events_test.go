@@ -0,0 +1,126 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"go-backend/database"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// eventStream is an open /items/events response
+type eventStream struct {
+	resp   *http.Response
+	lines  *bufio.Reader
+	cancel context.CancelFunc
+}
+
+// openEvents connects to the event stream, resuming after lastEventID if set
+func openEvents(t *testing.T, url, lastEventID string) *eventStream {
+	t.Helper()
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url+"/items/events", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lastEventID != "" {
+		req.Header.Set("Last-Event-ID", lastEventID)
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &eventStream{resp: resp, lines: bufio.NewReader(resp.Body), cancel: cancel}
+	t.Cleanup(s.close)
+	return s
+}
+
+func (s *eventStream) close() {
+	s.cancel()
+	s.resp.Body.Close()
+}
+
+// next reads the fields of the next event
+func (s *eventStream) next(t *testing.T) map[string]string {
+	t.Helper()
+	fields := map[string]string{}
+	for {
+		line, err := s.lines.ReadString('\n')
+		if err != nil {
+			t.Fatalf("reading the event stream: %v", err)
+		}
+		line = strings.TrimRight(line, "\n")
+		if line == "" {
+			if len(fields) > 0 {
+				return fields
+			}
+			continue
+		}
+		if k, v, ok := strings.Cut(line, ":"); ok && k != "" {
+			fields[k] = strings.TrimPrefix(v, " ")
+		}
+	}
+}
+
+func TestItemEventsResume(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	database.SetBackend(database.NewMemoryBackend())
+	t.Cleanup(func() { database.SetBackend(database.NewMemoryBackend()) })
+
+	r := gin.New()
+	r.GET("/items/events", itemEventsHandler)
+	srv := httptest.NewServer(r)
+	defer srv.Close()
+
+	create := func(id string) {
+		t.Helper()
+		if err := database.CreateItem(database.Item{ID: id, Data: map[string]interface{}{}}, "test"); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	stream := openEvents(t, srv.URL, "")
+	if e := stream.next(t); e["event"] != "open" {
+		t.Fatalf("first event = %v, want open", e)
+	}
+	create("id-1")
+	first := stream.next(t)
+	if first["event"] != database.EventCreated || !strings.Contains(first["data"], `"item_id":"id-1"`) || first["id"] == "" {
+		t.Fatalf("event = %v, want the creation of id-1", first)
+	}
+	stream.close()
+
+	create("id-2")
+	create("id-3")
+	stream = openEvents(t, srv.URL, first["id"])
+	if e := stream.next(t); e["event"] != "open" {
+		t.Fatalf("first event after resuming = %v, want open", e)
+	}
+	for _, id := range []string{"id-2", "id-3"} {
+		if e := stream.next(t); e["event"] != database.EventCreated || !strings.Contains(e["data"], `"item_id":"`+id+`"`) {
+			t.Errorf("replayed event = %v, want the creation of %s", e, id)
+		}
+	}
+	stream.close()
+
+	// an ID from before a restart
+	stream = openEvents(t, srv.URL, "1")
+	if e := stream.next(t); e["event"] != "reset" || !strings.Contains(e["data"], database.ErrEventsLost.Error()) {
+		t.Errorf("resuming after a lost event: first event = %v, want reset", e)
+	}
+	create("id-4")
+	if e := stream.next(t); !strings.Contains(e["data"], `"item_id":"id-4"`) {
+		t.Errorf("event after a reset = %v, want the creation of id-4", e)
+	}
+	stream.close()
+
+	w := get(r, "/items/events?last_event_id=abc")
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("invalid last_event_id: status %d, want 400", w.Code)
+	}
+}
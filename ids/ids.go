@@ -0,0 +1,79 @@
+// Package ids generates the IDs of items, runs, tasks, webhooks and their
+// deliveries, and references between items. An ID is a kind prefix
+// followed by a UUIDv7, so IDs of one kind sort by creation time.
+package ids
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Prefixes of the kinds of IDs
+const (
+	Item      = "item_"
+	Run       = "run_"
+	Task      = "task_"
+	Webhook   = "hook_"
+	Delivery  = "dlv_"
+	Reference = "ref_"
+)
+
+// legacy matches the "<kind>-<unix nanos>" IDs generated before this
+// package, they stay valid so existing data keeps resolving
+var legacy = map[string]*regexp.Regexp{
+	Item: regexp.MustCompile(`^id-[0-9]{1,20}$`),
+	Run:  regexp.MustCompile(`^run-[0-9]{1,20}$`),
+	Task: regexp.MustCompile(`^task-[0-9]{1,20}$`),
+}
+
+// New returns a new ID with the given prefix
+func New(prefix string) string {
+	return prefix + uuid.Must(uuid.NewV7()).String()
+}
+
+// NewItem returns a new item ID
+func NewItem() string {
+	return New(Item)
+}
+
+// NewRun returns a new run ID
+func NewRun() string {
+	return New(Run)
+}
+
+// NewTask returns a new task ID
+func NewTask() string {
+	return New(Task)
+}
+
+// NewWebhook returns a new webhook ID
+func NewWebhook() string {
+	return New(Webhook)
+}
+
+// NewDelivery returns a new webhook delivery ID
+func NewDelivery() string {
+	return New(Delivery)
+}
+
+// NewReference returns a new reference ID
+func NewReference() string {
+	return New(Reference)
+}
+
+// Valid reports whether id is an ID of the kind given by prefix, in the
+// current or the legacy format
+func Valid(prefix, id string) bool {
+	if re, ok := legacy[prefix]; ok && re.MatchString(id) {
+		return true
+	}
+
+	rest, ok := strings.CutPrefix(id, prefix)
+	if !ok || len(rest) != 36 {
+		return false
+	}
+	u, err := uuid.Parse(rest)
+	return err == nil && u.Version() == 7 && rest == u.String()
+}
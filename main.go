@@ -2,19 +2,23 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
 	"go-backend/database"
 	_ "go-backend/docs" // swag will generate this
+	"go-backend/ids"
 	"go-backend/mcp"
+	"go-backend/tables"
 	"image"
 	_ "image/jpeg"
 	_ "image/png"
 	"io/fs"
-
-	// "log"
+	"log"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gin-contrib/cors"
@@ -29,13 +33,46 @@ import (
 // @description Example API with GET, POST, and PATCH endpoints.
 
 func main() {
+	// DB_BACKEND selects the item store (json, memory or bolt), DB_PATH overrides its file
+	backend, err := database.OpenBackend(os.Getenv("DB_BACKEND"), os.Getenv("DB_PATH"))
+	if err != nil {
+		log.Fatalf("Failed to open database: %v", err)
+	}
+	defer backend.Close()
+	database.SetBackend(backend)
+	configureRevisions()
+
+	wal := openWAL()
+	if wal != nil {
+		defer wal.Close()
+	}
+
+	// Subcommands work on the database and exit instead of serving
+	if len(os.Args) > 1 {
+		code := 2
+		if os.Args[1] == "snapshot" {
+			code = snapshotCommand(os.Args[2:])
+		} else {
+			fmt.Fprintf(os.Stderr, "unknown command %q\n", os.Args[1])
+		}
+		backend.Close()
+		os.Exit(code)
+	}
+
+	startSnapshotSchedule()
+	startSweeper()
+	startWebhooks()
+	if wal != nil {
+		startWALCompaction()
+	}
+
 	r := gin.Default()
 
 	r.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"http://localhost:5173"}, // React dev server
-		AllowMethods:     []string{"GET", "POST", "OPTIONS"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept"},
-		ExposeHeaders:    []string{"Content-Length"},
+		AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-User", "If-Match", "If-None-Match"},
+		ExposeHeaders:    []string{"Content-Length", "ETag"},
 		AllowCredentials: true,
 		MaxAge:           12 * time.Hour,
 	}))
@@ -52,13 +89,129 @@ func main() {
 
 	r.GET("/tableData", getTableDataHandler)
 
+	r.GET("/tableData/export", exportTableDataHandler)
+
+	r.GET("/tables", listTablesHandler)
+
+	r.GET("/tables/:name", getTableHandler)
+
+	r.GET("/tables/:name/schema", getTableSchemaHandler)
+
+	r.GET("/tables/:name/export", exportTableHandler)
+
+	r.GET("/tables/:name/stats", tableStatsHandler)
+
+	r.GET("/tables/:name/pivot", tablePivotHandler)
+
+	r.POST("/tables/:name/rows", insertTableRowHandler)
+
+	r.GET("/tables/:name/rows/:key", getTableRowHandler)
+
+	r.PUT("/tables/:name/rows/:key", replaceTableRowHandler)
+
+	r.PATCH("/tables/:name/rows/:key", updateTableRowHandler)
+
+	r.DELETE("/tables/:name/rows/:key", deleteTableRowHandler)
+
 	r.POST("/user", createUser)
 
 	r.PATCH("/user/:id", updateUser)
 
 	r.POST("/add", addItemHandler)
 
-	r.GET("/item/:id", getItemHandler)
+	r.GET("/items", listItemsHandler)
+
+	r.GET("/items/search", searchItemsHandler)
+
+	r.GET("/items/aggregate", aggregateItemsHandler)
+
+	r.GET("/items/events", itemEventsHandler)
+
+	r.POST("/items/import", importItemsHandler)
+
+	r.GET("/items/export", exportItemsHandler)
+
+	r.GET("/item/:id", requireItemID, getItemHandler)
+
+	r.PUT("/item/:id", requireItemID, replaceItemHandler)
+
+	r.PATCH("/item/:id", requireItemID, patchItemHandler)
+
+	r.DELETE("/item/:id", requireItemID, deleteItemHandler)
+
+	r.GET("/item/:id/revisions", requireItemID, listRevisionsHandler)
+
+	r.GET("/item/:id/revisions/:rev", requireItemID, getRevisionHandler)
+
+	r.POST("/item/:id/revisions/:rev/restore", requireItemID, restoreRevisionHandler)
+
+	r.GET("/item/:id/diff", requireItemID, diffRevisionsHandler)
+
+	r.POST("/item/:id/undelete", requireItemID, undeleteItemHandler)
+
+	r.POST("/item/:id/references", requireItemID, addReferenceHandler)
+
+	r.GET("/item/:id/references", requireItemID, listReferencesHandler)
+
+	r.DELETE("/item/:id/references/:ref", requireItemID, removeReferenceHandler)
+
+	r.GET("/item/:id/related", requireItemID, relatedItemsHandler)
+
+	r.GET("/trash", listTrashHandler)
+
+	r.DELETE("/trash/:id", requireItemID, purgeTrashHandler)
+
+	r.GET("/collections", listCollectionsHandler)
+
+	r.POST("/collections", createCollectionHandler)
+
+	r.GET("/collections/:name", getCollectionHandler)
+
+	r.DELETE("/collections/:name", dropCollectionHandler)
+
+	r.GET("/collections/:name/schema", getCollectionSchemaHandler)
+
+	r.PUT("/collections/:name/schema", putCollectionSchemaHandler)
+
+	r.GET("/collections/:name/items", listCollectionItemsHandler)
+
+	r.POST("/collections/:name/items", addCollectionItemHandler)
+
+	r.GET("/collections/:name/items/:id", requireItemID, getCollectionItemHandler)
+
+	r.PUT("/collections/:name/items/:id", requireItemID, replaceCollectionItemHandler)
+
+	r.PATCH("/collections/:name/items/:id", requireItemID, patchCollectionItemHandler)
+
+	r.DELETE("/collections/:name/items/:id", requireItemID, deleteCollectionItemHandler)
+
+	admin := r.Group("/admin", requireAdmin)
+
+	admin.GET("/snapshots", listSnapshotsHandler)
+
+	admin.POST("/snapshots", takeSnapshotHandler)
+
+	admin.GET("/snapshots/:name", verifySnapshotHandler)
+
+	admin.DELETE("/snapshots/:name", deleteSnapshotHandler)
+
+	admin.POST("/snapshots/:name/restore", restoreSnapshotHandler)
+
+	hooks := r.Group("/webhooks", requireWebhookAdmin)
+
+	hooks.POST("", createWebhookHandler)
+
+	hooks.GET("", listWebhooksHandler)
+
+	hooks.GET("/dead-letters", listDeadLettersHandler)
+
+	hooks.GET("/:id", getWebhookHandler)
+
+	hooks.DELETE("/:id", deleteWebhookHandler)
+
+	hooks.GET("/:id/deliveries", listWebhookDeliveriesHandler)
+
+	hooks.POST("/deliveries/:id/retry", retryDeliveryHandler)
 
 	r.GET("/swagger/*any", ginSwagger.WrapHandler(swaggerFiles.Handler))
 
@@ -84,20 +237,37 @@ type Item = database.Item
 // @Tags items
 // @Produce json
 // @Param id path string true "Item ID"
-// @Success 200 {object} Item
-// @Failure 404 {object} map[string]string "Item not found"
+// @Param collection query string false "Collection the item belongs to"
+// @Param If-None-Match header string false "ETag of a cached copy"
+// @Param expand query string false "Comma separated reference types whose targets are embedded under expanded, * for all" example(located_at,photo)
+// @Success 200 {object} expandedItem
+// @Header 200 {string} ETag "Item version"
+// @Success 304 "Cached copy is current"
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Item or collection not found"
 // @Failure 500 {object} map[string]string "Failed to read DB"
 // @Router /item/{id} [get]
 func getItemHandler(c *gin.Context) {
-	id := c.Param("id")
-
-	item, found, err := database.GetByID(id)
+	item, err := database.GetItem(itemCollection(c), c.Param("id"))
 	if err != nil {
-		c.JSON(500, gin.H{"error": "Failed to read DB"})
+		writeDBError(c, err)
 		return
 	}
-	if !found {
-		c.JSON(404, gin.H{"error": "Item not found"})
+
+	etag := itemETag(item)
+	c.Header("ETag", etag)
+	if etagMatches(c.GetHeader("If-None-Match"), etag) {
+		c.Status(http.StatusNotModified)
+		return
+	}
+
+	if expand := c.Query("expand"); expand != "" {
+		expanded, err := database.Expand(itemCollection(c), item.ID, strings.Split(expand, ","))
+		if err != nil {
+			writeDBError(c, err)
+			return
+		}
+		c.JSON(http.StatusOK, expandedItem{Item: item, Expanded: expanded})
 		return
 	}
 
@@ -106,13 +276,20 @@ func getItemHandler(c *gin.Context) {
 
 // addItemHandler godoc
 // @Summary Add a new item
-// @Description Add a JSON object and store it in the database, returns the generated ID
+// @Description Add a JSON object and store it in the database, returns the generated ID.
+// @Description With a collection the object must match the collection's JSON Schema.
 // @Tags items
 // @Accept json
 // @Produce json
+// @Param collection query string false "Collection to add the item to"
+// @Param expires_at query string false "RFC 3339 time the item expires at, empty clears it"
+// @Param ttl query string false "Expire the item after this duration, e.g. 24h"
 // @Param data body map[string]interface{} true "Item data"
 // @Success 200 {object} map[string]string "id of the created item"
-// @Failure 400 {object} map[string]string "Invalid JSON"
+// @Failure 400 {object} map[string]string "Invalid JSON or expiry"
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Failure 409 {object} map[string]string "Item already exists"
+// @Failure 422 {object} validationErrorResponse "Item does not match the collection schema"
 // @Failure 500 {object} map[string]string "Failed to read/write DB"
 // @Router /add [post]
 func addItemHandler(c *gin.Context) {
@@ -123,46 +300,418 @@ func addItemHandler(c *gin.Context) {
 		return
 	}
 
-	id := database.NewID()
+	expiresAt, _, err := itemExpiry(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	id := ids.NewItem()
 	item := database.Item{
-		ID:   id,
-		Data: data,
+		ID:         id,
+		Collection: itemCollection(c),
+		ExpiresAt:  expiresAt,
+		Data:       data,
+	}
+
+	if err := database.CreateItem(item, requestAuthor(c)); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"id": id})
+}
+
+// listItemsHandler godoc
+// @Summary List items
+// @Description Returns a page of items. Any other query parameter filters on a field, e.g. data.site=North, data.severity[gte]=3, data.tags[contains]=crane, data.owner[exists]=true or data.status[in]=open,review
+// @Tags items
+// @Produce json
+// @Param collection query string false "Collection to list, items outside any collection by default"
+// @Param sort query string false "Comma separated fields, prefix with - for descending" example(-data.severity,id)
+// @Param limit query int false "Page size (default 100, max 1000)"
+// @Param cursor query string false "next_cursor of the previous page"
+// @Success 200 {object} database.Page
+// @Failure 400 {object} map[string]string "Invalid query"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /items [get]
+func listItemsHandler(c *gin.Context) {
+	q, err := database.ParseQuery(c.Request.URL.Query(), "collection")
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	page, err := database.Find(itemCollection(c), q)
+	if err != nil {
+		writeDBError(c, err)
+		return
 	}
 
-	items, err := database.ReadDB()
+	c.JSON(http.StatusOK, page)
+}
+
+// replaceItemHandler godoc
+// @Summary Replace an item
+// @Description Replace the data of an existing item
+// @Tags items
+// @Accept json
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Param If-Match header string false "ETag the item must still have"
+// @Param expires_at query string false "RFC 3339 time the item expires at, empty clears it"
+// @Param ttl query string false "Expire the item after this duration, e.g. 24h"
+// @Param data body map[string]interface{} true "Item data"
+// @Success 200 {object} Item
+// @Header 200 {string} ETag "New item version"
+// @Failure 400 {object} map[string]string "Invalid JSON, item ID or expiry"
+// @Failure 404 {object} map[string]string "Item not found"
+// @Failure 412 {object} map[string]string "Item version does not match If-Match"
+// @Failure 422 {object} validationErrorResponse "Item does not match the collection schema"
+// @Failure 500 {object} map[string]string "Failed to read/write DB"
+// @Router /item/{id} [put]
+func replaceItemHandler(c *gin.Context) {
+	var data map[string]interface{}
+
+	if err := c.ShouldBindJSON(&data); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	opts := writeOptions(c)
+	var err error
+	if opts.ExpiresAt, opts.SetExpiry, err = itemExpiry(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	item, err := database.ReplaceItem(itemCollection(c), c.Param("id"), data, opts)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read DB"})
+		writeDBError(c, err)
 		return
 	}
 
-	items = append(items, item)
+	c.Header("ETag", itemETag(item))
+	c.JSON(http.StatusOK, item)
+}
 
-	if err := database.WriteDB(items); err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write DB"})
+// patchItemHandler godoc
+// @Summary Partially update an item
+// @Description The Content-Type selects the patch format:
+// @Description application/json merges the given top-level keys into the data, null removes a key.
+// @Description application/merge-patch+json is an RFC 7396 JSON Merge Patch, merged recursively.
+// @Description application/json-patch+json is an RFC 6902 JSON Patch, e.g. [{"op":"test","path":"/status","value":"open"},{"op":"add","path":"/tags/-","value":"urgent"}].
+// @Description The patch is applied atomically, if any operation fails the item is unchanged and the error names the operation and the JSON pointer that failed.
+// @Tags items
+// @Accept json,application/merge-patch+json,application/json-patch+json
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Param If-Match header string false "ETag the item must still have"
+// @Param expires_at query string false "RFC 3339 time the item expires at, empty clears it"
+// @Param ttl query string false "Expire the item after this duration, e.g. 24h"
+// @Param data body map[string]interface{} true "Partial item data, merge patch or JSON Patch operations"
+// @Success 200 {object} Item
+// @Header 200 {string} ETag "New item version"
+// @Failure 400 {object} map[string]string "Invalid JSON, patch, item ID or expiry"
+// @Failure 404 {object} map[string]string "Item not found"
+// @Failure 409 {object} patchErrorResponse "A JSON Patch test operation failed"
+// @Failure 412 {object} map[string]string "Item version does not match If-Match"
+// @Failure 415 {object} map[string]string "Unsupported patch format"
+// @Failure 422 {object} patchErrorResponse "Patch cannot be applied or item does not match the collection schema"
+// @Failure 500 {object} map[string]string "Failed to read/write DB"
+// @Router /item/{id} [patch]
+func patchItemHandler(c *gin.Context) {
+	var patch database.Patch
+	var keys map[string]interface{}
+
+	switch c.ContentType() {
+	case "application/merge-patch+json":
+		var p database.MergePatch
+		if err := c.ShouldBindJSON(&p.Value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+			return
+		}
+		patch = p
+	case "application/json-patch+json":
+		var p database.JSONPatch
+		if err := c.ShouldBindJSON(&p); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON Patch, expected an array of operations"})
+			return
+		}
+		patch = p
+	case "", "application/json":
+		if err := c.ShouldBindJSON(&keys); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+			return
+		}
+	default:
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "Unsupported patch format, use application/json, application/merge-patch+json or application/json-patch+json"})
 		return
 	}
 
-	c.JSON(http.StatusOK, gin.H{"id": id})
+	opts := writeOptions(c)
+	var err error
+	if opts.ExpiresAt, opts.SetExpiry, err = itemExpiry(c); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var item *database.Item
+	if patch != nil {
+		item, err = database.ApplyPatch(itemCollection(c), c.Param("id"), patch, opts)
+	} else {
+		item, err = database.PatchItem(itemCollection(c), c.Param("id"), keys, opts)
+	}
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Header("ETag", itemETag(item))
+	c.JSON(http.StatusOK, item)
+}
+
+// deleteItemHandler godoc
+// @Summary Delete an item
+// @Description Moves an item to the trash, it can be undeleted until the trash retention has passed.
+// @Description Items referencing it are deleted too or lose the reference, depending on the on_delete of each reference.
+// @Tags items
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Param If-Match header string false "ETag the item must still have"
+// @Success 204 "Item moved to the trash"
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Item not found"
+// @Failure 409 {object} referencedErrorResponse "Item is referenced with on_delete restrict"
+// @Failure 412 {object} map[string]string "Item version does not match If-Match"
+// @Failure 500 {object} map[string]string "Failed to read/write DB"
+// @Router /item/{id} [delete]
+func deleteItemHandler(c *gin.Context) {
+	if err := database.DeleteItem(itemCollection(c), c.Param("id"), writeOptions(c)); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// validationErrorResponse is returned when item data does not match its collection schema
+type validationErrorResponse struct {
+	Error  string                     `json:"error" example:"Validation failed"`
+	Issues []database.ValidationIssue `json:"issues"`
+}
+
+// patchErrorResponse is returned when a JSON Patch operation fails
+type patchErrorResponse struct {
+	Error     string `json:"error" example:"patch cannot be applied"`
+	Operation int    `json:"operation" example:"1"`
+	Op        string `json:"op" example:"replace"`
+	Path      string `json:"path" example:"/site/name"`
+	Message   string `json:"message" example:"member \"site\" does not exist"`
+}
+
+// referencedErrorResponse lists the references that keep an item from being deleted
+type referencedErrorResponse struct {
+	Error      string               `json:"error" example:"Item is referenced"`
+	References []database.Reference `json:"references"`
+}
+
+// itemCollection returns the collection addressed by the request, from the
+// /collections/:name route or the collection query parameter
+func itemCollection(c *gin.Context) string {
+	if name := c.Param("name"); name != "" {
+		return name
+	}
+	return c.Query("collection")
+}
+
+// requireItemID rejects requests whose :id path parameter is not an item ID
+func requireItemID(c *gin.Context) {
+	if !ids.Valid(ids.Item, c.Param("id")) {
+		c.AbortWithStatusJSON(http.StatusBadRequest, gin.H{"error": "Invalid item ID"})
+	}
+}
+
+// requestAuthor returns who makes the request, as named by the X-User header.
+// It is recorded in item revisions and not authenticated.
+func requestAuthor(c *gin.Context) string {
+	return c.GetHeader("X-User")
+}
+
+// writeOptions returns the author and the If-Match precondition of a write request.
+// "*" matches any existing item, an If-Match without strong version ETags matches none.
+func writeOptions(c *gin.Context) database.WriteOptions {
+	opts := database.WriteOptions{Author: requestAuthor(c)}
+
+	header := c.GetHeader("If-Match")
+	if header == "" || strings.TrimSpace(header) == "*" {
+		return opts
+	}
+
+	opts.IfVersion = []int64{}
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if !strings.HasPrefix(tag, `"`) || !strings.HasSuffix(tag, `"`) || len(tag) < 2 {
+			continue
+		}
+		if v, err := strconv.ParseInt(tag[1:len(tag)-1], 10, 64); err == nil {
+			opts.IfVersion = append(opts.IfVersion, v)
+		}
+	}
+	return opts
+}
+
+// itemExpiry reads the expiry of an item from the expires_at (RFC 3339)
+// or ttl (duration such as 24h) query parameter. set is false if neither
+// is given, an empty expires_at clears the expiry.
+func itemExpiry(c *gin.Context) (at *time.Time, set bool, err error) {
+	s, hasAt := c.GetQuery("expires_at")
+	ttl, hasTTL := c.GetQuery("ttl")
+
+	switch {
+	case hasAt && hasTTL:
+		return nil, false, errors.New("expires_at and ttl are exclusive")
+	case hasAt && s == "":
+		return nil, true, nil
+	case hasAt:
+		t, err := time.Parse(time.RFC3339, s)
+		if err != nil {
+			return nil, false, errors.New("expires_at must be an RFC 3339 timestamp")
+		}
+		t = t.UTC()
+		return &t, true, nil
+	case hasTTL:
+		d, err := time.ParseDuration(ttl)
+		if err != nil || d <= 0 {
+			return nil, false, errors.New("ttl must be a positive duration such as 24h")
+		}
+		t := time.Now().UTC().Add(d)
+		return &t, true, nil
+	}
+	return nil, false, nil
+}
+
+// itemETag is the strong ETag of an item, its quoted version
+func itemETag(item *database.Item) string {
+	return `"` + strconv.FormatInt(item.Version, 10) + `"`
+}
+
+// etagMatches reports whether an If-None-Match header lists etag, using weak comparison
+func etagMatches(header, etag string) bool {
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" || strings.TrimPrefix(tag, "W/") == etag {
+			return true
+		}
+	}
+	return false
+}
+
+// writeDBError maps database errors to HTTP responses
+func writeDBError(c *gin.Context, err error) {
+	var verr *database.ValidationError
+	var perr *database.PatchError
+	var rerr *database.ReferencedError
+	var terr *tables.ValidationError
+
+	switch {
+	case errors.As(err, &verr):
+		c.JSON(http.StatusUnprocessableEntity, validationErrorResponse{Error: "Validation failed", Issues: verr.Issues})
+	case errors.As(err, &perr):
+		status := http.StatusUnprocessableEntity
+		if errors.Is(err, database.ErrInvalidPatch) {
+			status = http.StatusBadRequest
+		} else if errors.Is(err, database.ErrPatchTestFailed) {
+			status = http.StatusConflict
+		}
+		c.JSON(status, patchErrorResponse{Error: perr.Err.Error(), Operation: perr.Operation, Op: perr.Op, Path: perr.Path, Message: perr.Message})
+	case errors.As(err, &rerr):
+		c.JSON(http.StatusConflict, referencedErrorResponse{Error: "Item is referenced", References: rerr.References})
+	case errors.Is(err, database.ErrNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Item not found"})
+	case errors.Is(err, database.ErrRevisionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+	case errors.Is(err, database.ErrVersionMismatch):
+		c.JSON(http.StatusPreconditionFailed, gin.H{"error": "Item version does not match"})
+	case errors.Is(err, database.ErrConflict):
+		c.JSON(http.StatusConflict, gin.H{"error": "Item already exists"})
+	case errors.Is(err, database.ErrCollectionNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Collection not found"})
+	case errors.Is(err, database.ErrCollectionExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Collection already exists"})
+	case errors.Is(err, database.ErrSnapshotNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Snapshot not found"})
+	case errors.Is(err, database.ErrInvalidSnapshot):
+		c.JSON(http.StatusUnprocessableEntity, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrReferenceNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Reference not found"})
+	case errors.Is(err, database.ErrReferenceExists):
+		c.JSON(http.StatusConflict, gin.H{"error": "Reference already exists"})
+	case errors.Is(err, database.ErrWebhookNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Webhook not found"})
+	case errors.Is(err, database.ErrDeliveryNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Delivery not found"})
+	case errors.As(err, &terr):
+		c.JSON(http.StatusUnprocessableEntity, validationErrorResponse{Error: "Validation failed", Issues: terr.Issues})
+	case errors.Is(err, tables.ErrTableNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Table not found"})
+	case errors.Is(err, tables.ErrRowNotFound):
+		c.JSON(http.StatusNotFound, gin.H{"error": "Row not found"})
+	case errors.Is(err, tables.ErrRowExists), errors.Is(err, tables.ErrNoKey):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+	case errors.Is(err, tables.ErrInvalidTable):
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	case errors.Is(err, database.ErrInvalidQuery), errors.Is(err, database.ErrInvalidCollection), errors.Is(err, database.ErrInvalidWebhook), errors.Is(err, database.ErrInvalidPatch), errors.Is(err, database.ErrInvalidReference):
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+	default:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to access DB"})
+	}
 }
 
 // getTableDataHandler godoc
 // @Summary Get table data
-// @Description Returns table data from JSON file
+// @Description Returns table data from JSON file. Without query parameters the file is returned as is.
+// @Description Query parameters other than sort, page and pageSize filter on a column, e.g. category=Fruit, name[contains]=apple or price[gte]=1&price[lte]=2. Operators are eq ne gt gte lt lte contains exists in as for items. Parameters naming no column, like cache busters, are ignored.
 // @ID get-table-data
 // @Produce json
-// @Success 200 {array} map[string]interface{}
+// @Param sort query string false "Comma separated columns, prefix with - for descending" example(-price,name)
+// @Param page query int false "Page number, starting at 1"
+// @Param pageSize query int false "Rows per page (default 50, max 1000)"
+// @Success 200 {object} tables.Page "With query parameters"
+// @Success 200 {array} map[string]interface{} "Without query parameters"
+// @Failure 400 {object} map[string]string "Invalid query"
 // @Failure 500 {object} map[string]string
 // @Router /tableData [get]
 func getTableDataHandler(c *gin.Context) {
 	filepath := "./data/tableData.json"
 
-	data, err := os.ReadFile(filepath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+	var rows []tables.Row
+	var columns, ignore []string
+	query := c.Request.URL.Query()
+	if len(query) > 0 {
+		var err error
+		rows, columns, err = tables.Load(filepath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		ignore = tables.UnknownParams(query, columns)
+	}
+
+	if len(ignore) == len(query) {
+		data, err := os.ReadFile(filepath)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+
+		c.Data(http.StatusOK, "application/json", data)
 		return
 	}
 
-	c.Data(http.StatusOK, "application/json", data)
+	writeTablePage(c, rows, columns)
 }
 
 // getImagesHandler godoc
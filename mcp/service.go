@@ -5,6 +5,7 @@ import (
 	// "github.com/google/uuid"
 	"encoding/json"
 	"fmt"
+	"go-backend/ids"
 	"time"
 )
 
@@ -27,14 +28,6 @@ func PrintTaskAsJSON(task *Task) {
 	fmt.Println(string(b))
 }
 
-func NewRunID() string {
-	return fmt.Sprintf("run-%d", time.Now().UnixNano())
-}
-
-func NewTaskID() string {
-	return fmt.Sprintf("task-%d", time.Now().UnixNano())
-}
-
 // based upon JSON-RPC
 // ChatMessage represents incoming chat JSON
 type ChatMessage struct {
@@ -132,7 +125,7 @@ func SendToLLM(apiURL string, rpcReq *JsonRPCRequest) (*JsonRPCResponse, error)
 }
 
 func BuildRunWithOutput(input string) *Run {
-	runID := NewRunID()
+	runID := ids.NewRun()
 	fmt.Println("BuildRunWithOutput", input)
 	output := &RunOutput{
 		ID:        runID,
@@ -141,7 +134,7 @@ func BuildRunWithOutput(input string) *Run {
 	}
 
 	mainTask := &Task{
-		ID:        NewTaskID(),
+		ID:        ids.NewTask(),
 		RunID:     runID,
 		Type:      "LLM",
 		Input:     "Analyze scenario",
@@ -151,7 +144,7 @@ func BuildRunWithOutput(input string) *Run {
 	}
 
 	listMachinery := &Task{
-		ID:        NewTaskID(),
+		ID:        ids.NewTask(),
 		RunID:     runID,
 		Type:      "LLM",
 		Input:     "List all machinery",
@@ -161,7 +154,7 @@ func BuildRunWithOutput(input string) *Run {
 	}
 
 	categorizeMachinery := &Task{
-		ID:        NewTaskID(),
+		ID:        ids.NewTask(),
 		RunID:     runID,
 		Type:      "Tool",
 		Input:     "Categorize machinery",
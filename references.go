@@ -0,0 +1,173 @@
+package main
+
+import (
+	"go-backend/database"
+	"go-backend/ids"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// imageDirs are searched for the images references point to
+var imageDirs = []string{"./images", "./uploads"}
+
+// referenceRequest is the body of POST /item/{id}/references
+type referenceRequest struct {
+	Type     string          `json:"type" binding:"required" example:"located_at"`
+	To       database.Target `json:"to" binding:"required"`
+	OnDelete string          `json:"on_delete" example:"restrict" enums:"restrict,cascade,nullify"`
+}
+
+// expandedItem is an item with the targets of its references embedded by type
+type expandedItem struct {
+	*database.Item
+	Expanded map[string][]database.Related `json:"expanded"`
+}
+
+// imageExists reports whether name is an image in one of the image directories
+func imageExists(name string) bool {
+	if name != filepath.Base(name) || strings.HasPrefix(name, ".") {
+		return false
+	}
+	for _, dir := range imageDirs {
+		if info, err := os.Stat(filepath.Join(dir, name)); err == nil && !info.IsDir() {
+			return true
+		}
+	}
+	return false
+}
+
+// referenceDirection reads the direction query parameter, out by default
+func referenceDirection(c *gin.Context) (string, bool) {
+	switch d := c.DefaultQuery("direction", database.Outgoing); d {
+	case database.Outgoing, database.Incoming:
+		return d, true
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "direction must be out or in"})
+		return "", false
+	}
+}
+
+// addReferenceHandler godoc
+// @Summary Add a reference
+// @Description Links an item to another item, given by collection and ID, or to an image in ./images or ./uploads.
+// @Description on_delete decides what happens when the target item is deleted: restrict (default) refuses the delete, cascade deletes this item too, nullify removes the reference.
+// @Tags references
+// @Accept json
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Param reference body referenceRequest true "Reference"
+// @Success 201 {object} database.Reference
+// @Failure 400 {object} map[string]string "Invalid reference, target missing"
+// @Failure 404 {object} map[string]string "Item not found"
+// @Failure 409 {object} map[string]string "Reference already exists"
+// @Router /item/{id}/references [post]
+func addReferenceHandler(c *gin.Context) {
+	var req referenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+	if req.To.Image != "" && !imageExists(req.To.Image) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Image not found"})
+		return
+	}
+
+	r := database.Reference{
+		ID:        ids.NewReference(),
+		Type:      req.Type,
+		From:      database.Target{ID: c.Param("id")},
+		To:        req.To,
+		OnDelete:  req.OnDelete,
+		CreatedAt: time.Now().UTC(),
+	}
+	if r.OnDelete == "" {
+		r.OnDelete = database.OnDeleteRestrict
+	}
+	if err := database.AddReference(itemCollection(c), r); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	r.From.Collection = itemCollection(c)
+	c.JSON(http.StatusCreated, r)
+}
+
+// listReferencesHandler godoc
+// @Summary List references
+// @Description Returns the references from an item, or with direction=in the references to it, oldest first
+// @Tags references
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Param type query string false "Only references of this type"
+// @Param direction query string false "out (default) or in" Enums(out, in)
+// @Success 200 {array} database.Reference
+// @Failure 400 {object} map[string]string "Invalid direction"
+// @Failure 404 {object} map[string]string "Item not found"
+// @Router /item/{id}/references [get]
+func listReferencesHandler(c *gin.Context) {
+	direction, ok := referenceDirection(c)
+	if !ok {
+		return
+	}
+
+	refs, err := database.ListReferences(itemCollection(c), c.Param("id"), c.Query("type"), direction)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, refs)
+}
+
+// removeReferenceHandler godoc
+// @Summary Remove a reference
+// @Tags references
+// @Param id path string true "Item ID"
+// @Param ref path string true "Reference ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Success 204 "Reference removed"
+// @Failure 404 {object} map[string]string "Reference not found"
+// @Router /item/{id}/references/{ref} [delete]
+func removeReferenceHandler(c *gin.Context) {
+	if err := database.RemoveReference(itemCollection(c), c.Param("id"), c.Param("ref")); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// relatedItemsHandler godoc
+// @Summary Traverse references
+// @Description Returns the items an item references, or with direction=in the items referencing it, each with the reference leading there. Image references have no item. Deleted items are left out.
+// @Tags references
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Param type query string false "Only follow references of this type"
+// @Param direction query string false "out (default) or in" Enums(out, in)
+// @Success 200 {array} database.Related
+// @Failure 400 {object} map[string]string "Invalid direction"
+// @Failure 404 {object} map[string]string "Item not found"
+// @Router /item/{id}/related [get]
+func relatedItemsHandler(c *gin.Context) {
+	direction, ok := referenceDirection(c)
+	if !ok {
+		return
+	}
+
+	related, err := database.GetRelated(itemCollection(c), c.Param("id"), c.Query("type"), direction)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, related)
+}
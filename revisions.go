@@ -0,0 +1,143 @@
+package main
+
+import (
+	"go-backend/database"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// configureRevisions keeps REVISION_LIMIT revisions per item (default 100),
+// older ones are dropped as new ones are written
+func configureRevisions() {
+	s := os.Getenv("REVISION_LIMIT")
+	if s == "" {
+		return
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < 1 {
+		log.Fatalf("Invalid REVISION_LIMIT %q", s)
+	}
+	database.SetRevisionLimit(n)
+}
+
+// listRevisionsHandler godoc
+// @Summary List item revisions
+// @Description Returns the kept revisions of an item, oldest first, including deletes. The newest REVISION_LIMIT (default 100) revisions are kept per item.
+// @Tags revisions
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Success 200 {array} database.Revision
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Item or collection not found"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /item/{id}/revisions [get]
+func listRevisionsHandler(c *gin.Context) {
+	revisions, err := database.ListRevisions(itemCollection(c), c.Param("id"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revisions)
+}
+
+// getRevisionHandler godoc
+// @Summary Get an item revision
+// @Description Returns the data of an item as written by one revision
+// @Tags revisions
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param rev path int true "Revision number"
+// @Param collection query string false "Collection the item belongs to"
+// @Success 200 {object} database.Revision
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Revision or collection not found"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /item/{id}/revisions/{rev} [get]
+func getRevisionHandler(c *gin.Context) {
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || rev < 1 {
+		writeDBError(c, database.ErrRevisionNotFound)
+		return
+	}
+
+	revision, err := database.GetRevision(itemCollection(c), c.Param("id"), rev)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, revision)
+}
+
+// diffRevisionsHandler godoc
+// @Summary Diff two item revisions
+// @Description Lists the changes between the data of two revisions as JSON pointers into the data. Arrays are compared as a whole.
+// @Tags revisions
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param from query int true "Older revision number"
+// @Param to query int true "Newer revision number"
+// @Param collection query string false "Collection the item belongs to"
+// @Success 200 {array} database.Change
+// @Failure 400 {object} map[string]string "Invalid item ID or revision numbers"
+// @Failure 404 {object} map[string]string "Revision or collection not found"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /item/{id}/diff [get]
+func diffRevisionsHandler(c *gin.Context) {
+	from, err1 := strconv.Atoi(c.Query("from"))
+	to, err2 := strconv.Atoi(c.Query("to"))
+	if err1 != nil || err2 != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "from and to must be revision numbers"})
+		return
+	}
+
+	changes, err := database.DiffRevisions(itemCollection(c), c.Param("id"), from, to)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, changes)
+}
+
+// restoreRevisionHandler godoc
+// @Summary Restore an item revision
+// @Description Writes the data of an old revision back to the item as a new revision, recreating the item if it was deleted.
+// @Description The data must match the current collection schema.
+// @Tags revisions
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param rev path int true "Revision number"
+// @Param collection query string false "Collection the item belongs to"
+// @Param If-Match header string false "ETag the item must still have"
+// @Success 200 {object} Item
+// @Header 200 {string} ETag "New item version"
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Revision or collection not found"
+// @Failure 412 {object} map[string]string "Item version does not match If-Match"
+// @Failure 422 {object} validationErrorResponse "Revision does not match the collection schema"
+// @Failure 500 {object} map[string]string "Failed to read/write DB"
+// @Router /item/{id}/revisions/{rev}/restore [post]
+func restoreRevisionHandler(c *gin.Context) {
+	rev, err := strconv.Atoi(c.Param("rev"))
+	if err != nil || rev < 1 {
+		writeDBError(c, database.ErrRevisionNotFound)
+		return
+	}
+
+	item, err := database.RestoreRevision(itemCollection(c), c.Param("id"), rev, writeOptions(c))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Header("ETag", itemETag(item))
+	c.JSON(http.StatusOK, item)
+}
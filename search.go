@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"go-backend/database"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+// searchItemsHandler godoc
+// @Summary Search items
+// @Description Full-text search over the string values of item data, best matches first.
+// @Description Every clause must match: a term (crane), a "quoted phrase", a prefix (cran*) or any of these scoped to a field and its children (title:crane, data.notes:"tower crane").
+// @Description Highlights wrap the matches in <mark>, the rest of the snippet is HTML escaped.
+// @Tags items
+// @Produce json
+// @Param q query string true "Search query" example("tower crane" site:north)
+// @Param collection query string false "Collection to search, items outside any collection by default"
+// @Param limit query int false "Page size (default 20, max 100)"
+// @Param offset query int false "Number of results to skip"
+// @Success 200 {object} database.SearchResults
+// @Failure 400 {object} map[string]string "Invalid query"
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /items/search [get]
+func searchItemsHandler(c *gin.Context) {
+	limit, err := queryInt(c, "limit", 20, 1, 100)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+	offset, err := queryInt(c, "offset", 0, 0, -1)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	results, err := database.Search(itemCollection(c), c.Query("q"), offset, limit)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, results)
+}
+
+// queryInt reads an integer query parameter of at least min, capped at max unless max is negative
+func queryInt(c *gin.Context, name string, def, min, max int) (int, error) {
+	s := c.Query(name)
+	if s == "" {
+		return def, nil
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < min {
+		return 0, fmt.Errorf("%w: %s must be an integer of at least %d", database.ErrInvalidQuery, name, min)
+	}
+	if max >= 0 && n > max {
+		n = max
+	}
+	return n, nil
+}
@@ -0,0 +1,290 @@
+package main
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"go-backend/database"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// snapshotDir is where snapshots are written, SNAPSHOT_DIR overrides the default
+func snapshotDir() string {
+	if dir := os.Getenv("SNAPSHOT_DIR"); dir != "" {
+		return dir
+	}
+	return database.SnapshotDir
+}
+
+// snapshotRetention reads SNAPSHOT_KEEP_LAST and SNAPSHOT_KEEP_DAILY, unset keeps every snapshot
+func snapshotRetention() database.Retention {
+	last, _ := strconv.Atoi(os.Getenv("SNAPSHOT_KEEP_LAST"))
+	daily, _ := strconv.Atoi(os.Getenv("SNAPSHOT_KEEP_DAILY"))
+	return database.Retention{Last: last, Daily: daily}
+}
+
+// startSnapshotSchedule takes snapshots every SNAPSHOT_INTERVAL (e.g. 6h), unset disables it
+func startSnapshotSchedule() {
+	s := os.Getenv("SNAPSHOT_INTERVAL")
+	if s == "" {
+		return
+	}
+
+	interval, err := time.ParseDuration(s)
+	if err != nil || interval <= 0 {
+		log.Fatalf("Invalid SNAPSHOT_INTERVAL %q", s)
+	}
+	go database.ScheduleSnapshots(snapshotDir(), interval, snapshotRetention(), nil)
+}
+
+// openWAL replays the write-ahead log at WAL_PATH and logs every later
+// commit to it, WAL=off disables it. The log is compacted every
+// WAL_COMPACT_INTERVAL (default 10m), see startWALCompaction.
+func openWAL() *database.WAL {
+	if os.Getenv("WAL") == "off" {
+		return nil
+	}
+
+	path := os.Getenv("WAL_PATH")
+	if path == "" {
+		path = database.WALFile
+	}
+	w, err := database.OpenWAL(path)
+	if err != nil {
+		log.Fatalf("Failed to open write-ahead log: %v", err)
+	}
+
+	replayed, err := database.Recover(w, snapshotDir())
+	if err != nil {
+		log.Fatalf("Failed to replay write-ahead log: %v", err)
+	}
+	if replayed > 0 {
+		log.Printf("Replayed %d write-ahead log records", replayed)
+	}
+	return w
+}
+
+// compactionRetention prunes the snapshots compaction takes of a memory
+// database unless SNAPSHOT_KEEP_LAST or SNAPSHOT_KEEP_DAILY are set
+var compactionRetention = database.Retention{Last: 6, Daily: 7}
+
+// startWALCompaction compacts the write-ahead log every WAL_COMPACT_INTERVAL
+// if it holds commits. JSON files are rewritten then, a memory database is
+// written to a snapshot.
+func startWALCompaction() {
+	interval, err := envDuration("WAL_COMPACT_INTERVAL", 10*time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	r := snapshotRetention()
+	if r == (database.Retention{}) {
+		r = compactionRetention
+	}
+	go database.ScheduleCompaction(snapshotDir(), interval, r, nil)
+}
+
+// envDuration reads a positive duration such as 10m from the environment
+func envDuration(name string, def time.Duration) (time.Duration, error) {
+	s := os.Getenv(name)
+	if s == "" {
+		return def, nil
+	}
+
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("Invalid %s %q", name, s)
+	}
+	return d, nil
+}
+
+// requireAdmin protects the admin endpoints with the ADMIN_TOKEN bearer
+// token. Without ADMIN_TOKEN they are closed, a snapshot restore replaces
+// the whole database.
+func requireAdmin(c *gin.Context) {
+	token := os.Getenv("ADMIN_TOKEN")
+	if token == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Admin endpoints are disabled, set ADMIN_TOKEN to enable them"})
+		return
+	}
+
+	if subtle.ConstantTimeCompare([]byte(c.GetHeader("Authorization")), []byte("Bearer "+token)) != 1 {
+		c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "Unauthorized"})
+	}
+}
+
+// listSnapshotsHandler godoc
+// @Summary List snapshots
+// @Description Returns the complete snapshots, newest first.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Success 200 {array} database.SnapshotInfo
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admin endpoints are disabled without ADMIN_TOKEN"
+// @Failure 500 {object} map[string]string "Failed to read snapshots"
+// @Router /admin/snapshots [get]
+func listSnapshotsHandler(c *gin.Context) {
+	snapshots, err := database.ListSnapshots(snapshotDir())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read snapshots"})
+		return
+	}
+
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// takeSnapshotHandler godoc
+// @Summary Take a snapshot
+// @Description Writes a consistent, gzip compressed and sha256 checksummed snapshot of the whole database, then prunes old snapshots by the retention rules
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Success 201 {object} database.SnapshotInfo
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admin endpoints are disabled without ADMIN_TOKEN"
+// @Failure 500 {object} map[string]string "Failed to take snapshot"
+// @Router /admin/snapshots [post]
+func takeSnapshotHandler(c *gin.Context) {
+	info, err := database.TakeSnapshot(snapshotDir())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to take snapshot"})
+		return
+	}
+
+	if _, err := database.PruneSnapshots(snapshotDir(), snapshotRetention()); err != nil {
+		log.Printf("snapshot pruning failed: %v", err)
+	}
+
+	c.JSON(http.StatusCreated, info)
+}
+
+// verifySnapshotHandler godoc
+// @Summary Verify a snapshot
+// @Description Checks the checksum and content of a snapshot without restoring it
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param name path string true "Snapshot name"
+// @Success 200 {object} database.SnapshotInfo
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admin endpoints are disabled without ADMIN_TOKEN"
+// @Failure 404 {object} map[string]string "Snapshot not found"
+// @Failure 422 {object} map[string]string "Snapshot is corrupt"
+// @Router /admin/snapshots/{name} [get]
+func verifySnapshotHandler(c *gin.Context) {
+	info, err := database.VerifySnapshot(snapshotDir(), c.Param("name"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// restoreSnapshotHandler godoc
+// @Summary Restore a snapshot
+// @Description Replaces the whole database with a snapshot. The snapshot is verified completely first, a corrupt snapshot leaves the live data untouched.
+// @Tags admin
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param name path string true "Snapshot name"
+// @Success 200 {object} database.SnapshotInfo
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admin endpoints are disabled without ADMIN_TOKEN"
+// @Failure 404 {object} map[string]string "Snapshot not found"
+// @Failure 422 {object} map[string]string "Snapshot is corrupt"
+// @Failure 500 {object} map[string]string "Failed to write DB"
+// @Router /admin/snapshots/{name}/restore [post]
+func restoreSnapshotHandler(c *gin.Context) {
+	info, err := database.RestoreSnapshot(snapshotDir(), c.Param("name"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, info)
+}
+
+// deleteSnapshotHandler godoc
+// @Summary Delete a snapshot
+// @Tags admin
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param name path string true "Snapshot name"
+// @Success 204 "Snapshot deleted"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Admin endpoints are disabled without ADMIN_TOKEN"
+// @Failure 404 {object} map[string]string "Snapshot not found"
+// @Router /admin/snapshots/{name} [delete]
+func deleteSnapshotHandler(c *gin.Context) {
+	if err := database.DeleteSnapshot(snapshotDir(), c.Param("name")); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// snapshotCommand implements "snapshot take|list|verify <name>|restore <name>|prune"
+// and returns the exit code
+func snapshotCommand(args []string) int {
+	usage := func() int {
+		fmt.Fprintln(os.Stderr, "usage: snapshot take | list | verify <name> | restore <name> | prune")
+		return 2
+	}
+	if len(args) == 0 {
+		return usage()
+	}
+
+	dir := snapshotDir()
+	var err error
+
+	switch args[0] {
+	case "take":
+		var info database.SnapshotInfo
+		if info, err = database.TakeSnapshot(dir); err == nil {
+			fmt.Printf("%s %d items %s\n", info.Name, info.Items, info.SHA256)
+			_, err = database.PruneSnapshots(dir, snapshotRetention())
+		}
+	case "list":
+		var snapshots []database.SnapshotInfo
+		if snapshots, err = database.ListSnapshots(dir); err == nil {
+			for _, s := range snapshots {
+				fmt.Printf("%s %d bytes %s\n", s.Name, s.Size, s.SHA256)
+			}
+		}
+	case "verify", "restore":
+		if len(args) != 2 {
+			return usage()
+		}
+		var info database.SnapshotInfo
+		if args[0] == "verify" {
+			info, err = database.VerifySnapshot(dir, args[1])
+		} else {
+			info, err = database.RestoreSnapshot(dir, args[1])
+		}
+		if err == nil {
+			fmt.Printf("%s ok, %d items\n", info.Name, info.Items)
+		}
+	case "prune":
+		var pruned []string
+		if pruned, err = database.PruneSnapshots(dir, snapshotRetention()); err == nil {
+			for _, name := range pruned {
+				fmt.Println("deleted", name)
+			}
+		}
+	default:
+		return usage()
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "snapshot:", err)
+		return 1
+	}
+	return 0
+}
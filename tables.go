@@ -0,0 +1,425 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"go-backend/tables"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// tablesDir holds the JSON and CSV files served as tables
+var tablesDir = "./data"
+
+// parseTableQuery parses the query parameters of a table request, writing
+// the error response if they are invalid. Parameters listed in ignore or
+// naming no column, like cache busters, are skipped.
+func parseTableQuery(c *gin.Context, columns []string, ignore ...string) (tables.Query, bool) {
+	values := c.Request.URL.Query()
+	ignore = append(ignore, tables.UnknownParams(values, columns)...)
+
+	q, err := tables.ParseQuery(values, ignore...)
+	if err == nil {
+		err = q.Validate(columns)
+	}
+	if err != nil {
+		writeDBError(c, err)
+		return q, false
+	}
+	return q, true
+}
+
+// writeTablePage writes the page of rows selected by the query parameters
+func writeTablePage(c *gin.Context, rows []tables.Row, columns []string) {
+	q, ok := parseTableQuery(c, columns)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, q.Apply(rows))
+}
+
+// writeTableExport writes the rows selected by the query parameters, all
+// of them rather than a page, as a file download in the requested format
+func writeTableExport(c *gin.Context, t *tables.Table) {
+	format := c.DefaultQuery("format", tables.ExportCSV)
+	contentType, ok := tables.ContentTypes[format]
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be csv, xlsx or pdf"})
+		return
+	}
+
+	rows, ok := selectTableRows(c, t, "format")
+	if !ok {
+		return
+	}
+
+	var buf bytes.Buffer
+	if err := tables.Export(&buf, format, t.Name, t.Columns, rows); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s.%s"`, t.Name, format))
+	c.Data(http.StatusOK, contentType, buf.Bytes())
+}
+
+// openTable reads the table named in the path, writing the error response if that fails
+func openTable(c *gin.Context) (*tables.Table, bool) {
+	t, err := tables.Open(tablesDir, c.Param("name"))
+	if err != nil {
+		writeDBError(c, err)
+		return nil, false
+	}
+	return t, true
+}
+
+// listTablesHandler godoc
+// @Summary List tables
+// @Description Returns the JSON and CSV files in ./data that are served as tables, sorted by name. A name with both files is the JSON table.
+// @Tags tables
+// @Produce json
+// @Success 200 {array} tables.Table
+// @Failure 500 {object} map[string]string
+// @Router /tables [get]
+func listTablesHandler(c *gin.Context) {
+	list, err := tables.List(tablesDir)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	c.JSON(http.StatusOK, list)
+}
+
+// getTableHandler godoc
+// @Summary Get table rows
+// @Description Returns a page of the rows of ./data/{name}.json or ./data/{name}.csv. Empty CSV cells are null, CSV columns of only numbers or only booleans are typed.
+// @Description Any query parameter other than sort, page and pageSize filters on a column as for /tableData. Parameters naming no column are ignored.
+// @Tags tables
+// @Produce json
+// @Param name path string true "Table name" example(tableData)
+// @Param sort query string false "Comma separated columns, prefix with - for descending" example(-price,name)
+// @Param page query int false "Page number, starting at 1"
+// @Param pageSize query int false "Rows per page (default 50, max 1000)"
+// @Success 200 {object} tables.Page
+// @Failure 400 {object} map[string]string "Invalid query"
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name} [get]
+func getTableHandler(c *gin.Context) {
+	t, ok := openTable(c)
+	if !ok {
+		return
+	}
+
+	writeTablePage(c, t.Rows, t.Columns)
+}
+
+// getTableSchemaHandler godoc
+// @Summary Infer table schema
+// @Description Returns each column of a table with the type shared by its values, whether it can be null or missing, and its number of distinct values.
+// @Description Integers widen to number, strings that are all RFC 3339 times or YYYY-MM-DD dates are of type date, columns with incompatible values are mixed.
+// @Tags tables
+// @Produce json
+// @Param name path string true "Table name" example(tableData)
+// @Success 200 {object} tables.Schema
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/schema [get]
+func getTableSchemaHandler(c *gin.Context) {
+	t, ok := openTable(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, tables.Infer(t))
+}
+
+// tableRowBody binds a row from the request body, writing 400 if it is not a JSON object
+func tableRowBody(c *gin.Context) (tables.Row, bool) {
+	var row tables.Row
+	if err := c.ShouldBindJSON(&row); err != nil || row == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return nil, false
+	}
+	return row, true
+}
+
+// insertTableRowHandler godoc
+// @Summary Insert a table row
+// @Description Appends a row to the table file, which is replaced atomically. Values must match the column rules: those declared in ./data/{name}.schema.json, or else inferred from the rows, requiring the existing type and a value where no row lacks one.
+// @Description Columns the table does not have are rejected unless it has no columns yet. The key column needs a unique string or integer. The first edit of a table without a declared key saves the inferred key to ./data/{name}.schema.json.
+// @Tags tables
+// @Accept json
+// @Produce json
+// @Param name path string true "Table name" example(tableData)
+// @Param row body map[string]interface{} true "Row"
+// @Success 201 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid JSON"
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 409 {object} map[string]string "A row with this key exists"
+// @Failure 422 {object} validationErrorResponse "Row does not match the column rules"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/rows [post]
+func insertTableRowHandler(c *gin.Context) {
+	row, ok := tableRowBody(c)
+	if !ok {
+		return
+	}
+
+	row, err := tables.Insert(tablesDir, c.Param("name"), row)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, row)
+}
+
+// getTableRowHandler godoc
+// @Summary Get a table row
+// @Description Returns the row whose key column, see the table schema, holds key
+// @Tags tables
+// @Produce json
+// @Param name path string true "Table name" example(tableData)
+// @Param key path string true "Value of the key column" example(Apple)
+// @Success 200 {object} map[string]interface{}
+// @Failure 404 {object} map[string]string "Table or row not found"
+// @Failure 409 {object} map[string]string "Table has no key column"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/rows/{key} [get]
+func getTableRowHandler(c *gin.Context) {
+	row, err := tables.Get(tablesDir, c.Param("name"), c.Param("key"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, row)
+}
+
+// replaceTableRowHandler godoc
+// @Summary Replace a table row
+// @Description Replaces the row whose key column holds key. The new row is validated like an inserted one and may change the key unless another row has it.
+// @Tags tables
+// @Accept json
+// @Produce json
+// @Param name path string true "Table name" example(tableData)
+// @Param key path string true "Value of the key column" example(Apple)
+// @Param row body map[string]interface{} true "Row"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid JSON"
+// @Failure 404 {object} map[string]string "Table or row not found"
+// @Failure 409 {object} map[string]string "Table has no key column, or another row has the new key"
+// @Failure 422 {object} validationErrorResponse "Row does not match the column rules"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/rows/{key} [put]
+func replaceTableRowHandler(c *gin.Context) {
+	row, ok := tableRowBody(c)
+	if !ok {
+		return
+	}
+
+	row, err := tables.Replace(tablesDir, c.Param("name"), c.Param("key"), row)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, row)
+}
+
+// updateTableRowHandler godoc
+// @Summary Update a table row
+// @Description Sets the given columns of the row whose key column holds key, null clears a column. The resulting row is validated like an inserted one.
+// @Tags tables
+// @Accept json
+// @Produce json
+// @Param name path string true "Table name" example(tableData)
+// @Param key path string true "Value of the key column" example(Apple)
+// @Param values body map[string]interface{} true "Columns to set"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string "Invalid JSON"
+// @Failure 404 {object} map[string]string "Table or row not found"
+// @Failure 409 {object} map[string]string "Table has no key column, or another row has the new key"
+// @Failure 422 {object} validationErrorResponse "Row does not match the column rules"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/rows/{key} [patch]
+func updateTableRowHandler(c *gin.Context) {
+	values, ok := tableRowBody(c)
+	if !ok {
+		return
+	}
+
+	row, err := tables.Update(tablesDir, c.Param("name"), c.Param("key"), values)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, row)
+}
+
+// deleteTableRowHandler godoc
+// @Summary Delete a table row
+// @Tags tables
+// @Param name path string true "Table name" example(tableData)
+// @Param key path string true "Value of the key column" example(Apple)
+// @Success 204 "Row deleted"
+// @Failure 404 {object} map[string]string "Table or row not found"
+// @Failure 409 {object} map[string]string "Table has no key column"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/rows/{key} [delete]
+func deleteTableRowHandler(c *gin.Context) {
+	if err := tables.Delete(tablesDir, c.Param("name"), c.Param("key")); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// exportTableDataHandler godoc
+// @Summary Export table data
+// @Description Downloads the rows of ./data/tableData.json selected by the same column filters and sort as GET /tableData, without paging. Parameters naming no column are ignored.
+// @Description XLSX keeps numbers and booleans typed. PDF prints Latin-1 text only, other characters show as "?", and cuts off cells too wide for their column.
+// @ID export-table-data
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce application/pdf
+// @Param format query string false "File format (default csv)" Enums(csv, xlsx, pdf)
+// @Param sort query string false "Comma separated columns, prefix with - for descending" example(-price,name)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string "Invalid format or query"
+// @Failure 500 {object} map[string]string
+// @Router /tableData/export [get]
+func exportTableDataHandler(c *gin.Context) {
+	t, err := tables.Open(tablesDir, "tableData")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+		return
+	}
+
+	writeTableExport(c, t)
+}
+
+// exportTableHandler godoc
+// @Summary Export a table
+// @Description Downloads the rows of a table selected by the same column filters and sort as GET /tables/{name}, without paging. See GET /tableData/export for the formats.
+// @Tags tables
+// @Produce text/csv
+// @Produce application/vnd.openxmlformats-officedocument.spreadsheetml.sheet
+// @Produce application/pdf
+// @Param name path string true "Table name" example(tableData)
+// @Param format query string false "File format (default csv)" Enums(csv, xlsx, pdf)
+// @Param sort query string false "Comma separated columns, prefix with - for descending" example(-price,name)
+// @Success 200 {file} file
+// @Failure 400 {object} map[string]string "Invalid format or query"
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/export [get]
+func exportTableHandler(c *gin.Context) {
+	t, ok := openTable(c)
+	if !ok {
+		return
+	}
+
+	writeTableExport(c, t)
+}
+
+// selectTableRows returns the rows of a table matching the column filters
+// in the query parameters, writing the error response if they are invalid
+func selectTableRows(c *gin.Context, t *tables.Table, ignore ...string) ([]tables.Row, bool) {
+	q, ok := parseTableQuery(c, t.Columns, ignore...)
+	if !ok {
+		return nil, false
+	}
+	return q.Select(t.Rows), true
+}
+
+// splitColumns splits a comma separated list of columns, nil if s is empty
+func splitColumns(s string) []string {
+	if s == "" {
+		return nil
+	}
+	columns := strings.Split(s, ",")
+	for i := range columns {
+		columns[i] = strings.TrimSpace(columns[i])
+	}
+	return columns
+}
+
+// tableStatsHandler godoc
+// @Summary Column statistics
+// @Description Returns count, nulls, sum, min, max, mean, median, standard deviation and percentiles of number columns over the rows matching the column filters, which work as for GET /tables/{name}.
+// @Description Percentiles interpolate linearly between values. Statistics of columns without numbers are null.
+// @Tags tables
+// @Produce json
+// @Param name path string true "Table name" example(tableData)
+// @Param columns query string false "Comma separated number columns, all by default" example(price)
+// @Param percentiles query string false "Comma separated percentiles from 0 to 100 (default 25,50,75,90,95,99)" example(10,50,90)
+// @Success 200 {array} tables.ColumnStats
+// @Failure 400 {object} map[string]string "Invalid query, unknown or non-number column"
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/stats [get]
+func tableStatsHandler(c *gin.Context) {
+	t, ok := openTable(c)
+	if !ok {
+		return
+	}
+	rows, ok := selectTableRows(c, t, "columns", "percentiles")
+	if !ok {
+		return
+	}
+
+	percentiles, err := tables.ParsePercentiles(c.Query("percentiles"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+	stats, err := tables.Stats(t, rows, splitColumns(c.Query("columns")), percentiles)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, stats)
+}
+
+// tablePivotHandler godoc
+// @Summary Pivot table
+// @Description Aggregates a value per combination of the row columns, over the rows matching the column filters, which work as for GET /tables/{name}. With a pivot column the value is also broken down by each of its values.
+// @Description Rows and pivot columns are ordered by value, the totals aggregate over the whole row, column or selection. A group without numbers has a null sum, avg, min or max.
+// @Tags tables
+// @Produce json
+// @Param name path string true "Table name" example(tableData)
+// @Param rows query string true "Comma separated row columns" example(category)
+// @Param columns query string false "Pivot column"
+// @Param value query string false "count (default) or sum, avg, min or max of a column" example(avg(price))
+// @Success 200 {object} tables.Pivot
+// @Failure 400 {object} map[string]string "Invalid query or unknown column"
+// @Failure 404 {object} map[string]string "Table not found"
+// @Failure 500 {object} map[string]string "Invalid table file"
+// @Router /tables/{name}/pivot [get]
+func tablePivotHandler(c *gin.Context) {
+	t, ok := openTable(c)
+	if !ok {
+		return
+	}
+	rows, ok := selectTableRows(c, t, "rows", "columns", "value")
+	if !ok {
+		return
+	}
+
+	pivot, err := tables.PivotTable(t, rows, splitColumns(c.Query("rows")), c.Query("columns"), c.DefaultQuery("value", "count"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, pivot)
+}
@@ -0,0 +1,59 @@
+package tables
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Export formats
+const (
+	ExportCSV  = "csv"
+	ExportXLSX = "xlsx"
+	ExportPDF  = "pdf"
+)
+
+// ErrUnknownFormat is returned for export formats other than csv, xlsx and pdf
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// ContentTypes maps export formats to their media types
+var ContentTypes = map[string]string{
+	ExportCSV:  "text/csv; charset=utf-8",
+	ExportXLSX: "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet",
+	ExportPDF:  "application/pdf",
+}
+
+// Export writes the rows in the given format, the columns in order with a
+// header row. Title names the sheet of XLSX files and heads PDF pages.
+func Export(w io.Writer, format, title string, columns []string, rows []Row) error {
+	switch format {
+	case ExportCSV:
+		return WriteCSV(w, columns, rows)
+	case ExportXLSX:
+		return WriteXLSX(w, title, columns, rows)
+	case ExportPDF:
+		return WritePDF(w, title, columns, rows)
+	default:
+		return fmt.Errorf("%w %q, use csv, xlsx or pdf", ErrUnknownFormat, format)
+	}
+}
+
+// WriteCSV writes a header line and one record per row, null as an empty cell
+func WriteCSV(w io.Writer, columns []string, rows []Row) error {
+	cw := csv.NewWriter(w)
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, row := range rows {
+		record := make([]string, len(columns))
+		for i, col := range columns {
+			record[i] = formatCell(row[col])
+		}
+		if err := cw.Write(record); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
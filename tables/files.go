@@ -0,0 +1,280 @@
+package tables
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/atomicfile"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// Table file formats
+const (
+	FormatJSON = "json"
+	FormatCSV  = "csv"
+)
+
+var (
+	// ErrTableNotFound is returned for names without a table file
+	ErrTableNotFound = errors.New("table not found")
+	// ErrInvalidTable is returned for table files that cannot be parsed
+	ErrInvalidTable = errors.New("invalid table")
+)
+
+var tableName = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9_-]{0,63}$`)
+
+// Table is a dataset read from <name>.json, an array of objects, or
+// <name>.csv, a header line followed by one line per row. Declared holds
+// the rules of <name>.schema.json if there is one.
+type Table struct {
+	Name     string    `json:"name" example:"tableData"`
+	Format   string    `json:"format" example:"json"`
+	Path     string    `json:"-"`
+	Rows     []Row     `json:"-"`
+	Columns  []string  `json:"-"`
+	Declared *Declared `json:"-"`
+
+	// The records of a CSV file by row and the column types they were
+	// parsed with, so unchanged cells are saved as they were written
+	records [][]string
+	types   []string
+}
+
+// List returns the tables in dir sorted by name. A name with both a JSON
+// and a CSV file is the JSON table.
+func List(dir string) ([]Table, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	byName := map[string]Table{}
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name, format, ok := splitName(e.Name())
+		if !ok {
+			continue
+		}
+		if t, seen := byName[name]; seen && t.Format == FormatJSON {
+			continue
+		}
+		byName[name] = Table{Name: name, Format: format, Path: filepath.Join(dir, e.Name())}
+	}
+
+	tables := make([]Table, 0, len(byName))
+	for _, t := range byName {
+		tables = append(tables, t)
+	}
+	sort.Slice(tables, func(i, j int) bool {
+		return tables[i].Name < tables[j].Name
+	})
+	return tables, nil
+}
+
+// Open reads the table called name from dir
+func Open(dir, name string) (*Table, error) {
+	if !tableName.MatchString(name) {
+		return nil, ErrTableNotFound
+	}
+
+	for _, format := range []string{FormatJSON, FormatCSV} {
+		path := filepath.Join(dir, name+"."+format)
+		if _, err := os.Stat(path); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+
+		t := &Table{Name: name, Format: format, Path: path}
+		var err error
+		if format == FormatJSON {
+			t.Rows, t.Columns, err = Load(path)
+		} else {
+			err = t.loadCSV()
+		}
+		if err != nil {
+			return nil, err
+		}
+		if t.Declared, err = loadDeclared(filepath.Join(dir, name+schemaExt)); err != nil {
+			return nil, err
+		}
+		if t.Declared != nil && t.Declared.Key != "" && !t.hasRule(t.Declared.Key) {
+			return nil, fmt.Errorf("%w: %s: key %q is not a column", ErrInvalidTable, name+schemaExt, t.Declared.Key)
+		}
+		return t, nil
+	}
+	return nil, ErrTableNotFound
+}
+
+// save writes the rows back to the table file, atomically and in the
+// layout of the format: one JSON object per line, or a CSV header and
+// records with empty cells for null. CSV cells whose value did not change
+// keep their text, e.g. 00501 or 1.50.
+func (t *Table) save() error {
+	var buf bytes.Buffer
+
+	if t.Format == FormatCSV {
+		w := csv.NewWriter(&buf)
+		w.Write(t.Columns)
+		for r, row := range t.Rows {
+			record := make([]string, len(t.Columns))
+			for i, col := range t.Columns {
+				record[i] = t.csvCell(r, i, row[col])
+			}
+			w.Write(record)
+		}
+		w.Flush()
+		if err := w.Error(); err != nil {
+			return err
+		}
+	} else {
+		buf.WriteString("[\n")
+		for i, row := range t.Rows {
+			buf.WriteString("  {")
+			first := true
+			for _, col := range t.Columns {
+				v, ok := row[col]
+				if !ok {
+					continue
+				}
+				if !first {
+					buf.WriteString(",")
+				}
+				first = false
+				key, _ := marshal(col)
+				value, err := marshal(v)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(&buf, " %s: %s", key, value)
+			}
+			buf.WriteString(" }")
+			if i < len(t.Rows)-1 {
+				buf.WriteString(",")
+			}
+			buf.WriteString("\n")
+		}
+		buf.WriteString("]\n")
+	}
+
+	return atomicfile.WriteFile(t.Path, buf.Bytes(), 0644)
+}
+
+// marshal encodes a value as JSON without escaping HTML characters
+func marshal(v interface{}) ([]byte, error) {
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	return bytes.TrimRight(buf.Bytes(), "\n"), nil
+}
+
+// formatCell converts a value to a CSV cell
+func formatCell(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case bool:
+		return strconv.FormatBool(v)
+	default:
+		raw, _ := marshal(v)
+		return string(raw)
+	}
+}
+
+// csvCell returns the text of column i of row r: the text read from the
+// file if the row has one and its value is unchanged, else the value formatted
+func (t *Table) csvCell(r, i int, v interface{}) string {
+	if r < len(t.records) && i < len(t.records[r]) && i < len(t.types) {
+		if text := t.records[r][i]; reflect.DeepEqual(parseCell(text, t.types[i]), v) {
+			return text
+		}
+	}
+	return formatCell(v)
+}
+
+// LoadCSV reads a table from a CSV file and returns its rows and the
+// columns of its header. Empty cells are null, columns whose other cells
+// are all numbers or all booleans hold those types.
+func LoadCSV(path string) ([]Row, []string, error) {
+	t := &Table{Path: path}
+	if err := t.loadCSV(); err != nil {
+		return nil, nil, err
+	}
+	return t.Rows, t.Columns, nil
+}
+
+// loadCSV reads the rows and columns of a CSV table and keeps the records
+func (t *Table) loadCSV() error {
+	f, err := os.Open(t.Path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return fmt.Errorf("%w: %s: %v", ErrInvalidTable, t.Path, err)
+	}
+	if len(records) == 0 {
+		t.Rows, t.Columns = []Row{}, []string{}
+		return nil
+	}
+
+	header := records[0]
+	seen := map[string]bool{}
+	for i, h := range header {
+		header[i] = strings.TrimSpace(strings.TrimPrefix(h, "\ufeff"))
+		if header[i] == "" || seen[header[i]] {
+			return fmt.Errorf("%w: %s: column %d has no or a duplicate name", ErrInvalidTable, t.Path, i+1)
+		}
+		seen[header[i]] = true
+	}
+
+	types := make([]string, len(header))
+	for i := range header {
+		cells := make([]string, 0, len(records)-1)
+		for _, rec := range records[1:] {
+			cells = append(cells, rec[i])
+		}
+		types[i] = cellType(cells)
+	}
+
+	rows := make([]Row, 0, len(records)-1)
+	for _, rec := range records[1:] {
+		row := Row{}
+		for i, h := range header {
+			row[h] = parseCell(rec[i], types[i])
+		}
+		rows = append(rows, row)
+	}
+
+	t.Rows, t.Columns = rows, header
+	t.records, t.types = records[1:], types
+	return nil
+}
+
+// splitName splits a file name into table name and format
+func splitName(file string) (string, string, bool) {
+	ext := filepath.Ext(file)
+	name := strings.TrimSuffix(file, ext)
+	format := strings.ToLower(strings.TrimPrefix(ext, "."))
+	if (format != FormatJSON && format != FormatCSV) || !tableName.MatchString(name) {
+		return "", "", false
+	}
+	return name, format, true
+}
@@ -0,0 +1,124 @@
+package tables
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeTable(t *testing.T, name, content string) string {
+	t.Helper()
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func readTable(t *testing.T, dir, name string) string {
+	t.Helper()
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(content)
+}
+
+func TestCSVEditKeepsCellText(t *testing.T) {
+	dir := writeTable(t, "zips.csv", "code,zip,price,amount,active\n"+
+		"a,01234,1.50,1e3,TRUE\n"+
+		"b,00501,2.25,7,false\n"+
+		"c,10115,3,8,true\n")
+
+	if _, err := Update(dir, "zips", "b", Row{"amount": 9.0}); err != nil {
+		t.Fatal(err)
+	}
+	if err := Delete(dir, "zips", "c"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Insert(dir, "zips", Row{"code": "d", "zip": "00601", "price": 1.5, "amount": 2.0, "active": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	want := "code,zip,price,amount,active\n" +
+		"a,01234,1.50,1e3,TRUE\n" +
+		"b,00501,2.25,9,false\n" +
+		"d,00601,1.5,2,true\n"
+	if got := readTable(t, dir, "zips.csv"); got != want {
+		t.Errorf("file after edits:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+	content := "code,zip,price\n" +
+		"a,01234,1.50\n" +
+		"b,,2.0\n"
+	dir := writeTable(t, "zips.csv", content)
+
+	table, err := Open(dir, "zips")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zip := table.Rows[0]["zip"]; zip != "01234" {
+		t.Errorf("zip = %v, want the string 01234", zip)
+	}
+	if err := table.save(); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := readTable(t, dir, "zips.csv"); got != content {
+		t.Errorf("saved file:\n%s\nwant:\n%s", got, content)
+	}
+}
+
+func TestJSONEdit(t *testing.T) {
+	dir := writeTable(t, "items.json", `[
+  { "name": "a", "price": 1.5 },
+  { "name": "b", "price": 2 }
+]
+`)
+
+	if _, err := Replace(dir, "items", "b", Row{"name": "c", "price": 3.0}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Insert(dir, "items", Row{"name": "c", "price": 4.0}); err != ErrRowExists {
+		t.Errorf("inserting a taken key: err = %v, want ErrRowExists", err)
+	}
+	if _, err := Get(dir, "items", "b"); err == nil {
+		t.Error("the replaced key is still found")
+	}
+
+	want := `[
+  { "name": "a", "price": 1.5 },
+  { "name": "c", "price": 3 }
+]
+`
+	if got := readTable(t, dir, "items.json"); got != want {
+		t.Errorf("file after edits:\n%s\nwant:\n%s", got, want)
+	}
+}
+
+func TestEditKeepsKey(t *testing.T) {
+	dir := writeTable(t, "parts.json", `[
+  { "name": "bolt", "id": 1 },
+  { "name": "bolt", "id": 2 },
+  { "name": "nut", "id": 3 }
+]
+`)
+
+	if err := Delete(dir, "parts", "2"); err != nil {
+		t.Fatal(err)
+	}
+	if got := readTable(t, dir, "parts.schema.json"); got != "{\n  \"key\": \"id\",\n  \"columns\": []\n}\n" {
+		t.Errorf("schema file after the first edit:\n%s", got)
+	}
+
+	// name is distinct now, rows are still addressed by id
+	if _, err := Get(dir, "parts", "3"); err != nil {
+		t.Errorf("getting row 3: %v", err)
+	}
+	if _, err := Update(dir, "parts", "nut", Row{"name": "washer"}); !errors.Is(err, ErrRowNotFound) {
+		t.Errorf("updating by name: err = %v, want ErrRowNotFound", err)
+	}
+}
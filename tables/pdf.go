@@ -0,0 +1,252 @@
+package tables
+
+import (
+	"bytes"
+	"compress/zlib"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// Page geometry in points: A4, turned to landscape when the columns do not fit
+const (
+	pdfShortSide = 595.0
+	pdfLongSide  = 842.0
+	pdfMargin    = 36.0
+	pdfFontSize  = 9.0
+	pdfRowHeight = 14.0
+	pdfPadding   = 4.0
+	pdfMaxColumn = 220.0
+	pdfTitleSize = 12.0
+	pdfTitleGap  = 22.0
+)
+
+// helveticaWidths are the widths of the printable ASCII characters in
+// Helvetica, in thousandths of the font size, from the standard AFM
+var helveticaWidths = [95]int{
+	278, 278, 355, 556, 556, 889, 667, 191, 333, 333, 389, 584, 278, 333, 278, 278, // space to /
+	556, 556, 556, 556, 556, 556, 556, 556, 556, 556, 278, 278, 584, 584, 584, 556, // 0 to ?
+	1015, 667, 667, 722, 722, 667, 611, 778, 722, 278, 500, 667, 556, 833, 722, 778, // @ to O
+	667, 778, 722, 667, 611, 722, 667, 944, 667, 667, 611, 278, 278, 278, 469, 556, // P to _
+	333, 556, 556, 500, 556, 556, 278, 556, 556, 222, 222, 500, 222, 833, 556, 556, // ` to o
+	556, 556, 333, 500, 278, 556, 500, 722, 500, 500, 500, 334, 260, 334, 584, // p to ~
+}
+
+// WritePDF writes the rows as a table over as many pages as needed, with
+// the title and page number atop every page and the header repeated.
+// Text uses the standard Helvetica fonts, so characters outside Latin-1
+// print as "?" and cells too wide for their column are cut off with "...".
+// Columns holding only numbers are right-aligned.
+func WritePDF(w io.Writer, title string, columns []string, rows []Row) error {
+	cells := make([][]string, len(rows))
+	for r, row := range rows {
+		cells[r] = make([]string, len(columns))
+		for i, col := range columns {
+			cells[r][i] = formatCell(row[col])
+		}
+	}
+
+	widths := make([]float64, len(columns))
+	numeric := make([]bool, len(columns))
+	total := 0.0
+	for i, col := range columns {
+		widths[i] = textWidth(col) * 1.1
+		numeric[i] = true
+		for r, row := range rows {
+			if _, ok := row[col].(float64); !ok && row[col] != nil {
+				numeric[i] = false
+			}
+			if w := textWidth(cells[r][i]); w > widths[i] {
+				widths[i] = w
+			}
+		}
+		widths[i] += 2 * pdfPadding
+		if widths[i] > pdfMaxColumn {
+			widths[i] = pdfMaxColumn
+		}
+		total += widths[i]
+	}
+
+	pageW, pageH := pdfShortSide, pdfLongSide
+	if total > pageW-2*pdfMargin {
+		pageW, pageH = pageH, pageW
+	}
+	if usable := pageW - 2*pdfMargin; total > usable {
+		shrink(widths, usable)
+	}
+
+	tableW := 0.0
+	for _, w := range widths {
+		tableW += w
+	}
+
+	perPage := int((pageH-2*pdfMargin-pdfTitleGap)/pdfRowHeight) - 1
+	pages := (len(rows) + perPage - 1) / perPage
+	if pages == 0 {
+		pages = 1
+	}
+
+	var contents [][]byte
+	for p := 0; p < pages; p++ {
+		var s strings.Builder
+		top := pageH - pdfMargin
+
+		fmt.Fprintf(&s, "BT /F2 %.0f Tf %.2f %.2f Td (%s) Tj ET\n", pdfTitleSize, pdfMargin, top-pdfTitleSize, pdfText(title))
+		pageNo := fmt.Sprintf("Page %d of %d", p+1, pages)
+		fmt.Fprintf(&s, "BT /F1 8 Tf %.2f %.2f Td (%s) Tj ET\n", pageW-pdfMargin-textWidth(pageNo)*8/pdfFontSize, top-pdfTitleSize, pdfText(pageNo))
+
+		y := top - pdfTitleGap
+		fmt.Fprintf(&s, "0.9 g %.2f %.2f %.2f %.2f re f 0 g\n", pdfMargin, y-pdfRowHeight, tableW, pdfRowHeight)
+		writeRow(&s, "F2", columns, widths, numeric, y)
+		y -= pdfRowHeight
+
+		end := (p + 1) * perPage
+		if end > len(rows) {
+			end = len(rows)
+		}
+		for r := p * perPage; r < end; r++ {
+			writeRow(&s, "F1", cells[r], widths, numeric, y)
+			y -= pdfRowHeight
+		}
+		fmt.Fprintf(&s, "0.5 w %.2f %.2f m %.2f %.2f l S\n", pdfMargin, y, pdfMargin+tableW, y)
+
+		contents = append(contents, []byte(s.String()))
+	}
+
+	return writePDFObjects(w, pageW, pageH, contents)
+}
+
+// shrink fits the columns into width: columns narrower than an equal share
+// of what the narrower ones leave keep their width, the others get that share
+func shrink(widths []float64, width float64) {
+	order := make([]int, len(widths))
+	for i := range order {
+		order[i] = i
+	}
+	sort.Slice(order, func(a, b int) bool { return widths[order[a]] < widths[order[b]] })
+
+	for n, i := range order {
+		share := width / float64(len(order)-n)
+		if widths[i] > share {
+			for _, j := range order[n:] {
+				widths[j] = share
+			}
+			return
+		}
+		width -= widths[i]
+	}
+}
+
+// writeRow draws one row of cells whose top edge is at y
+func writeRow(s *strings.Builder, font string, cells []string, widths []float64, right []bool, y float64) {
+	x := pdfMargin
+	baseline := y - pdfRowHeight + (pdfRowHeight-pdfFontSize)/2 + 2
+	for i, cell := range cells {
+		text := fitText(cell, widths[i]-2*pdfPadding)
+		tx := x + pdfPadding
+		if right[i] {
+			tx = x + widths[i] - pdfPadding - textWidth(text)
+		}
+		if text != "" {
+			fmt.Fprintf(s, "BT /%s %.0f Tf %.2f %.2f Td (%s) Tj ET\n", font, pdfFontSize, tx, baseline, pdfText(text))
+		}
+		x += widths[i]
+	}
+}
+
+// writePDFObjects writes the document: catalog, page tree, the two fonts,
+// then a page and its compressed content stream per page, and the xref table
+func writePDFObjects(w io.Writer, pageW, pageH float64, contents [][]byte) error {
+	var buf bytes.Buffer
+	var offsets []int
+	object := func(body string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		fmt.Fprintf(&buf, "%d 0 obj\n%s", len(offsets), body)
+		if stream != nil {
+			buf.WriteString("\nstream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream")
+		}
+		buf.WriteString("\nendobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n%\xe2\xe3\xcf\xd3\n")
+
+	kids := make([]string, len(contents))
+	for i := range contents {
+		kids[i] = fmt.Sprintf("%d 0 R", 5+2*i)
+	}
+	object("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	object(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), len(contents)), nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica /Encoding /WinAnsiEncoding >>", nil)
+	object("<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica-Bold /Encoding /WinAnsiEncoding >>", nil)
+
+	for i, content := range contents {
+		var z bytes.Buffer
+		zw := zlib.NewWriter(&z)
+		zw.Write(content)
+		if err := zw.Close(); err != nil {
+			return err
+		}
+
+		object(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %.0f %.0f] /Resources << /Font << /F1 3 0 R /F2 4 0 R >> >> /Contents %d 0 R >>", pageW, pageH, 6+2*i), nil)
+		object(fmt.Sprintf("<< /Length %d /Filter /FlateDecode >>", z.Len()), z.Bytes())
+	}
+
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref)
+
+	_, err := buf.WriteTo(w)
+	return err
+}
+
+// textWidth estimates the width of text in points at the body font size
+func textWidth(text string) float64 {
+	units := 0
+	for _, r := range text {
+		if r >= ' ' && r <= '~' {
+			units += helveticaWidths[r-' ']
+		} else {
+			units += 556
+		}
+	}
+	return float64(units) * pdfFontSize / 1000
+}
+
+// fitText cuts text off with "..." so it fits into width points
+func fitText(text string, width float64) string {
+	if textWidth(text) <= width {
+		return text
+	}
+	runes := []rune(text)
+	for len(runes) > 0 && textWidth(string(runes)+"...") > width {
+		runes = runes[:len(runes)-1]
+	}
+	if len(runes) == 0 {
+		return ""
+	}
+	return string(runes) + "..."
+}
+
+// pdfText encodes text as the body of a PDF string in WinAnsiEncoding:
+// Latin-1 characters as bytes, others as "?", and ( ) \ escaped
+func pdfText(text string) string {
+	var b strings.Builder
+	for _, r := range text {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteByte(byte(r))
+		case r >= ' ' && r <= '~', r >= 0xA0 && r <= 0xFF:
+			b.WriteByte(byte(r))
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,198 @@
+package tables
+
+import (
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+var (
+	// ErrRowNotFound is returned for keys no row of the table has
+	ErrRowNotFound = errors.New("row not found")
+	// ErrRowExists is returned when a row would repeat the key of another
+	ErrRowExists = errors.New("row already exists")
+	// ErrNoKey is returned when editing rows of a table without a key column
+	ErrNoKey = errors.New("table has no key column")
+)
+
+// editMu serializes edits, so no edit overwrites the file another one just wrote
+var editMu sync.Mutex
+
+// Get returns the row of a table with the given key
+func Get(dir, name, key string) (Row, error) {
+	t, err := Open(dir, name)
+	if err != nil {
+		return nil, err
+	}
+
+	i, err := t.find(key)
+	if err != nil {
+		return nil, err
+	}
+	return t.Rows[i], nil
+}
+
+// Insert appends a row to a table
+func Insert(dir, name string, row Row) (Row, error) {
+	err := edit(dir, name, func(t *Table, rules []Rule, keyCol string) error {
+		if err := t.validate(row, rules, keyCol); err != nil {
+			return err
+		}
+		if keyCol != "" {
+			if _, err := t.find(keyString(row[keyCol])); err == nil {
+				return ErrRowExists
+			}
+		}
+
+		t.Rows = append(t.Rows, row)
+		t.addColumns(row)
+		return nil
+	})
+	return row, err
+}
+
+// Replace replaces the row with the given key. The new row may change the
+// key as long as no other row has it.
+func Replace(dir, name, key string, row Row) (Row, error) {
+	err := edit(dir, name, func(t *Table, rules []Rule, keyCol string) error {
+		i, err := t.find(key)
+		if err != nil {
+			return err
+		}
+		return t.replace(i, row, rules, keyCol)
+	})
+	return row, err
+}
+
+// Update sets the given columns of the row with the given key, null clears a column
+func Update(dir, name, key string, values Row) (Row, error) {
+	var row Row
+
+	err := edit(dir, name, func(t *Table, rules []Rule, keyCol string) error {
+		i, err := t.find(key)
+		if err != nil {
+			return err
+		}
+
+		row = Row{}
+		for k, v := range t.Rows[i] {
+			row[k] = v
+		}
+		for k, v := range values {
+			row[k] = v
+		}
+		return t.replace(i, row, rules, keyCol)
+	})
+	return row, err
+}
+
+// Delete removes the row with the given key
+func Delete(dir, name, key string) error {
+	return edit(dir, name, func(t *Table, _ []Rule, _ string) error {
+		i, err := t.find(key)
+		if err != nil {
+			return err
+		}
+
+		t.Rows = append(t.Rows[:i], t.Rows[i+1:]...)
+		if i < len(t.records) {
+			t.records = append(t.records[:i], t.records[i+1:]...)
+		}
+		return nil
+	})
+}
+
+// edit opens a table, applies fn with the rules and key column as they
+// were before the edit and saves the table if fn succeeds. The first edit
+// of a table without a declared key declares the inferred one, so rows
+// keep their key when an edit makes another column the first distinct one.
+func edit(dir, name string, fn func(t *Table, rules []Rule, keyCol string) error) error {
+	editMu.Lock()
+	defer editMu.Unlock()
+
+	t, err := Open(dir, name)
+	if err != nil {
+		return err
+	}
+
+	rules, keyCol := t.Rules(), Infer(t).Key
+	pin := keyCol != "" && (t.Declared == nil || t.Declared.Key == "")
+	if pin {
+		if t.Declared == nil {
+			t.Declared = &Declared{Columns: []Rule{}}
+		}
+		t.Declared.Key = keyCol
+	}
+
+	if err := fn(t, rules, keyCol); err != nil {
+		return err
+	}
+	if pin {
+		if err := saveDeclared(filepath.Join(dir, name+schemaExt), t.Declared); err != nil {
+			return err
+		}
+	}
+	return t.save()
+}
+
+// find returns the index of the row with the given key
+func (t *Table) find(key string) (int, error) {
+	keyCol := Infer(t).Key
+	if keyCol == "" {
+		return 0, ErrNoKey
+	}
+
+	for i, row := range t.Rows {
+		if v, ok := row[keyCol]; ok && v != nil && keyString(v) == key {
+			return i, nil
+		}
+	}
+	return 0, fmt.Errorf("%w: no row with %s %q", ErrRowNotFound, keyCol, key)
+}
+
+// replace validates a row and puts it at index i
+func (t *Table) replace(i int, row Row, rules []Rule, keyCol string) error {
+	if err := t.validate(row, rules, keyCol); err != nil {
+		return err
+	}
+	if j, err := t.find(keyString(row[keyCol])); err == nil && j != i {
+		return ErrRowExists
+	}
+
+	t.Rows[i] = row
+	t.addColumns(row)
+	return nil
+}
+
+// addColumns appends the columns of a row the table does not have yet, sorted by name
+func (t *Table) addColumns(row Row) {
+	known := map[string]bool{}
+	for _, c := range t.Columns {
+		known[c] = true
+	}
+
+	var added []string
+	for c := range row {
+		if !known[c] {
+			added = append(added, c)
+		}
+	}
+	sort.Strings(added)
+	t.Columns = append(t.Columns, added...)
+}
+
+// keyString is the form of a key value in paths
+func keyString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	default:
+		raw, _ := marshal(v)
+		return string(raw)
+	}
+}
@@ -0,0 +1,230 @@
+package tables
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/atomicfile"
+	"go-backend/database"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// schemaExt is the suffix of the file declaring the rules of a table
+const schemaExt = ".schema.json"
+
+// Declared is the content of <name>.schema.json, e.g.
+//
+//	{"key": "name", "columns": [{"name": "price", "type": "number", "required": true, "minimum": 0}]}
+//
+// Declared columns replace the inferred rules of the same name and may
+// name columns the table has no values for yet.
+type Declared struct {
+	Key     string `json:"key,omitempty"`
+	Columns []Rule `json:"columns"`
+}
+
+// Rule constrains the values of a column. Type is one of the column types,
+// empty, mixed or json allow any value. Required rejects null and missing values.
+type Rule struct {
+	Name     string        `json:"name" example:"price"`
+	Type     string        `json:"type,omitempty" example:"number"`
+	Required bool          `json:"required,omitempty"`
+	Minimum  *float64      `json:"minimum,omitempty"`
+	Maximum  *float64      `json:"maximum,omitempty"`
+	Enum     []interface{} `json:"enum,omitempty"`
+}
+
+// ValidationError lists every way a row violates the rules of its table.
+// Issue paths are JSON pointers to the column.
+type ValidationError struct {
+	Table  string
+	Issues []database.ValidationIssue
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("row does not match the columns of table %q (%d issues)", e.Table, len(e.Issues))
+}
+
+// loadDeclared reads a schema file, nil if there is none
+func loadDeclared(path string) (*Declared, error) {
+	raw, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var d Declared
+	if err := json.Unmarshal(raw, &d); err != nil {
+		return nil, fmt.Errorf("%w: %s: %v", ErrInvalidTable, path, err)
+	}
+	for _, r := range d.Columns {
+		switch r.Type {
+		case "", TypeBoolean, TypeInteger, TypeNumber, TypeDate, TypeString, TypeMixed, TypeJSON:
+		default:
+			return nil, fmt.Errorf("%w: %s: column %q has unknown type %q", ErrInvalidTable, path, r.Name, r.Type)
+		}
+	}
+	return &d, nil
+}
+
+// saveDeclared writes a schema file
+func saveDeclared(path string, d *Declared) error {
+	raw, err := json.MarshalIndent(d, "", "  ")
+	if err != nil {
+		return err
+	}
+	return atomicfile.WriteFile(path, append(raw, '\n'), 0644)
+}
+
+// Rules returns the rule of every column in column order, then the
+// declared columns the table has no values for. Inferred rules require
+// the type of the existing values, with integer widened to number, and
+// a value if no row lacks one.
+func (t *Table) Rules() []Rule {
+	declared := map[string]Rule{}
+	if t.Declared != nil {
+		for _, r := range t.Declared.Columns {
+			declared[r.Name] = r
+		}
+	}
+
+	var rules []Rule
+	seen := map[string]bool{}
+	for _, col := range Infer(t).Columns {
+		seen[col.Name] = true
+		if r, ok := declared[col.Name]; ok {
+			rules = append(rules, r)
+			continue
+		}
+
+		r := Rule{Name: col.Name, Type: col.Type, Required: !col.Nullable}
+		switch col.Type {
+		case TypeInteger:
+			r.Type = TypeNumber
+		case TypeNull:
+			r.Type = ""
+		}
+		rules = append(rules, r)
+	}
+	if t.Declared != nil {
+		for _, r := range t.Declared.Columns {
+			if !seen[r.Name] {
+				rules = append(rules, r)
+			}
+		}
+	}
+	return rules
+}
+
+// hasRule reports whether a column has values or is declared
+func (t *Table) hasRule(column string) bool {
+	for _, r := range t.Rules() {
+		if r.Name == column {
+			return true
+		}
+	}
+	return false
+}
+
+// validate checks a row against the rules. Columns without a rule are
+// rejected unless the table has no columns at all yet. The key column,
+// if any, needs a string or integer.
+func (t *Table) validate(row Row, rules []Rule, keyCol string) error {
+	var issues []database.ValidationIssue
+	issue := func(column, keyword, format string, args ...interface{}) {
+		issues = append(issues, database.ValidationIssue{
+			Path:    "/" + strings.NewReplacer("~", "~0", "/", "~1").Replace(column),
+			Keyword: keyword,
+			Message: fmt.Sprintf(format, args...),
+		})
+	}
+
+	known := map[string]bool{}
+	for _, r := range rules {
+		known[r.Name] = true
+
+		v, ok := row[r.Name]
+		if !ok || v == nil {
+			if r.Required || r.Name == keyCol {
+				issue(r.Name, "required", "column %s needs a value", r.Name)
+			}
+			continue
+		}
+		if r.Name == keyCol && valueType(v) != TypeString && valueType(v) != TypeDate && valueType(v) != TypeInteger {
+			issue(r.Name, "key", "key column %s needs a string or integer", r.Name)
+			continue
+		}
+
+		if !typeAllows(r.Type, v) {
+			issue(r.Name, "type", "must be of type %s", r.Type)
+			continue
+		}
+		if n, ok := v.(float64); ok {
+			if r.Minimum != nil && n < *r.Minimum {
+				issue(r.Name, "minimum", "must be at least %s", strconv.FormatFloat(*r.Minimum, 'f', -1, 64))
+			}
+			if r.Maximum != nil && n > *r.Maximum {
+				issue(r.Name, "maximum", "must be at most %s", strconv.FormatFloat(*r.Maximum, 'f', -1, 64))
+			}
+		}
+		if len(r.Enum) > 0 && !inEnum(r.Enum, v) {
+			raw, _ := marshal(r.Enum)
+			issue(r.Name, "enum", "must be one of %s", raw)
+		}
+	}
+
+	if len(rules) > 0 {
+		var unknown []string
+		for col := range row {
+			if !known[col] {
+				unknown = append(unknown, col)
+			}
+		}
+		sort.Strings(unknown)
+		for _, col := range unknown {
+			issue(col, "column", "table has no column %s", col)
+		}
+	}
+
+	if len(issues) > 0 {
+		return &ValidationError{Table: t.Name, Issues: issues}
+	}
+	return nil
+}
+
+// typeAllows reports whether a non-null value fits a column type
+func typeAllows(typ string, v interface{}) bool {
+	switch typ {
+	case "", TypeMixed, TypeJSON:
+		return true
+	case TypeNumber:
+		_, ok := v.(float64)
+		return ok
+	case TypeInteger:
+		n, ok := v.(float64)
+		return ok && n == math.Trunc(n) && !math.IsInf(n, 0)
+	case TypeDate:
+		s, ok := v.(string)
+		return ok && isDate(s)
+	case TypeNull:
+		return false
+	default:
+		return valueType(v) == typ || (typ == TypeString && valueType(v) == TypeDate)
+	}
+}
+
+func inEnum(enum []interface{}, v interface{}) bool {
+	raw, _ := json.Marshal(v)
+	for _, e := range enum {
+		if want, _ := json.Marshal(e); string(want) == string(raw) {
+			return true
+		}
+	}
+	return false
+}
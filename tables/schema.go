@@ -0,0 +1,168 @@
+package tables
+
+import (
+	"encoding/json"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Column types
+const (
+	TypeNull    = "null"
+	TypeBoolean = "boolean"
+	TypeInteger = "integer"
+	TypeNumber  = "number"
+	TypeDate    = "date"
+	TypeString  = "string"
+	TypeMixed   = "mixed"
+	TypeJSON    = "json" // arrays and objects
+)
+
+// Column describes one column of a table. Nullable is true if a row lacks
+// the column or holds null, Distinct counts the different non-null values.
+type Column struct {
+	Name     string `json:"name" example:"price"`
+	Type     string `json:"type" example:"number" enums:"null,boolean,integer,number,date,string,mixed,json"`
+	Nullable bool   `json:"nullable"`
+	Distinct int    `json:"distinct" example:"12"`
+}
+
+// Schema describes the columns of a table. Key is the column rows are
+// addressed by, declared or else the first string or integer column
+// whose values are all present and distinct. The first edit of a table
+// declares the inferred key, so it no longer changes with the rows.
+type Schema struct {
+	Table   string   `json:"table" example:"tableData"`
+	Rows    int      `json:"rows" example:"54"`
+	Key     string   `json:"key,omitempty" example:"name"`
+	Columns []Column `json:"columns"`
+}
+
+// Infer derives the schema of a table from its rows. A column has the type
+// shared by all its non-null values, integer widening to number, or mixed.
+// Strings that are all RFC 3339 times or YYYY-MM-DD dates are of type date.
+func Infer(t *Table) Schema {
+	s := Schema{Table: t.Name, Rows: len(t.Rows), Columns: []Column{}}
+
+	for _, name := range t.Columns {
+		col := Column{Name: name, Type: TypeNull}
+		distinct := map[string]bool{}
+		for _, row := range t.Rows {
+			v, ok := row[name]
+			if !ok || v == nil {
+				col.Nullable = true
+				continue
+			}
+			raw, _ := json.Marshal(v)
+			distinct[string(raw)] = true
+			col.Type = widen(col.Type, valueType(v))
+		}
+		col.Distinct = len(distinct)
+		s.Columns = append(s.Columns, col)
+
+		keyType := col.Type == TypeString || col.Type == TypeInteger
+		if s.Key == "" && keyType && !col.Nullable && col.Distinct == len(t.Rows) {
+			s.Key = name
+		}
+	}
+	if t.Declared != nil && t.Declared.Key != "" {
+		s.Key = t.Declared.Key
+	}
+	return s
+}
+
+// valueType returns the column type of a single JSON value
+func valueType(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return TypeNull
+	case bool:
+		return TypeBoolean
+	case float64:
+		if v == math.Trunc(v) && !math.IsInf(v, 0) {
+			return TypeInteger
+		}
+		return TypeNumber
+	case string:
+		if isDate(v) {
+			return TypeDate
+		}
+		return TypeString
+	default:
+		return TypeJSON
+	}
+}
+
+// widen combines the type of a column so far with the type of another value
+func widen(have, next string) string {
+	switch {
+	case have == TypeNull || have == next:
+		return next
+	case next == TypeNull:
+		return have
+	case have == TypeInteger && next == TypeNumber, have == TypeNumber && next == TypeInteger:
+		return TypeNumber
+	case have == TypeDate && next == TypeString, have == TypeString && next == TypeDate:
+		return TypeString
+	default:
+		return TypeMixed
+	}
+}
+
+func isDate(s string) bool {
+	if _, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return true
+	}
+	_, err := time.Parse("2006-01-02", s)
+	return err == nil
+}
+
+// cellType returns the type CSV cells are read as: number or boolean if
+// every non-empty cell parses as one, string otherwise. Numbers with
+// leading zeros, e.g. zip codes like 01234, are strings.
+func cellType(cells []string) string {
+	typ := TypeNull
+	for _, c := range cells {
+		c = strings.TrimSpace(c)
+		if c == "" {
+			continue
+		}
+		next := TypeString
+		if n, err := strconv.ParseFloat(c, 64); err == nil && !math.IsNaN(n) && !math.IsInf(n, 0) && !leadingZero(c) {
+			next = TypeNumber
+		} else if strings.EqualFold(c, "true") || strings.EqualFold(c, "false") {
+			next = TypeBoolean
+		}
+		if typ != TypeNull && typ != next {
+			return TypeString
+		}
+		typ = next
+	}
+	return typ
+}
+
+// leadingZero reports whether a number is written with a zero before
+// another digit, e.g. 007 or -01.5
+func leadingZero(s string) bool {
+	s = strings.TrimLeft(s, "+-")
+	return len(s) > 1 && s[0] == '0' && s[1] >= '0' && s[1] <= '9'
+}
+
+// parseCell converts a CSV cell to a value of the column type
+func parseCell(cell, typ string) interface{} {
+	trimmed := strings.TrimSpace(cell)
+	if trimmed == "" {
+		return nil
+	}
+	switch typ {
+	case TypeNumber:
+		n, _ := strconv.ParseFloat(trimmed, 64)
+		return n
+	case TypeBoolean:
+		return strings.EqualFold(trimmed, "true")
+	default:
+		return cell
+	}
+}
@@ -0,0 +1,198 @@
+package tables
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+// missing leaves the column out of a row
+var missing = &struct{}{}
+
+// column builds a table with one column holding values, one per row
+func column(values ...interface{}) *Table {
+	t := &Table{Name: "t", Columns: []string{"c"}}
+	for _, v := range values {
+		row := Row{}
+		if v != missing {
+			row["c"] = v
+		}
+		t.Rows = append(t.Rows, row)
+	}
+	return t
+}
+
+func TestInferColumn(t *testing.T) {
+	tests := []struct {
+		name   string
+		values []interface{}
+		want   Column
+	}{
+		{"integers", []interface{}{1.0, 2.0}, Column{Type: TypeInteger, Distinct: 2}},
+		{"integers and numbers", []interface{}{1.0, 2.5}, Column{Type: TypeNumber, Distinct: 2}},
+		{"strings", []interface{}{"a", "b", "a"}, Column{Type: TypeString, Distinct: 2}},
+		{"dates", []interface{}{"2024-01-01", "2024-01-02T10:00:00Z"}, Column{Type: TypeDate, Distinct: 2}},
+		{"dates and strings", []interface{}{"2024-01-01", "soon"}, Column{Type: TypeString, Distinct: 2}},
+		{"booleans", []interface{}{true, false}, Column{Type: TypeBoolean, Distinct: 2}},
+		{"numbers and strings", []interface{}{1.0, "a"}, Column{Type: TypeMixed, Distinct: 2}},
+		{"arrays and objects", []interface{}{[]interface{}{1.0}, map[string]interface{}{"a": 1.0}}, Column{Type: TypeJSON, Distinct: 2}},
+		{"nulls", []interface{}{nil, nil}, Column{Type: TypeNull, Nullable: true}},
+		{"null", []interface{}{1.0, nil}, Column{Type: TypeInteger, Nullable: true, Distinct: 1}},
+		{"missing", []interface{}{"a", missing}, Column{Type: TypeString, Nullable: true, Distinct: 1}},
+	}
+	for _, tt := range tests {
+		tt.want.Name = "c"
+		s := Infer(column(tt.values...))
+		if len(s.Columns) != 1 || s.Columns[0] != tt.want {
+			t.Errorf("%s: columns = %+v, want %+v", tt.name, s.Columns, tt.want)
+		}
+	}
+}
+
+func TestInferKey(t *testing.T) {
+	tests := []struct {
+		name     string
+		columns  []string
+		rows     []Row
+		declared *Declared
+		want     string
+	}{
+		{
+			"first distinct string", []string{"price", "name", "code"},
+			[]Row{{"price": 1.5, "name": "a", "code": "x"}, {"price": 2.5, "name": "b", "code": "y"}},
+			nil, "name",
+		},
+		{
+			"integer", []string{"id", "name"},
+			[]Row{{"id": 1.0, "name": "a"}, {"id": 2.0, "name": "b"}},
+			nil, "id",
+		},
+		{
+			"duplicates", []string{"category", "name"},
+			[]Row{{"category": "fruit", "name": "apple"}, {"category": "fruit", "name": "pear"}},
+			nil, "name",
+		},
+		{
+			"nullable", []string{"code", "name"},
+			[]Row{{"code": "x", "name": "a"}, {"name": "b"}},
+			nil, "name",
+		},
+		{
+			"dates", []string{"day", "name"},
+			[]Row{{"day": "2024-01-01", "name": "a"}, {"day": "2024-01-02", "name": "b"}},
+			nil, "name",
+		},
+		{
+			"none", []string{"category", "price"},
+			[]Row{{"category": "fruit", "price": 1.0}, {"category": "fruit", "price": 1.0}},
+			nil, "",
+		},
+		{
+			"declared", []string{"name", "category"},
+			[]Row{{"name": "apple", "category": "fruit"}},
+			&Declared{Key: "category"}, "category",
+		},
+	}
+	for _, tt := range tests {
+		table := &Table{Name: "t", Columns: tt.columns, Rows: tt.rows, Declared: tt.declared}
+		if got := Infer(table).Key; got != tt.want {
+			t.Errorf("%s: key = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestCellType(t *testing.T) {
+	tests := []struct {
+		cells []string
+		want  string
+	}{
+		{[]string{"1", "2.5", "-3", "1e3"}, TypeNumber},
+		{[]string{"0", "-0.5", " 7 "}, TypeNumber},
+		{[]string{"1", ""}, TypeNumber},
+		{[]string{"01234", "1"}, TypeString},
+		{[]string{"007"}, TypeString},
+		{[]string{"-01.5"}, TypeString},
+		{[]string{"true", "FALSE"}, TypeBoolean},
+		{[]string{"1", "true"}, TypeString},
+		{[]string{"NaN"}, TypeString},
+		{[]string{"Inf"}, TypeString},
+		{[]string{"", " "}, TypeNull},
+	}
+	for _, tt := range tests {
+		if got := cellType(tt.cells); got != tt.want {
+			t.Errorf("cellType(%q) = %s, want %s", tt.cells, got, tt.want)
+		}
+	}
+}
+
+const partsTable = `[
+  { "name": "bolt", "price": 1.5 },
+  { "name": "nut", "price": 0.5 }
+]
+`
+
+func TestDeclaredRules(t *testing.T) {
+	dir := writeTable(t, "parts.json", partsTable)
+	schema := `{"key": "code", "columns": [
+		{"name": "price", "type": "integer", "minimum": 0},
+		{"name": "code", "type": "string", "required": true},
+		{"name": "finish", "enum": ["zinc", "black"]}
+	]}`
+	if err := os.WriteFile(filepath.Join(dir, "parts.schema.json"), []byte(schema), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	table, err := Open(dir, "parts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if key := Infer(table).Key; key != "code" {
+		t.Errorf("key = %q, want the declared code", key)
+	}
+	var names []string
+	for _, r := range table.Rules() {
+		names = append(names, r.Name+":"+r.Type)
+	}
+	if want := []string{"name:string", "price:integer", "code:string", "finish:"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("rules = %v, want %v", names, want)
+	}
+
+	_, err = Insert(dir, "parts", Row{"name": "washer", "price": -1.5, "finish": "red"})
+	var verr *ValidationError
+	if !errors.As(err, &verr) {
+		t.Fatalf("inserting an invalid row: err = %v, want a ValidationError", err)
+	}
+	got := map[string]string{}
+	for _, issue := range verr.Issues {
+		got[issue.Path] = issue.Keyword
+	}
+	if want := map[string]string{"/price": "type", "/code": "required", "/finish": "enum"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %+v, want %v", verr.Issues, want)
+	}
+
+	if _, err := Insert(dir, "parts", Row{"name": "washer", "price": 2.0, "code": "w1", "finish": "zinc"}); err != nil {
+		t.Errorf("inserting a valid row: %v", err)
+	}
+	if _, err := Get(dir, "parts", "w1"); err != nil {
+		t.Errorf("getting the row by its declared key: %v", err)
+	}
+}
+
+func TestInvalidDeclaredRules(t *testing.T) {
+	tests := []string{
+		`{"columns": [`,
+		`{"columns": [{"name": "price", "type": "money"}]}`,
+		`{"key": "code", "columns": []}`,
+	}
+	for _, schema := range tests {
+		dir := writeTable(t, "parts.json", partsTable)
+		if err := os.WriteFile(filepath.Join(dir, "parts.schema.json"), []byte(schema), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := Open(dir, "parts"); !errors.Is(err, ErrInvalidTable) {
+			t.Errorf("opening with schema %s: err = %v, want ErrInvalidTable", schema, err)
+		}
+	}
+}
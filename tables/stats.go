@@ -0,0 +1,255 @@
+package tables
+
+import (
+	"fmt"
+	"go-backend/database"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultPercentiles are reported when a stats request names none
+var DefaultPercentiles = []float64{25, 50, 75, 90, 95, 99}
+
+// ColumnStats summarizes the numbers of a column. The statistics are null
+// if the column has no numbers among the selected rows. Percentiles are
+// keyed p<percentile>, e.g. p90, and interpolate linearly between values.
+type ColumnStats struct {
+	Column      string             `json:"column" example:"price"`
+	Count       int                `json:"count" example:"54"`
+	Nulls       int                `json:"nulls"`
+	Sum         *float64           `json:"sum"`
+	Min         *float64           `json:"min"`
+	Max         *float64           `json:"max"`
+	Mean        *float64           `json:"mean"`
+	Median      *float64           `json:"median"`
+	StdDev      *float64           `json:"stddev"`
+	Percentiles map[string]float64 `json:"percentiles"`
+}
+
+// Pivot is a value aggregated per combination of row fields and, with a
+// pivot column, per value of that column. Columns holds the values of the
+// pivot column as text, in order, and keys the values and totals. Pivot
+// columns whose values differ only in type, like 1 and "1", are rejected.
+type Pivot struct {
+	Rows    []string               `json:"rows" example:"category"`
+	Column  string                 `json:"column,omitempty"`
+	Value   string                 `json:"value" example:"avg(price)"`
+	Columns []string               `json:"columns,omitempty"`
+	Data    []PivotRow             `json:"data"`
+	Totals  map[string]interface{} `json:"totals,omitempty"`
+	Total   interface{}            `json:"total"`
+}
+
+// PivotRow is one combination of row field values with the aggregated
+// value per pivot column and over all of them
+type PivotRow struct {
+	Key    map[string]interface{} `json:"key"`
+	Values map[string]interface{} `json:"values,omitempty"`
+	Total  interface{}            `json:"total"`
+}
+
+// ParsePercentiles reads comma separated percentiles between 0 and 100,
+// DefaultPercentiles if s is empty
+func ParsePercentiles(s string) ([]float64, error) {
+	if s == "" {
+		return DefaultPercentiles, nil
+	}
+
+	var ps []float64
+	for _, f := range strings.Split(s, ",") {
+		p, err := strconv.ParseFloat(strings.TrimSpace(f), 64)
+		if err != nil || p < 0 || p > 100 {
+			return nil, fmt.Errorf("%w: percentiles must be numbers from 0 to 100", database.ErrInvalidQuery)
+		}
+		ps = append(ps, p)
+	}
+	return ps, nil
+}
+
+// Stats summarizes the given columns of the rows, every number or integer
+// column of the table if columns is empty
+func Stats(t *Table, rows []Row, columns []string, percentiles []float64) ([]ColumnStats, error) {
+	types := map[string]string{}
+	var numeric []string
+	for _, col := range Infer(t).Columns {
+		types[col.Name] = col.Type
+		if col.Type == TypeNumber || col.Type == TypeInteger {
+			numeric = append(numeric, col.Name)
+		}
+	}
+	if len(columns) == 0 {
+		columns = numeric
+	}
+
+	stats := make([]ColumnStats, 0, len(columns))
+	for _, col := range columns {
+		typ, ok := types[col]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown column %q", database.ErrInvalidQuery, col)
+		}
+		if typ != TypeNumber && typ != TypeInteger && typ != TypeNull {
+			return nil, fmt.Errorf("%w: column %q is of type %s, not a number", database.ErrInvalidQuery, col, typ)
+		}
+
+		s := ColumnStats{Column: col}
+		var values []float64
+		for _, row := range rows {
+			if n, ok := row[col].(float64); ok {
+				values = append(values, n)
+			} else {
+				s.Nulls++
+			}
+		}
+		s.Count = len(values)
+		if len(values) > 0 {
+			summarize(&s, values, percentiles)
+		}
+		stats = append(stats, s)
+	}
+	return stats, nil
+}
+
+// summarize fills in the statistics of a non-empty list of values
+func summarize(s *ColumnStats, values []float64, percentiles []float64) {
+	sort.Float64s(values)
+
+	sum := 0.0
+	for _, v := range values {
+		sum += v
+	}
+	mean := sum / float64(len(values))
+	variance := 0.0
+	for _, v := range values {
+		variance += (v - mean) * (v - mean)
+	}
+	stddev := math.Sqrt(variance / float64(len(values)))
+	median := percentile(values, 50)
+
+	s.Sum, s.Mean, s.StdDev, s.Median = &sum, &mean, &stddev, &median
+	s.Min, s.Max = &values[0], &values[len(values)-1]
+	s.Percentiles = make(map[string]float64, len(percentiles))
+	for _, p := range percentiles {
+		s.Percentiles["p"+strconv.FormatFloat(p, 'f', -1, 64)] = percentile(values, p)
+	}
+}
+
+// percentile interpolates the p-th percentile of sorted values
+func percentile(sorted []float64, p float64) float64 {
+	pos := p / 100 * float64(len(sorted)-1)
+	lo := int(math.Floor(pos))
+	hi := int(math.Ceil(pos))
+	return sorted[lo] + (sorted[hi]-sorted[lo])*(pos-float64(lo))
+}
+
+// PivotTable aggregates value, count or fn(column) with fn one of sum avg
+// min max, per combination of the row columns and, unless column is empty,
+// per value of column. Rows and pivot columns are ordered by value.
+func PivotTable(t *Table, rows []Row, rowCols []string, column, value string) (Pivot, error) {
+	metric, err := parseMetric(value)
+	if err != nil {
+		return Pivot{}, err
+	}
+	known := map[string]bool{}
+	for _, c := range t.Columns {
+		known[c] = true
+	}
+	for _, c := range append(append([]string{}, rowCols...), column, metric.Field) {
+		if c != "" && !known[c] {
+			return Pivot{}, fmt.Errorf("%w: unknown column %q", database.ErrInvalidQuery, c)
+		}
+	}
+	if len(rowCols) == 0 {
+		return Pivot{}, fmt.Errorf("%w: rows needs at least one column", database.ErrInvalidQuery)
+	}
+
+	// Rows are grouped as items holding the row as data, like Select
+	items := make([]database.Item, len(rows))
+	for i, row := range rows {
+		items[i] = database.Item{ID: fmt.Sprintf("%010d", i), Data: row}
+	}
+	var metrics []database.Metric
+	if metric.Func != database.MetricCount {
+		metric.Field = "data." + metric.Field
+		metrics = append(metrics, metric)
+	}
+	aggregate := func(cols ...string) []database.Group {
+		a := database.Aggregation{Metrics: metrics}
+		for _, c := range cols {
+			a.GroupBy = append(a.GroupBy, database.GroupField{Field: "data." + c})
+		}
+		return a.Evaluate(items).Groups
+	}
+	result := func(g database.Group) interface{} {
+		if len(metrics) == 0 {
+			return g.Count
+		}
+		return g.Metrics[metrics[0].Name()]
+	}
+	keyOf := func(g database.Group) map[string]interface{} {
+		key := map[string]interface{}{}
+		for _, c := range rowCols {
+			key[c] = g.Key["data."+c]
+		}
+		return key
+	}
+
+	p := Pivot{Rows: rowCols, Column: column, Value: value, Data: []PivotRow{}}
+	if total := aggregate(); len(total) > 0 {
+		p.Total = result(total[0])
+	} else if len(metrics) == 0 {
+		p.Total = 0
+	}
+
+	index := map[string]int{}
+	for _, g := range aggregate(rowCols...) {
+		row := PivotRow{Key: keyOf(g), Total: result(g)}
+		if column != "" {
+			row.Values = map[string]interface{}{}
+		}
+		raw, _ := marshal(row.Key)
+		index[string(raw)] = len(p.Data)
+		p.Data = append(p.Data, row)
+	}
+
+	if column != "" {
+		p.Columns = []string{}
+		p.Totals = map[string]interface{}{}
+		for _, g := range aggregate(column) {
+			header := keyString(g.Key["data."+column])
+			if _, ok := p.Totals[header]; ok {
+				return Pivot{}, fmt.Errorf("%w: column %q holds values of different types written as %q", database.ErrInvalidQuery, column, header)
+			}
+			p.Columns = append(p.Columns, header)
+			p.Totals[header] = result(g)
+		}
+		for _, g := range aggregate(append(append([]string{}, rowCols...), column)...) {
+			raw, _ := marshal(keyOf(g))
+			p.Data[index[string(raw)]].Values[keyString(g.Key["data."+column])] = result(g)
+		}
+	}
+	return p, nil
+}
+
+// parseMetric reads count or fn(column) with fn one of sum avg min max
+func parseMetric(spec string) (database.Metric, error) {
+	if spec == "" || spec == database.MetricCount {
+		return database.Metric{Func: database.MetricCount}, nil
+	}
+
+	open := strings.Index(spec, "(")
+	if open < 0 || !strings.HasSuffix(spec, ")") {
+		return database.Metric{}, fmt.Errorf("%w: value %q must be count or fn(column)", database.ErrInvalidQuery, spec)
+	}
+	m := database.Metric{Func: spec[:open], Field: spec[open+1 : len(spec)-1]}
+	if m.Field == "" {
+		return m, fmt.Errorf("%w: value %q names no column", database.ErrInvalidQuery, spec)
+	}
+	switch m.Func {
+	case database.MetricSum, database.MetricAvg, database.MetricMin, database.MetricMax:
+	default:
+		return m, fmt.Errorf("%w: unknown function %q, use count, sum, avg, min or max", database.ErrInvalidQuery, m.Func)
+	}
+	return m, nil
+}
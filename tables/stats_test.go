@@ -0,0 +1,42 @@
+package tables
+
+import (
+	"errors"
+	"go-backend/database"
+	"testing"
+)
+
+func TestStatsWithoutNumbers(t *testing.T) {
+	table := &Table{Columns: []string{"name", "price"}, Rows: []Row{
+		{"name": "a", "price": nil},
+		{"name": "b", "price": nil},
+	}}
+
+	stats, err := Stats(table, table.Rows, []string{"price"}, DefaultPercentiles)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s := stats[0]; s.Count != 0 || s.Nulls != 2 || s.Mean != nil || s.Percentiles != nil {
+		t.Errorf("stats of a column without numbers = %+v, want only nulls counted", s)
+	}
+}
+
+func TestPivotRejectsAmbiguousColumns(t *testing.T) {
+	table := &Table{Columns: []string{"category", "size"}, Rows: []Row{
+		{"category": "a", "size": 1.0},
+		{"category": "b", "size": "1"},
+	}}
+
+	_, err := PivotTable(table, table.Rows, []string{"category"}, "size", "count")
+	if !errors.Is(err, database.ErrInvalidQuery) {
+		t.Errorf("pivoting 1 and \"1\": err = %v, want ErrInvalidQuery", err)
+	}
+
+	p, err := PivotTable(table, table.Rows, []string{"size"}, "category", "count")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(p.Data) != 2 || len(p.Columns) != 2 {
+		t.Errorf("pivot by size has %d rows and columns %v, want 2 rows and 2 columns", len(p.Data), p.Columns)
+	}
+}
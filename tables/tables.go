@@ -0,0 +1,287 @@
+// Package tables serves tabular datasets read from JSON and CSV files:
+// rows of flat objects that are filtered, sorted and paged with the same
+// operators as stored items, and whose column schema can be inferred.
+package tables
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"go-backend/database"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 1000
+)
+
+// Row is one row of a table, keyed by column name
+type Row = map[string]interface{}
+
+// Query selects, orders and pages rows. Filter and sort fields are column names.
+type Query struct {
+	Filters  []database.Filter
+	Sort     []database.SortKey
+	Page     int
+	PageSize int
+}
+
+// Page is one page of rows. Total counts the rows of the table, Filtered
+// those matching the filters.
+type Page struct {
+	Rows     []Row `json:"rows"`
+	Page     int   `json:"page" example:"1"`
+	PageSize int   `json:"pageSize" example:"50"`
+	Pages    int   `json:"pages" example:"2"`
+	Total    int   `json:"total" example:"54"`
+	Filtered int   `json:"filtered" example:"27"`
+}
+
+// reservedParams are query string keys that are not column filters
+var reservedParams = map[string]bool{"sort": true, "page": true, "pageSize": true}
+
+var operators = map[string]bool{
+	database.OpEq: true, database.OpNe: true, database.OpGt: true, database.OpGte: true,
+	database.OpLt: true, database.OpLte: true, database.OpContains: true,
+	database.OpExists: true, database.OpIn: true,
+}
+
+// Load reads a table from a JSON file holding an array of objects and
+// returns its rows and its columns in the order they first appear
+func Load(path string) ([]Row, []string, error) {
+	raw, err := os.ReadFile(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var objects []json.RawMessage
+	if err := json.Unmarshal(raw, &objects); err != nil {
+		return nil, nil, fmt.Errorf("%w: %s: %v", ErrInvalidTable, path, err)
+	}
+
+	rows := make([]Row, 0, len(objects))
+	var columns []string
+	seen := map[string]bool{}
+	for i, obj := range objects {
+		var row Row
+		keys, err := objectKeys(obj)
+		if err == nil {
+			err = json.Unmarshal(obj, &row)
+		}
+		if err != nil || row == nil {
+			return nil, nil, fmt.Errorf("%w: %s: row %d is not an object", ErrInvalidTable, path, i+1)
+		}
+		for _, k := range keys {
+			if !seen[k] {
+				seen[k] = true
+				columns = append(columns, k)
+			}
+		}
+		rows = append(rows, row)
+	}
+	return rows, columns, nil
+}
+
+// objectKeys returns the keys of a JSON object in document order
+func objectKeys(raw json.RawMessage) ([]string, error) {
+	dec := json.NewDecoder(bytes.NewReader(raw))
+	if t, err := dec.Token(); err != nil || t != json.Delim('{') {
+		return nil, fmt.Errorf("not an object")
+	}
+
+	var keys []string
+	for dec.More() {
+		t, err := dec.Token()
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, t.(string))
+		var skip json.RawMessage
+		if err := dec.Decode(&skip); err != nil {
+			return nil, err
+		}
+	}
+	return keys, nil
+}
+
+// ParseQuery builds a Query from URL parameters:
+//
+//	category=Fruit           equality
+//	price[gte]=1&price[lt]=2 operator, one of eq ne gt gte lt lte contains exists in
+//	name[contains]=apple     case-insensitive substring
+//	sort=-price,name         comma separated columns, "-" for descending
+//	page=2&pageSize=20       1-based page number and page size
+//
+// Keys listed in ignore are skipped, so callers can mix in their own parameters.
+func ParseQuery(values url.Values, ignore ...string) (Query, error) {
+	q := Query{Page: 1, PageSize: DefaultPageSize}
+
+	skip := map[string]bool{}
+	for _, k := range ignore {
+		skip[k] = true
+	}
+
+	if s := values.Get("sort"); s != "" {
+		for _, f := range strings.Split(s, ",") {
+			key := database.SortKey{Field: strings.TrimSpace(f)}
+			if strings.HasPrefix(key.Field, "-") {
+				key.Field, key.Desc = key.Field[1:], true
+			}
+			if key.Field == "" {
+				return q, fmt.Errorf("%w: empty sort column", database.ErrInvalidQuery)
+			}
+			q.Sort = append(q.Sort, key)
+		}
+	}
+
+	if s := values.Get("page"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("%w: page must be a positive integer", database.ErrInvalidQuery)
+		}
+		q.Page = n
+	}
+	if s := values.Get("pageSize"); s != "" {
+		n, err := strconv.Atoi(s)
+		if err != nil || n < 1 {
+			return q, fmt.Errorf("%w: pageSize must be a positive integer", database.ErrInvalidQuery)
+		}
+		if n > MaxPageSize {
+			n = MaxPageSize
+		}
+		q.PageSize = n
+	}
+
+	keys := make([]string, 0, len(values))
+	for k := range values {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		if reservedParams[key] || skip[key] {
+			continue
+		}
+
+		column, op := key, database.OpEq
+		if i := strings.Index(key, "["); i >= 0 && strings.HasSuffix(key, "]") {
+			column, op = key[:i], key[i+1:len(key)-1]
+		}
+		if !operators[op] {
+			return q, fmt.Errorf("%w: unknown operator %q", database.ErrInvalidQuery, op)
+		}
+
+		for _, v := range values[key] {
+			q.Filters = append(q.Filters, database.Filter{Field: column, Op: op, Value: v})
+		}
+	}
+
+	return q, nil
+}
+
+// UnknownParams returns the keys of values that are neither sort, page and
+// pageSize nor filters on one of columns, in order
+func UnknownParams(values url.Values, columns []string) []string {
+	known := map[string]bool{}
+	for _, c := range columns {
+		known[c] = true
+	}
+
+	var unknown []string
+	for key := range values {
+		column := key
+		if i := strings.Index(key, "["); i >= 0 {
+			column = key[:i]
+		}
+		if !reservedParams[key] && !known[column] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+	return unknown
+}
+
+// Validate fails with database.ErrInvalidQuery if the query names a column
+// that is not one of columns
+func (q Query) Validate(columns []string) error {
+	known := map[string]bool{}
+	for _, c := range columns {
+		known[c] = true
+	}
+
+	for _, f := range q.Filters {
+		if !known[f.Field] {
+			return fmt.Errorf("%w: unknown column %q", database.ErrInvalidQuery, f.Field)
+		}
+	}
+	for _, k := range q.Sort {
+		if !known[k.Field] {
+			return fmt.Errorf("%w: unknown column %q", database.ErrInvalidQuery, k.Field)
+		}
+	}
+	return nil
+}
+
+// Select returns the rows matching the filters in sort order. Rows that
+// compare equal keep their order in the table.
+func (q Query) Select(rows []Row) []Row {
+	// Rows are matched and ordered as items holding the row as data, with
+	// the row number as ID to keep the sort stable
+	dq := database.Query{Sort: make([]database.SortKey, len(q.Sort))}
+	for i, k := range q.Sort {
+		dq.Sort[i] = database.SortKey{Field: "data." + k.Field, Desc: k.Desc}
+	}
+	for _, f := range q.Filters {
+		f.Field = "data." + f.Field
+		dq.Filters = append(dq.Filters, f)
+	}
+
+	items := make([]database.Item, 0, len(rows))
+	for i, row := range rows {
+		item := database.Item{ID: fmt.Sprintf("%010d", i), Data: row}
+		if dq.Match(item) {
+			items = append(items, item)
+		}
+	}
+	dq.SortItems(items)
+
+	selected := make([]Row, len(items))
+	for i, item := range items {
+		selected[i] = item.Data
+	}
+	return selected
+}
+
+// Apply filters, sorts and pages the rows
+func (q Query) Apply(rows []Row) Page {
+	selected := q.Select(rows)
+
+	size := q.PageSize
+	if size <= 0 {
+		size = DefaultPageSize
+	}
+	page := Page{
+		Rows:     []Row{},
+		Page:     q.Page,
+		PageSize: size,
+		Pages:    (len(selected) + size - 1) / size,
+		Total:    len(rows),
+		Filtered: len(selected),
+	}
+
+	start := (q.Page - 1) * size
+	if start < 0 || start >= len(selected) {
+		return page
+	}
+	end := start + size
+	if end > len(selected) {
+		end = len(selected)
+	}
+	page.Rows = selected[start:end]
+	return page
+}
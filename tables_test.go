@@ -0,0 +1,103 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveTables points the table handlers at a directory holding the given
+// files and returns a router with the table routes
+func serveTables(t *testing.T, files map[string]string) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+
+	dir := t.TempDir()
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	saved := tablesDir
+	tablesDir = dir
+	t.Cleanup(func() { tablesDir = saved })
+
+	r := gin.New()
+	r.GET("/tables/:name", getTableHandler)
+	r.GET("/tables/:name/export", exportTableHandler)
+	r.GET("/tables/:name/stats", tableStatsHandler)
+	r.GET("/tables/:name/pivot", tablePivotHandler)
+	return r
+}
+
+func get(r *gin.Engine, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+	return w
+}
+
+const fruit = `[
+  { "name": "Apple", "category": "Fruit", "price": 1.5 },
+  { "name": "Carrot", "category": "Vegetable", "price": 0.5 },
+  { "name": "Pear", "category": "Fruit", "price": 2 }
+]
+`
+
+func TestExportTable(t *testing.T) {
+	r := serveTables(t, map[string]string{"fruit.json": fruit})
+
+	tests := []struct {
+		target string
+		status int
+		body   string
+	}{
+		{"/tables/fruit/export", http.StatusOK, "name,category,price\nApple,Fruit,1.5\nCarrot,Vegetable,0.5\nPear,Fruit,2\n"},
+		{"/tables/fruit/export?category=Fruit&sort=-price", http.StatusOK, "name,category,price\nPear,Fruit,2\nApple,Fruit,1.5\n"},
+		{"/tables/fruit/export?format=csv&_=1700000000&price[lt]=1", http.StatusOK, "name,category,price\nCarrot,Vegetable,0.5\n"},
+		{"/tables/fruit/export?price[like]=1", http.StatusBadRequest, ""},
+		{"/tables/fruit/export?sort=weight", http.StatusBadRequest, ""},
+		{"/tables/fruit/export?format=doc", http.StatusBadRequest, ""},
+	}
+	for _, tt := range tests {
+		w := get(r, tt.target)
+		if w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.target, w.Code, tt.status, w.Body)
+			continue
+		}
+		if tt.body != "" && w.Body.String() != tt.body {
+			t.Errorf("%s:\n%s\nwant:\n%s", tt.target, w.Body, tt.body)
+		}
+	}
+
+	w := get(r, "/tables/fruit/export?format=xlsx")
+	if w.Code != http.StatusOK || w.Header().Get("Content-Disposition") != `attachment; filename="fruit.xlsx"` {
+		t.Errorf("xlsx export: status %d, headers %v", w.Code, w.Header())
+	}
+}
+
+func TestTableQueriesIgnoreUnknownParams(t *testing.T) {
+	r := serveTables(t, map[string]string{"fruit.json": fruit})
+
+	tests := []struct {
+		target string
+		status int
+	}{
+		{"/tables/fruit?_=1700000000&category=Fruit", http.StatusOK},
+		{"/tables/fruit?weight[gt]=1", http.StatusOK},
+		{"/tables/fruit?category[like]=F", http.StatusBadRequest},
+		{"/tables/fruit?sort=weight", http.StatusBadRequest},
+		{"/tables/fruit/stats?columns=price&_=1700000000", http.StatusOK},
+		{"/tables/fruit/stats?price[bad]=1", http.StatusBadRequest},
+		{"/tables/fruit/pivot?rows=category&value=sum(price)&_=1700000000", http.StatusOK},
+		{"/tables/fruit/pivot?rows=category&name[bad]=x", http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if w := get(r, tt.target); w.Code != tt.status {
+			t.Errorf("%s: status %d, want %d: %s", tt.target, w.Code, tt.status, w.Body)
+		}
+	}
+}
@@ -0,0 +1,522 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/database"
+	"go-backend/ids"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	formatNDJSON = "ndjson"
+	formatCSV    = "csv"
+)
+
+// importBatchSize is the number of rows written per transaction
+const importBatchSize = 500
+
+// maxImportErrors caps the row errors listed in an import result
+const maxImportErrors = 1000
+
+// importResult reports how many rows were imported and why the others failed
+type importResult struct {
+	Imported int              `json:"imported"`
+	Failed   int              `json:"failed"`
+	Errors   []importRowError `json:"errors"`
+}
+
+// importRowError is the reason one row was not imported, Row counts from 1
+// and includes the CSV header
+type importRowError struct {
+	Row    int                        `json:"row"`
+	ID     string                     `json:"id,omitempty"`
+	Error  string                     `json:"error"`
+	Issues []database.ValidationIssue `json:"issues,omitempty"`
+}
+
+// importRow is a parsed row waiting to be written
+type importRow struct {
+	row  int
+	item database.Item
+}
+
+// importItemsHandler godoc
+// @Summary Import items
+// @Description Streams items from NDJSON (one {"id","expires_at","data"} object or plain data object per line) or CSV (optional id and expires_at columns and data.<path> columns, cells holding JSON values are decoded).
+// @Description Rows without an id get a new one, rows with the id of an existing item replace it. Rows that fail are reported and do not stop the import.
+// @Tags items
+// @Accept plain
+// @Produce json
+// @Param collection query string false "Collection to import into"
+// @Param format query string false "ndjson or csv, defaults to the Content-Type (text/csv) or ndjson"
+// @Param rows body string true "NDJSON lines or CSV records"
+// @Success 200 {object} importResult
+// @Failure 400 {object} map[string]string "Unknown format or invalid CSV header"
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Failure 500 {object} map[string]string "Failed to write DB"
+// @Router /items/import [post]
+func importItemsHandler(c *gin.Context) {
+	format := c.Query("format")
+	if format == "" {
+		format = formatNDJSON
+		if strings.HasPrefix(c.ContentType(), "text/csv") {
+			format = formatCSV
+		}
+	}
+
+	result := importResult{Errors: []importRowError{}}
+	collection, author := itemCollection(c), requestAuthor(c)
+	batch := make([]importRow, 0, importBatchSize)
+
+	fail := func(row int, id string, err error) {
+		result.Failed++
+		if len(result.Errors) >= maxImportErrors {
+			return
+		}
+		rowErr := importRowError{Row: row, ID: id, Error: err.Error()}
+		var verr *database.ValidationError
+		if errors.As(err, &verr) {
+			rowErr.Error = "Validation failed"
+			rowErr.Issues = verr.Issues
+		}
+		result.Errors = append(result.Errors, rowErr)
+	}
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+		items := make([]database.Item, len(batch))
+		for i, r := range batch {
+			items[i] = r.item
+		}
+		errs, err := database.ImportItems(collection, items, author)
+		if err != nil {
+			return err
+		}
+		for i, err := range errs {
+			if err != nil {
+				fail(batch[i].row, batch[i].item.ID, err)
+			} else {
+				result.Imported++
+			}
+		}
+		batch = batch[:0]
+		return nil
+	}
+
+	add := func(row int, item database.Item) error {
+		if item.ID == "" {
+			item.ID = ids.NewItem()
+		} else if !ids.Valid(ids.Item, item.ID) {
+			fail(row, item.ID, errors.New("invalid item ID"))
+			return nil
+		}
+		batch = append(batch, importRow{row: row, item: item})
+		if len(batch) < importBatchSize {
+			return nil
+		}
+		return flush()
+	}
+
+	var err error
+	switch format {
+	case formatNDJSON:
+		err = readNDJSON(c.Request.Body, add, fail)
+	case formatCSV:
+		err = readCSV(c.Request.Body, add, fail)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ndjson or csv"})
+		return
+	}
+	if err == nil {
+		err = flush()
+	}
+
+	var headerErr csvHeaderError
+	if errors.As(err, &headerErr) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// readNDJSON parses one item per non-empty line. A line is either an
+// exported item with "data" and optional "id", or the data object itself.
+func readNDJSON(r io.Reader, add func(int, database.Item) error, fail func(int, string, error)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	row := 0
+	for scanner.Scan() {
+		row++
+		line := bytes.TrimSpace(scanner.Bytes())
+		if len(line) == 0 {
+			continue
+		}
+
+		var obj map[string]interface{}
+		if err := json.Unmarshal(line, &obj); err != nil || obj == nil {
+			fail(row, "", errors.New("invalid JSON object"))
+			continue
+		}
+
+		item := database.Item{Data: obj}
+		if data, ok := obj["data"].(map[string]interface{}); ok {
+			id, _ := obj["id"].(string)
+			version, _ := obj["version"].(float64)
+			item = database.Item{ID: id, Version: int64(version), Data: data}
+
+			if s, ok := obj["expires_at"].(string); ok {
+				expiresAt, err := parseExpiry(s)
+				if err != nil {
+					fail(row, id, err)
+					continue
+				}
+				item.ExpiresAt = expiresAt
+			}
+		}
+
+		if err := add(row, item); err != nil {
+			return err
+		}
+	}
+	return scanner.Err()
+}
+
+// csvHeaderError rejects a CSV import as a whole
+type csvHeaderError struct {
+	msg string
+}
+
+func (e csvHeaderError) Error() string {
+	return e.msg
+}
+
+// readCSV parses one item per record after the header. Columns are id,
+// expires_at, version and collection (both ignored) and data.<path> for
+// nested data keys, see columnPath.
+func readCSV(r io.Reader, add func(int, database.Item) error, fail func(int, string, error)) error {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return csvHeaderError{fmt.Sprintf("invalid CSV header: %v", err)}
+	}
+
+	idColumn, expiryColumn := -1, -1
+	paths := make([][]string, len(header))
+	for i, name := range header {
+		switch {
+		case name == "id":
+			idColumn = i
+		case name == "expires_at":
+			expiryColumn = i
+		case name == "version" || name == "collection":
+		case strings.HasPrefix(name, "data.") && len(name) > len("data."):
+			paths[i] = splitColumn(name[len("data."):])
+		default:
+			return csvHeaderError{fmt.Sprintf("unknown CSV column %q, data columns start with data.", name)}
+		}
+	}
+
+	row := 1
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			return nil
+		}
+		row++
+
+		var perr *csv.ParseError
+		if errors.As(err, &perr) {
+			fail(row, "", err)
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		item := database.Item{Data: map[string]interface{}{}}
+		if idColumn >= 0 && idColumn < len(record) {
+			item.ID = record[idColumn]
+		}
+
+		var rowErr error
+		if expiryColumn >= 0 && expiryColumn < len(record) && record[expiryColumn] != "" {
+			item.ExpiresAt, rowErr = parseExpiry(record[expiryColumn])
+		}
+		for i, cell := range record {
+			if rowErr != nil {
+				break
+			}
+			if i >= len(paths) || paths[i] == nil || cell == "" {
+				continue
+			}
+			if err := setPath(item.Data, paths[i], decodeCell(cell)); err != nil {
+				rowErr = fmt.Errorf("column %q: %w", header[i], err)
+				break
+			}
+		}
+		if rowErr != nil {
+			fail(row, item.ID, rowErr)
+			continue
+		}
+
+		if err := add(row, item); err != nil {
+			return err
+		}
+	}
+}
+
+// parseExpiry reads an RFC 3339 expires_at value
+func parseExpiry(s string) (*time.Time, error) {
+	t, err := time.Parse(time.RFC3339Nano, s)
+	if err != nil {
+		return nil, errors.New("expires_at must be an RFC 3339 timestamp")
+	}
+	return &t, nil
+}
+
+// setPath stores v in data under the nested keys of path
+func setPath(data map[string]interface{}, path []string, v interface{}) error {
+	for _, key := range path[:len(path)-1] {
+		next, ok := data[key]
+		if !ok {
+			m := map[string]interface{}{}
+			data[key] = m
+			data = m
+			continue
+		}
+		m, ok := next.(map[string]interface{})
+		if !ok {
+			return fmt.Errorf("%q is not an object", key)
+		}
+		data = m
+	}
+
+	last := path[len(path)-1]
+	if _, ok := data[last]; ok {
+		return errors.New("conflicts with another column")
+	}
+	data[last] = v
+	return nil
+}
+
+// decodeCell reads a CSV cell as a JSON value, falling back to the plain string
+func decodeCell(cell string) interface{} {
+	var v interface{}
+	if err := json.Unmarshal([]byte(cell), &v); err == nil {
+		return v
+	}
+	return cell
+}
+
+// encodeCell writes a value so that decodeCell reads it back. Strings are
+// written as is unless they would decode as another JSON value.
+func encodeCell(v interface{}) string {
+	if s, ok := v.(string); ok {
+		if decoded, isString := decodeCell(s).(string); isString && decoded == s && s != "" {
+			return s
+		}
+	}
+	raw, _ := json.Marshal(v)
+	return string(raw)
+}
+
+// flatten collects the leaves of nested objects under dotted column names,
+// keys are escaped by escapeKey. Arrays and empty objects are leaves.
+func flatten(prefix string, v interface{}, out map[string]interface{}) {
+	m, ok := v.(map[string]interface{})
+	if !ok || len(m) == 0 {
+		out[prefix] = v
+		return
+	}
+	for k, child := range m {
+		flatten(prefix+"."+escapeKey(k), child, out)
+	}
+}
+
+// escapeKey writes a data key as a segment of a dotted column name, with
+// a backslash before dots and backslashes so keys holding dots stay apart
+// from nested keys: {"a.b": 1} is data.a\.b and {"a": {"b": 1}} data.a.b
+func escapeKey(k string) string {
+	return strings.NewReplacer(`\`, `\\`, `.`, `\.`).Replace(k)
+}
+
+// splitColumn splits a dotted column name into the data keys of its path,
+// undoing escapeKey
+func splitColumn(name string) []string {
+	var path []string
+	var key strings.Builder
+	for i := 0; i < len(name); i++ {
+		switch {
+		case name[i] == '\\' && i+1 < len(name):
+			i++
+			key.WriteByte(name[i])
+		case name[i] == '.':
+			path = append(path, key.String())
+			key.Reset()
+		default:
+			key.WriteByte(name[i])
+		}
+	}
+	return append(path, key.String())
+}
+
+// exportItemsHandler godoc
+// @Summary Export items
+// @Description Streams the items matching the filters, in the format of /items without paging. Without sort the items are read in ID order a page at a time.
+// @Description NDJSON writes one item per line. CSV writes id, version, expires_at and one data.<path> column per nested data key found in a first pass over the items, keys have dots and backslashes escaped with a backslash and cells holding anything but plain strings are JSON.
+// @Tags items
+// @Produce plain
+// @Param collection query string false "Collection to export"
+// @Param format query string false "ndjson (default) or csv"
+// @Param sort query string false "Comma separated fields, prefix with - for descending"
+// @Success 200 {string} string "NDJSON lines or CSV records"
+// @Failure 400 {object} map[string]string "Unknown format or invalid query"
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /items/export [get]
+func exportItemsHandler(c *gin.Context) {
+	format := c.DefaultQuery("format", formatNDJSON)
+	if format != formatNDJSON && format != formatCSV {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be ndjson or csv"})
+		return
+	}
+
+	q, err := database.ParseQuery(c.Request.URL.Query(), "collection", "format")
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+	collection := itemCollection(c)
+
+	// The response starts with the first item, errors before it are
+	// reported as usual and later ones end the stream
+	started := false
+	start := func() {
+		started = true
+		c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="items.%s"`, format))
+		if format == formatCSV {
+			c.Header("Content-Type", "text/csv; charset=utf-8")
+		} else {
+			c.Header("Content-Type", "application/x-ndjson")
+		}
+	}
+
+	if format == formatCSV {
+		err = exportCSV(c.Writer, collection, q, start)
+	} else {
+		err = exportNDJSON(c.Writer, collection, q, start)
+	}
+	if err != nil && !started {
+		writeDBError(c, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Exporting items: %v", err)
+	}
+}
+
+// exportNDJSON writes one item per line as it is read, calling start
+// before the first
+func exportNDJSON(w io.Writer, collection string, q database.Query, start func()) error {
+	bw := bufio.NewWriter(w)
+	enc := json.NewEncoder(bw)
+	started := false
+	err := database.EachItem(collection, q, func(item database.Item) error {
+		if !started {
+			started = true
+			start()
+		}
+		return enc.Encode(item)
+	})
+	if err != nil {
+		return err
+	}
+	if !started {
+		start()
+	}
+	return bw.Flush()
+}
+
+// exportCSV writes the items with one column per data path. A first pass
+// collects the paths, the second writes the records as they are read.
+// Paths first appearing between the passes are left out.
+func exportCSV(w io.Writer, collection string, q database.Query, start func()) error {
+	columns := map[string]bool{}
+	err := database.EachItem(collection, q, func(item database.Item) error {
+		for k := range flattenData(item.Data) {
+			columns[k] = true
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	dataColumns := make([]string, 0, len(columns))
+	for k := range columns {
+		dataColumns = append(dataColumns, k)
+	}
+	sort.Strings(dataColumns)
+	header := append([]string{"id", "version", "expires_at"}, dataColumns...)
+
+	start()
+	cw := csv.NewWriter(w)
+	if err := cw.Write(header); err != nil {
+		return err
+	}
+	err = database.EachItem(collection, q, func(item database.Item) error {
+		row := flattenData(item.Data)
+		record := make([]string, len(header))
+		record[0] = item.ID
+		record[1] = strconv.FormatInt(item.Version, 10)
+		if item.ExpiresAt != nil {
+			record[2] = item.ExpiresAt.UTC().Format(time.RFC3339Nano)
+		}
+		for j, col := range dataColumns {
+			if v, ok := row[col]; ok {
+				record[j+3] = encodeCell(v)
+			}
+		}
+		return cw.Write(record)
+	})
+	if err != nil {
+		return err
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+// flattenData returns the leaves of item data keyed by column name
+func flattenData(data map[string]interface{}) map[string]interface{} {
+	row := map[string]interface{}{}
+	for k, v := range data {
+		flatten("data."+escapeKey(k), v, row)
+	}
+	return row
+}
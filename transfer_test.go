@@ -0,0 +1,176 @@
+package main
+
+import (
+	"encoding/json"
+	"go-backend/database"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// serveItems gives the test an empty database and returns a router with
+// the import and export routes
+func serveItems(t *testing.T) *gin.Engine {
+	t.Helper()
+	gin.SetMode(gin.TestMode)
+	database.SetBackend(database.NewMemoryBackend())
+	t.Cleanup(func() { database.SetBackend(database.NewMemoryBackend()) })
+
+	r := gin.New()
+	r.POST("/items/import", importItemsHandler)
+	r.GET("/items/export", exportItemsHandler)
+	return r
+}
+
+// importItems posts body to target and decodes the import result
+func importItems(t *testing.T, r *gin.Engine, target, contentType, body string) importResult {
+	t.Helper()
+	req := httptest.NewRequest(http.MethodPost, target, strings.NewReader(body))
+	req.Header.Set("Content-Type", contentType)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Fatalf("POST %s: status %d: %s", target, w.Code, w.Body)
+	}
+
+	var result importResult
+	if err := json.Unmarshal(w.Body.Bytes(), &result); err != nil {
+		t.Fatal(err)
+	}
+	return result
+}
+
+// errorRows returns the failed rows in order, rows failing on write are
+// reported after the ones that did not parse
+func errorRows(result importResult) []int {
+	rows := []int{}
+	for _, e := range result.Errors {
+		rows = append(rows, e.Row)
+	}
+	sort.Ints(rows)
+	return rows
+}
+
+func TestImportNDJSONRowErrors(t *testing.T) {
+	r := serveItems(t)
+	schema := `{"type": "object", "required": ["name"]}`
+	if err := database.CreateCollection(database.Collection{Name: "assets", Schema: json.RawMessage(schema)}); err != nil {
+		t.Fatal(err)
+	}
+
+	body := strings.Join([]string{
+		`{"id": "id-1", "data": {"name": "pump"}}`,
+		``,
+		`{"name": "valve"`,
+		`{"name": "tank"}`,
+		`{"id": "pump-1", "data": {"name": "pump"}}`,
+		`{"id": "id-2", "expires_at": "tomorrow", "data": {"name": "pump"}}`,
+		`{"id": "id-3", "data": {"site": "north"}}`,
+		`[1, 2]`,
+	}, "\n")
+	result := importItems(t, r, "/items/import?collection=assets", "application/x-ndjson", body)
+
+	if result.Imported != 2 || result.Failed != 5 {
+		t.Errorf("imported %d, failed %d, want 2 and 5", result.Imported, result.Failed)
+	}
+	if got, want := errorRows(result), []int{3, 5, 6, 7, 8}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failed rows = %v, want %v: %+v", got, want, result.Errors)
+	}
+	for _, e := range result.Errors {
+		if e.Row == 7 && (e.ID != "id-3" || len(e.Issues) != 1 || e.Issues[0].Keyword != "required") {
+			t.Errorf("row 7 = %+v, want a required issue for id-3", e)
+		}
+	}
+
+	item, err := database.GetItem("assets", "id-1")
+	if err != nil || item.Data["name"] != "pump" {
+		t.Errorf("imported item = %+v, %v", item, err)
+	}
+}
+
+func TestImportCSVRowErrors(t *testing.T) {
+	r := serveItems(t)
+
+	body := "id,expires_at,data.name,data.site.floor\n" +
+		"id-1,,pump,2\n" +
+		"id-2,soon,valve,1\n" +
+		"id-3,,\"tank\n" +
+		"id-4,,crane,3\n"
+	result := importItems(t, r, "/items/import", "text/csv", body)
+
+	if result.Imported != 1 || result.Failed != 2 {
+		t.Errorf("imported %d, failed %d, want 1 and 2", result.Imported, result.Failed)
+	}
+	if got, want := errorRows(result), []int{3, 4}; !reflect.DeepEqual(got, want) {
+		t.Errorf("failed rows = %v, want %v: %+v", got, want, result.Errors)
+	}
+
+	item, err := database.GetItem("", "id-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := map[string]interface{}{"name": "pump", "site": map[string]interface{}{"floor": 2.0}}
+	if !reflect.DeepEqual(item.Data, want) {
+		t.Errorf("imported data = %v, want %v", item.Data, want)
+	}
+
+	req := httptest.NewRequest(http.MethodPost, "/items/import?format=csv", strings.NewReader("id,name\nid-1,pump\n"))
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("importing a CSV with a bad header: status %d, want 400", w.Code)
+	}
+}
+
+func TestExportItems(t *testing.T) {
+	r := serveItems(t)
+	body := `{"id": "id-1", "expires_at": "2100-01-02T03:04:05Z", "data": {"name": "pump", "n": 2, "code": "01", "count": "2", "a.b": "x", "tags": ["t"], "site": {"floor": 1}}}` + "\n" +
+		`{"id": "id-2", "data": {"name": "valve, large"}}` + "\n"
+	if result := importItems(t, r, "/items/import", "application/x-ndjson", body); result.Failed != 0 {
+		t.Fatalf("import failed: %+v", result.Errors)
+	}
+
+	w := get(r, "/items/export?format=csv")
+	want := "id,version,expires_at,data.a\\.b,data.code,data.count,data.n,data.name,data.site.floor,data.tags\n" +
+		"id-1,1,2100-01-02T03:04:05Z,x,01,\"\"\"2\"\"\",2,pump,1,\"[\"\"t\"\"]\"\n" +
+		"id-2,1,,,,,,\"valve, large\",,\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("CSV export: status %d:\n%s\nwant:\n%s", w.Code, w.Body, want)
+	}
+
+	w = get(r, "/items/export?data.name=pump")
+	want = `{"id":"id-1","version":1,"expires_at":"2100-01-02T03:04:05Z","data":{"a.b":"x","code":"01","count":"2","n":2,"name":"pump","site":{"floor":1},"tags":["t"]}}` + "\n"
+	if w.Code != http.StatusOK || w.Body.String() != want {
+		t.Errorf("NDJSON export: status %d:\n%s\nwant:\n%s", w.Code, w.Body, want)
+	}
+
+	if w := get(r, "/items/export?format=xml"); w.Code != http.StatusBadRequest {
+		t.Errorf("exporting as xml: status %d, want 400", w.Code)
+	}
+}
+
+func TestImportExportRoundTrip(t *testing.T) {
+	body := `{"id": "id-1", "expires_at": "2100-01-02T03:04:05.5Z", "data": {"name": "pump", "n": 2.5, "code": "01", "on": true, "none": null, "a.b\\c": "x", "tags": ["t", 1], "empty": {}, "site": {"floor": 1, "room": "true"}}}` + "\n" +
+		`{"id": "id-2", "data": {"name": "line\nbreak"}}` + "\n"
+
+	for _, format := range []string{"ndjson", "csv"} {
+		r := serveItems(t)
+		if result := importItems(t, r, "/items/import", "application/x-ndjson", body); result.Failed != 0 {
+			t.Fatalf("import failed: %+v", result.Errors)
+		}
+		exported := get(r, "/items/export?format="+format).Body.String()
+
+		r = serveItems(t)
+		if result := importItems(t, r, "/items/import?format="+format, "text/plain", exported); result.Imported != 2 || result.Failed != 0 {
+			t.Fatalf("%s: re-import = %+v", format, result)
+		}
+		if again := get(r, "/items/export?format="+format).Body.String(); again != exported {
+			t.Errorf("%s export after a round trip:\n%s\nwant:\n%s", format, again, exported)
+		}
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"go-backend/database"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// startSweeper expires items and empties old trash every SWEEP_INTERVAL
+// (default 1m). Deleted items stay recoverable for TRASH_RETENTION (default 720h).
+func startSweeper() {
+	interval, err := envDuration("SWEEP_INTERVAL", time.Minute)
+	if err != nil {
+		log.Fatal(err)
+	}
+	retention, err := envDuration("TRASH_RETENTION", database.DefaultTrashRetention)
+	if err != nil {
+		log.Fatal(err)
+	}
+	go database.ScheduleSweeper(interval, retention, nil)
+}
+
+// listTrashHandler godoc
+// @Summary List deleted items
+// @Description Returns the deleted items of a collection that can still be undeleted, most recently deleted first
+// @Tags trash
+// @Produce json
+// @Param collection query string false "Collection to list, items outside any collection by default"
+// @Success 200 {array} database.TrashEntry
+// @Failure 404 {object} map[string]string "Collection not found"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /trash [get]
+func listTrashHandler(c *gin.Context) {
+	entries, err := database.ListTrash(itemCollection(c))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, entries)
+}
+
+// undeleteItemHandler godoc
+// @Summary Undelete an item
+// @Description Takes a deleted item back out of the trash with a new version
+// @Tags trash
+// @Produce json
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Success 200 {object} Item
+// @Header 200 {string} ETag "New item version"
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Item not in the trash"
+// @Failure 409 {object} map[string]string "Item ID was reused"
+// @Failure 422 {object} validationErrorResponse "Item does not match the current collection schema"
+// @Failure 500 {object} map[string]string "Failed to read/write DB"
+// @Router /item/{id}/undelete [post]
+func undeleteItemHandler(c *gin.Context) {
+	item, err := database.UndeleteItem(itemCollection(c), c.Param("id"), requestAuthor(c))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Header("ETag", itemETag(item))
+	c.JSON(http.StatusOK, item)
+}
+
+// purgeTrashHandler godoc
+// @Summary Purge a deleted item
+// @Description Removes a deleted item from the trash for good, its revision history is kept
+// @Tags trash
+// @Param id path string true "Item ID"
+// @Param collection query string false "Collection the item belongs to"
+// @Success 204 "Item purged"
+// @Failure 400 {object} map[string]string "Invalid item ID"
+// @Failure 404 {object} map[string]string "Item not in the trash"
+// @Failure 500 {object} map[string]string "Failed to read/write DB"
+// @Router /trash/{id} [delete]
+func purgeTrashHandler(c *gin.Context) {
+	if err := database.PurgeItem(itemCollection(c), c.Param("id")); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
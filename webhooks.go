@@ -0,0 +1,232 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"go-backend/database"
+	"go-backend/ids"
+	"go-backend/webhooks"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// dispatcher sends the webhook deliveries of this process
+var dispatcher = webhooks.New(nil)
+
+// startWebhooks resumes pending deliveries and starts sending new ones
+func startWebhooks() {
+	if err := dispatcher.Start(nil); err != nil {
+		log.Fatalf("Failed to start webhooks: %v", err)
+	}
+}
+
+// requireWebhookAdmin protects the webhook endpoints with the ADMIN_TOKEN
+// bearer token. Like the other admin endpoints they stay closed without
+// ADMIN_TOKEN, webhooks make the server send requests to any URL.
+func requireWebhookAdmin(c *gin.Context) {
+	if os.Getenv("ADMIN_TOKEN") == "" {
+		c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "Webhooks are disabled, set ADMIN_TOKEN to enable them"})
+		return
+	}
+	requireAdmin(c)
+}
+
+// webhookRequest is the body of POST /webhooks
+type webhookRequest struct {
+	URL    string   `json:"url" binding:"required" example:"http://localhost:9000/hooks"`
+	Events []string `json:"events" binding:"required" example:"item.created,item.deleted"`
+	Secret string   `json:"secret" example:"generated when empty"`
+}
+
+// redactSecret hides the secret of a webhook, it is only shown on creation
+func redactSecret(w database.Webhook) database.Webhook {
+	w.Secret = ""
+	return w
+}
+
+// createWebhookHandler godoc
+// @Summary Create a webhook
+// @Description Subscribes a URL to item.created, item.updated and item.deleted events.
+// @Description Deliveries are signed with the secret in the X-Webhook-Signature header, t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>">.
+// @Description The secret is generated if empty and only returned here.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param webhook body webhookRequest true "Webhook"
+// @Success 201 {object} database.Webhook
+// @Failure 400 {object} map[string]string "Invalid webhook"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Webhooks are disabled without ADMIN_TOKEN"
+// @Failure 500 {object} map[string]string "Failed to write DB"
+// @Router /webhooks [post]
+func createWebhookHandler(c *gin.Context) {
+	var req webhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid JSON"})
+		return
+	}
+
+	if req.Secret == "" {
+		b := make([]byte, 32)
+		if _, err := rand.Read(b); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate secret"})
+			return
+		}
+		req.Secret = hex.EncodeToString(b)
+	}
+
+	w := database.Webhook{
+		ID:        ids.NewWebhook(),
+		URL:       req.URL,
+		Events:    req.Events,
+		Secret:    req.Secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := database.CreateWebhook(w); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, w)
+}
+
+// listWebhooksHandler godoc
+// @Summary List webhooks
+// @Description Returns all webhooks, oldest first, without their secrets
+// @Tags webhooks
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Success 200 {array} database.Webhook
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Webhooks are disabled without ADMIN_TOKEN"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /webhooks [get]
+func listWebhooksHandler(c *gin.Context) {
+	hooks, err := database.ListWebhooks()
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	for i := range hooks {
+		hooks[i] = redactSecret(hooks[i])
+	}
+	c.JSON(http.StatusOK, hooks)
+}
+
+// getWebhookHandler godoc
+// @Summary Get a webhook
+// @Tags webhooks
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} database.Webhook
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Webhooks are disabled without ADMIN_TOKEN"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Router /webhooks/{id} [get]
+func getWebhookHandler(c *gin.Context) {
+	w, err := database.GetWebhook(c.Param("id"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, redactSecret(w))
+}
+
+// deleteWebhookHandler godoc
+// @Summary Delete a webhook
+// @Description Deletes a webhook and its delivery log, pending deliveries are dropped
+// @Tags webhooks
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param id path string true "Webhook ID"
+// @Success 204 "Webhook deleted"
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Webhooks are disabled without ADMIN_TOKEN"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Router /webhooks/{id} [delete]
+func deleteWebhookHandler(c *gin.Context) {
+	if err := database.DeleteWebhook(c.Param("id")); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// listWebhookDeliveriesHandler godoc
+// @Summary List the deliveries of a webhook
+// @Description Returns the delivery log of a webhook newest first, with the attempts and the last response of each delivery. Succeeded deliveries are kept for 7 days.
+// @Tags webhooks
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param id path string true "Webhook ID"
+// @Param status query string false "Only deliveries in this state" Enums(pending, succeeded, dead)
+// @Success 200 {array} database.Delivery
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Webhooks are disabled without ADMIN_TOKEN"
+// @Failure 404 {object} map[string]string "Webhook not found"
+// @Router /webhooks/{id}/deliveries [get]
+func listWebhookDeliveriesHandler(c *gin.Context) {
+	if _, err := database.GetWebhook(c.Param("id")); err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	deliveries, err := database.ListDeliveries(c.Param("id"), c.Query("status"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// listDeadLettersHandler godoc
+// @Summary List dead deliveries
+// @Description Returns the deliveries of all webhooks that failed every attempt, newest first
+// @Tags webhooks
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Success 200 {array} database.Delivery
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Webhooks are disabled without ADMIN_TOKEN"
+// @Failure 500 {object} map[string]string "Failed to read DB"
+// @Router /webhooks/dead-letters [get]
+func listDeadLettersHandler(c *gin.Context) {
+	deliveries, err := database.ListDeliveries("", database.DeliveryDead)
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, deliveries)
+}
+
+// retryDeliveryHandler godoc
+// @Summary Retry a delivery
+// @Description Sends a delivery again, starting over with the first attempt
+// @Tags webhooks
+// @Produce json
+// @Param Authorization header string true "Bearer ADMIN_TOKEN"
+// @Param id path string true "Delivery ID"
+// @Success 202 {object} database.Delivery
+// @Failure 401 {object} map[string]string "Unauthorized"
+// @Failure 403 {object} map[string]string "Webhooks are disabled without ADMIN_TOKEN"
+// @Failure 404 {object} map[string]string "Delivery not found"
+// @Router /webhooks/deliveries/{id}/retry [post]
+func retryDeliveryHandler(c *gin.Context) {
+	d, err := dispatcher.Retry(c.Param("id"))
+	if err != nil {
+		writeDBError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, d)
+}
@@ -0,0 +1,415 @@
+// Package webhooks delivers item events to the webhook
+// subscriptions stored in the database. Every delivery is logged, failed
+// attempts are retried with exponential backoff and deliveries that fail
+// MaxAttempts times end up in the dead-letter list.
+//
+// A delivery is a POST of a JSON Envelope with the headers
+//
+//	X-Webhook-ID         delivery ID, the same for every attempt
+//	X-Webhook-Event      event type, e.g. item.created
+//	X-Webhook-Signature  t=<unix time>,v1=<hex HMAC-SHA256 of "<t>.<body>" keyed with the secret>
+package webhooks
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"go-backend/database"
+	"go-backend/ids"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MaxAttempts is how often a delivery is tried before it is dead
+const MaxAttempts = 8
+
+const (
+	initialBackoff = 5 * time.Second
+	maxBackoff     = time.Hour
+	requestTimeout = 10 * time.Second
+	workers        = 4
+
+	// logRetention is how long succeeded deliveries stay in the log
+	logRetention = 7 * 24 * time.Hour
+)
+
+// Envelope is the body of every delivery
+type Envelope struct {
+	ID        string          `json:"id"`
+	Event     string          `json:"event"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data" swaggertype:"object"`
+}
+
+// Dispatcher turns events into deliveries and sends them
+type Dispatcher struct {
+	client *http.Client
+
+	mu       sync.Mutex
+	due      map[string]time.Time // pending delivery IDs and their next attempt
+	inflight map[string]bool
+	failures map[string]int // consecutive database errors by delivery ID
+	wake     chan struct{}
+}
+
+// New returns a dispatcher sending with client, nil uses a client with a 10s timeout
+func New(client *http.Client) *Dispatcher {
+	if client == nil {
+		client = &http.Client{Timeout: requestTimeout}
+	}
+	return &Dispatcher{
+		client:   client,
+		due:      map[string]time.Time{},
+		inflight: map[string]bool{},
+		failures: map[string]int{},
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Start resumes the pending deliveries of the log, follows the item change
+// feed and sends deliveries until stop is closed
+func (d *Dispatcher) Start(stop <-chan struct{}) error {
+	pending, err := database.ListDeliveries("", database.DeliveryPending)
+	if err != nil {
+		return err
+	}
+	d.mu.Lock()
+	for _, del := range pending {
+		d.due[del.ID] = nextAttempt(del)
+	}
+	d.mu.Unlock()
+
+	go d.follow(stop)
+	go d.run(stop)
+	return nil
+}
+
+// Publish creates a delivery of the event for every webhook subscribed to it
+func (d *Dispatcher) Publish(event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	hooks, err := database.ListWebhooks()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now().UTC()
+	for _, hook := range hooks {
+		if !hook.Subscribes(event) {
+			continue
+		}
+
+		del := database.Delivery{
+			ID:            ids.NewDelivery(),
+			WebhookID:     hook.ID,
+			Event:         event,
+			Payload:       payload,
+			Status:        database.DeliveryPending,
+			CreatedAt:     now,
+			NextAttemptAt: &now,
+		}
+		if err := database.SaveDelivery(del); err != nil {
+			return err
+		}
+		d.schedule(del.ID, now)
+	}
+	return nil
+}
+
+// Retry sends a delivery again from the first attempt, typically one from the dead-letter list
+func (d *Dispatcher) Retry(id string) (database.Delivery, error) {
+	del, err := database.GetDelivery(id)
+	if err != nil {
+		return del, err
+	}
+
+	now := time.Now().UTC()
+	del.Status = database.DeliveryPending
+	del.Attempts = 0
+	del.NextAttemptAt = &now
+	if err := database.SaveDelivery(del); err != nil {
+		return del, err
+	}
+
+	d.schedule(del.ID, now)
+	return del, nil
+}
+
+// Sign returns the X-Webhook-Signature of a body sent at t
+func Sign(secret string, t time.Time, body []byte) string {
+	ts := strconv.FormatInt(t.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(ts + "."))
+	mac.Write(body)
+	return "t=" + ts + ",v1=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// Verify checks a X-Webhook-Signature header on the receiving side,
+// rejecting signatures older than tolerance
+func Verify(secret, header string, body []byte, tolerance time.Duration) error {
+	var ts, sig string
+	for _, part := range strings.Split(header, ",") {
+		k, v, _ := strings.Cut(part, "=")
+		switch k {
+		case "t":
+			ts = v
+		case "v1":
+			sig = v
+		}
+	}
+
+	unix, err := strconv.ParseInt(ts, 10, 64)
+	if err != nil || sig == "" {
+		return errors.New("malformed signature")
+	}
+	t := time.Unix(unix, 0)
+	if age := time.Since(t); age > tolerance || age < -tolerance {
+		return errors.New("signature expired")
+	}
+	if !hmac.Equal([]byte(Sign(secret, t, body)), []byte(header)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+// Backoff returns the wait after the given number of failed attempts: 5s, 10s, 20s, ... up to an hour
+func Backoff(attempts int) time.Duration {
+	wait := initialBackoff
+	for i := 1; i < attempts && wait < maxBackoff; i++ {
+		wait *= 2
+	}
+	if wait > maxBackoff {
+		wait = maxBackoff
+	}
+	return wait
+}
+
+// follow publishes the item change feed, resuming after the last event
+// seen when the subscription is dropped. Failed subscriptions are retried
+// with backoff until stop is closed.
+func (d *Dispatcher) follow(stop <-chan struct{}) {
+	var lastID uint64
+	resume := false
+	failures := 0
+
+	for {
+		sub, missed, err := database.SubscribeEvents(lastID, resume)
+		if errors.Is(err, database.ErrEventsLost) {
+			log.Printf("webhooks: item events lost after %d", lastID)
+			sub, missed, err = database.SubscribeEvents(0, false)
+		}
+		if err != nil {
+			failures++
+			wait := Backoff(failures)
+			log.Printf("webhooks: subscribing to item events failed: %v, retrying in %s", err, wait)
+			select {
+			case <-stop:
+				return
+			case <-time.After(wait):
+			}
+			continue
+		}
+		failures = 0
+
+		for _, e := range missed {
+			d.itemEvent(e)
+			lastID = e.ID
+		}
+
+		for open := true; open; {
+			select {
+			case <-stop:
+				sub.Close()
+				return
+			case e, ok := <-sub.Events:
+				if !ok {
+					open = false
+					break
+				}
+				d.itemEvent(e)
+				lastID, resume = e.ID, true
+			}
+		}
+	}
+}
+
+func (d *Dispatcher) itemEvent(e database.Event) {
+	if err := d.Publish("item."+e.Type, e); err != nil {
+		log.Printf("webhooks: failed to queue item event %d: %v", e.ID, err)
+	}
+}
+
+func (d *Dispatcher) schedule(id string, at time.Time) {
+	d.mu.Lock()
+	d.due[id] = at
+	d.mu.Unlock()
+
+	select {
+	case d.wake <- struct{}{}:
+	default:
+	}
+}
+
+// run sends due deliveries, at most workers at a time
+func (d *Dispatcher) run(stop <-chan struct{}) {
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+	prune := time.NewTicker(time.Hour)
+	defer prune.Stop()
+	sem := make(chan struct{}, workers)
+
+	for {
+		select {
+		case <-stop:
+			return
+		case <-prune.C:
+			if _, err := database.PruneDeliveries(time.Now().Add(-logRetention)); err != nil {
+				log.Printf("webhooks: pruning the delivery log failed: %v", err)
+			}
+			continue
+		case <-ticker.C:
+		case <-d.wake:
+		}
+
+		now := time.Now()
+		d.mu.Lock()
+		var ready []string
+		for id, at := range d.due {
+			if !at.After(now) && !d.inflight[id] {
+				d.inflight[id] = true
+				ready = append(ready, id)
+			}
+		}
+		d.mu.Unlock()
+
+		for _, id := range ready {
+			sem <- struct{}{}
+			go func(id string) {
+				defer func() { <-sem }()
+				d.attempt(id)
+			}(id)
+		}
+	}
+}
+
+// attempt sends a delivery once and records the outcome
+func (d *Dispatcher) attempt(id string) {
+	next, err := d.deliver(id)
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	delete(d.inflight, id)
+	if err != nil {
+		// The delivery could not be loaded or its outcome not recorded,
+		// retried with the same backoff as failed requests
+		d.failures[id]++
+		wait := Backoff(d.failures[id])
+		d.due[id] = time.Now().Add(wait)
+		log.Printf("webhooks: delivery %s: %v, retrying in %s", id, err, wait)
+		return
+	}
+	delete(d.failures, id)
+	if next.IsZero() {
+		delete(d.due, id)
+	} else {
+		d.due[id] = next
+	}
+}
+
+// deliver returns when to try again, zero once the delivery is done or dead
+func (d *Dispatcher) deliver(id string) (time.Time, error) {
+	del, err := database.GetDelivery(id)
+	if errors.Is(err, database.ErrDeliveryNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+	if del.Status != database.DeliveryPending {
+		return time.Time{}, nil
+	}
+
+	hook, err := database.GetWebhook(del.WebhookID)
+	if errors.Is(err, database.ErrWebhookNotFound) {
+		return time.Time{}, nil
+	}
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	status, sendErr := d.send(hook, del)
+	now := time.Now().UTC()
+	del.Attempts++
+	del.LastStatus = status
+	del.NextAttemptAt = nil
+
+	var next time.Time
+	switch {
+	case sendErr == nil:
+		del.Status = database.DeliverySucceeded
+		del.DeliveredAt = &now
+		del.LastError = ""
+	case del.Attempts >= MaxAttempts:
+		del.Status = database.DeliveryDead
+		del.LastError = sendErr.Error()
+		log.Printf("webhooks: delivery %s to %s is dead after %d attempts: %v", del.ID, hook.URL, del.Attempts, sendErr)
+	default:
+		next = now.Add(Backoff(del.Attempts))
+		del.NextAttemptAt = &next
+		del.LastError = sendErr.Error()
+	}
+
+	if err := database.SaveDelivery(del); err != nil {
+		return time.Time{}, err
+	}
+	return next, nil
+}
+
+// send posts the signed envelope and returns the response status
+func (d *Dispatcher) send(hook database.Webhook, del database.Delivery) (int, error) {
+	body, err := json.Marshal(Envelope{ID: del.ID, Event: del.Event, CreatedAt: del.CreatedAt, Data: del.Payload})
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "go-backend-webhooks")
+	req.Header.Set("X-Webhook-ID", del.ID)
+	req.Header.Set("X-Webhook-Event", del.Event)
+	req.Header.Set("X-Webhook-Signature", Sign(hook.Secret, time.Now(), body))
+
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func nextAttempt(del database.Delivery) time.Time {
+	if del.NextAttemptAt != nil {
+		return *del.NextAttemptAt
+	}
+	return del.CreatedAt
+}
@@ -0,0 +1,236 @@
+package webhooks
+
+import (
+	"encoding/json"
+	"go-backend/database"
+	"go-backend/ids"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a local webhook endpoint answering with status
+type receiver struct {
+	*httptest.Server
+	secret string
+
+	mu       sync.Mutex
+	status   int
+	requests []*http.Request
+	bodies   [][]byte
+	received chan struct{}
+}
+
+func newReceiver(t *testing.T, secret string) *receiver {
+	r := &receiver{secret: secret, status: http.StatusOK, received: make(chan struct{}, MaxAttempts+1)}
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		r.mu.Lock()
+		r.requests = append(r.requests, req)
+		r.bodies = append(r.bodies, body)
+		status := r.status
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+		r.received <- struct{}{}
+	}))
+	t.Cleanup(r.Close)
+	return r
+}
+
+func (r *receiver) answer(status int) {
+	r.mu.Lock()
+	r.status = status
+	r.mu.Unlock()
+}
+
+func (r *receiver) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.requests)
+}
+
+// setup subscribes a new receiver to item.created on an empty database
+func setup(t *testing.T) (*receiver, database.Webhook) {
+	database.SetBackend(database.NewMemoryBackend())
+	t.Cleanup(func() { database.SetBackend(database.NewMemoryBackend()) })
+
+	r := newReceiver(t, "s3cret")
+	hook := database.Webhook{
+		ID:        ids.NewWebhook(),
+		URL:       r.URL,
+		Events:    []string{database.WebhookItemCreated},
+		Secret:    r.secret,
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := database.CreateWebhook(hook); err != nil {
+		t.Fatal(err)
+	}
+	return r, hook
+}
+
+// publish queues one event and returns its delivery
+func publish(t *testing.T, d *Dispatcher, hook database.Webhook) database.Delivery {
+	t.Helper()
+	if err := d.Publish(database.WebhookItemCreated, map[string]string{"id": "a"}); err != nil {
+		t.Fatal(err)
+	}
+	pending, err := database.ListDeliveries(hook.ID, database.DeliveryPending)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 1 {
+		t.Fatalf("%d pending deliveries, want 1", len(pending))
+	}
+	return pending[0]
+}
+
+func getDelivery(t *testing.T, id string) database.Delivery {
+	t.Helper()
+	del, err := database.GetDelivery(id)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return del
+}
+
+func TestSignedDelivery(t *testing.T) {
+	r, hook := setup(t)
+	d := New(r.Client())
+	stop := make(chan struct{})
+	defer close(stop)
+	if err := d.Start(stop); err != nil {
+		t.Fatal(err)
+	}
+
+	del := publish(t, d, hook)
+	select {
+	case <-r.received:
+	case <-time.After(5 * time.Second):
+		t.Fatal("no delivery within 5s")
+	}
+
+	r.mu.Lock()
+	req, body := r.requests[0], r.bodies[0]
+	r.mu.Unlock()
+	if err := Verify(r.secret, req.Header.Get("X-Webhook-Signature"), body, time.Minute); err != nil {
+		t.Errorf("signature: %v", err)
+	}
+	if err := Verify("other", req.Header.Get("X-Webhook-Signature"), body, time.Minute); err == nil {
+		t.Error("the signature verifies with another secret")
+	}
+	if got := req.Header.Get("X-Webhook-ID"); got != del.ID {
+		t.Errorf("X-Webhook-ID = %q, want %q", got, del.ID)
+	}
+
+	var env Envelope
+	if err := json.Unmarshal(body, &env); err != nil {
+		t.Fatal(err)
+	}
+	if env.ID != del.ID || env.Event != database.WebhookItemCreated || string(env.Data) != `{"id":"a"}` {
+		t.Errorf("envelope = %+v", env)
+	}
+
+	deadline := time.Now().Add(5 * time.Second)
+	for getDelivery(t, del.ID).Status != database.DeliverySucceeded {
+		if time.Now().After(deadline) {
+			t.Fatalf("delivery = %+v, want it succeeded", getDelivery(t, del.ID))
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRetryAndDeadLetter(t *testing.T) {
+	r, hook := setup(t)
+	r.answer(http.StatusServiceUnavailable)
+	d := New(r.Client())
+	del := publish(t, d, hook)
+
+	for attempt := 1; attempt <= MaxAttempts; attempt++ {
+		before := time.Now()
+		next, err := d.deliver(del.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := getDelivery(t, del.ID)
+		if got.Attempts != attempt || got.LastStatus != http.StatusServiceUnavailable || got.LastError == "" {
+			t.Fatalf("delivery after attempt %d = %+v", attempt, got)
+		}
+
+		if attempt < MaxAttempts {
+			wait := next.Sub(before)
+			if got.Status != database.DeliveryPending || wait < Backoff(attempt) || wait > Backoff(attempt)+time.Second {
+				t.Fatalf("attempt %d: status %s, next attempt in %s, want pending in %s", attempt, got.Status, wait, Backoff(attempt))
+			}
+		} else if got.Status != database.DeliveryDead || !next.IsZero() {
+			t.Fatalf("after %d attempts: status %s, next attempt %v, want dead", attempt, got.Status, next)
+		}
+	}
+	if n := r.count(); n != MaxAttempts {
+		t.Errorf("receiver got %d requests, want %d", n, MaxAttempts)
+	}
+
+	dead, err := database.ListDeliveries("", database.DeliveryDead)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dead) != 1 || dead[0].ID != del.ID {
+		t.Errorf("dead-letter list = %+v, want the delivery", dead)
+	}
+
+	// a dead delivery is not sent again by itself
+	if next, err := d.deliver(del.ID); err != nil || !next.IsZero() || r.count() != MaxAttempts {
+		t.Errorf("dead delivery was attempted again: next %v, err %v", next, err)
+	}
+
+	r.answer(http.StatusNoContent)
+	retried, err := d.Retry(del.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if retried.Status != database.DeliveryPending || retried.Attempts != 0 {
+		t.Errorf("retried delivery = %+v, want pending without attempts", retried)
+	}
+	if _, err := d.deliver(del.ID); err != nil {
+		t.Fatal(err)
+	}
+	if got := getDelivery(t, del.ID); got.Status != database.DeliverySucceeded || got.Attempts != 1 || got.DeliveredAt == nil {
+		t.Errorf("delivery after a manual retry = %+v, want succeeded at the first attempt", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{1, 5 * time.Second},
+		{2, 10 * time.Second},
+		{3, 20 * time.Second},
+		{10, 2560 * time.Second},
+		{11, time.Hour},
+		{50, time.Hour},
+	}
+	for _, tt := range tests {
+		if got := Backoff(tt.attempts); got != tt.want {
+			t.Errorf("Backoff(%d) = %s, want %s", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestVerifyRejectsOldSignatures(t *testing.T) {
+	body := []byte(`{"id":"a"}`)
+	header := Sign("s3cret", time.Now().Add(-time.Hour), body)
+	if err := Verify("s3cret", header, body, 5*time.Minute); err == nil {
+		t.Error("an hour old signature verifies with a 5m tolerance")
+	}
+	if err := Verify("s3cret", header, []byte(`{"id":"b"}`), 2*time.Hour); err == nil {
+		t.Error("the signature verifies another body")
+	}
+	if err := Verify("s3cret", "v1=abc", body, time.Hour); err == nil {
+		t.Error("a signature without time verifies")
+	}
+}